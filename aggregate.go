@@ -0,0 +1,154 @@
+package tcpconnparser
+
+import (
+	"net"
+	"sort"
+	"strconv"
+)
+
+// GroupKey returns the key of the Group to which the given connection belongs, and whether
+// it belongs to any Group.
+type GroupKey func(conn *Connection) (string, bool)
+
+// GroupByRemoteAddr groups connections by their remote address. Listening connections,
+// which have no remote address, belong to no Group.
+func GroupByRemoteAddr(conn *Connection) (string, bool) {
+	if conn.State == StateListen || conn.RemoteAddr == nil {
+		return "", false
+	}
+
+	return conn.RemoteAddr.String(), true
+}
+
+// GroupByRemotePrefix returns a GroupKey grouping connections by the prefix containing
+// their remote address, as returned by Prefix. Listening connections, which have no remote
+// address, belong to no Group.
+func GroupByRemotePrefix(ipv4PrefixLen, ipv6PrefixLen int) GroupKey {
+	return func(conn *Connection) (string, bool) {
+		if conn.State == StateListen || conn.RemoteAddr == nil {
+			return "", false
+		}
+
+		return Prefix(conn.RemoteAddr, ipv4PrefixLen, ipv6PrefixLen).String(), true
+	}
+}
+
+// GroupByLocalPort groups connections by their local port.
+func GroupByLocalPort(conn *Connection) (string, bool) {
+	return strconv.Itoa(int(conn.LocalPort)), true
+}
+
+// GroupByUID groups connections by the UID owning their socket.
+func GroupByUID(conn *Connection) (string, bool) {
+	return strconv.FormatUint(uint64(conn.UID), 10), true
+}
+
+// Prefix returns the prefix of the given length containing addr, using ipv4PrefixLen
+// for IPv4 addresses, including IPv4-mapped IPv6 addresses, and ipv6PrefixLen otherwise.
+func Prefix(addr net.IP, ipv4PrefixLen, ipv6PrefixLen int) *net.IPNet {
+	if ipv4 := addr.To4(); ipv4 != nil {
+		mask := net.CIDRMask(ipv4PrefixLen, 8*net.IPv4len)
+		return &net.IPNet{IP: ipv4.Mask(mask), Mask: mask}
+	}
+
+	mask := net.CIDRMask(ipv6PrefixLen, 8*net.IPv6len)
+	return &net.IPNet{IP: addr.Mask(mask), Mask: mask}
+}
+
+// Group is the aggregate of the connections with a key, as returned by Aggregate.
+type Group struct {
+	Key     string
+	Count   int           // The number of connections
+	ByState map[State]int // The number of connections in each State
+
+	// The summed receive and send queues of the non-listening connections, in bytes.
+	ReceiveQueueBytes, SendQueueBytes uint64
+}
+
+// GroupOrder is the order in which SortGroups sorts Groups.
+type GroupOrder int
+
+const (
+	// GroupOrderCount sorts Groups by their Count, largest first.
+	GroupOrderCount GroupOrder = iota
+
+	// GroupOrderReceiveQueue sorts Groups by their ReceiveQueueBytes, largest first.
+	GroupOrderReceiveQueue
+
+	// GroupOrderSendQueue sorts Groups by their SendQueueBytes, largest first.
+	GroupOrderSendQueue
+)
+
+// Aggregate groups the given connections by key, returning the Groups sorted by GroupOrderCount.
+func Aggregate(conns []*Connection, key GroupKey) []*Group {
+	groupsByKey := make(map[string]*Group)
+	var groups []*Group
+
+	for _, conn := range conns {
+		k, ok := key(conn)
+		if !ok {
+			continue
+		}
+
+		group, ok := groupsByKey[k]
+		if !ok {
+			group = &Group{
+				Key:     k,
+				ByState: make(map[State]int),
+			}
+			groupsByKey[k] = group
+			groups = append(groups, group)
+		}
+
+		group.Count++
+		group.ByState[conn.State]++
+
+		if conn.State != StateListen {
+			group.ReceiveQueueBytes += uint64(conn.ReceiveBufferSize)
+			group.SendQueueBytes += uint64(conn.SendBufferSize)
+		}
+	}
+
+	SortGroups(groups, GroupOrderCount)
+	return groups
+}
+
+// SortGroups sorts the given Groups in the given order. Groups which are equal in that
+// order are sorted by Count, then by Key.
+func SortGroups(groups []*Group, order GroupOrder) {
+	value := func(group *Group) uint64 {
+		switch order {
+		case GroupOrderReceiveQueue:
+			return group.ReceiveQueueBytes
+		case GroupOrderSendQueue:
+			return group.SendQueueBytes
+		default:
+			return uint64(group.Count)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if vi, vj := value(groups[i]), value(groups[j]); vi != vj {
+			return vi > vj
+		}
+
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+
+		return groups[i].Key < groups[j].Key
+	})
+}
+
+// TopGroups returns the first n of the given Groups in the given order, or all of them if
+// n is not positive. The given slice is not modified.
+func TopGroups(groups []*Group, order GroupOrder, n int) []*Group {
+	top := append([]*Group(nil), groups...)
+	SortGroups(top, order)
+
+	if n > 0 && n < len(top) {
+		top = top[:n]
+	}
+
+	return top
+}
@@ -0,0 +1,96 @@
+package tcpconnparser
+
+import (
+	"net"
+	"testing"
+)
+
+// MockAggregateConns returns connections from several remote hosts across two prefixes.
+func mockAggregateConns() []*Connection {
+	return []*Connection{
+		NewListeningConnection(ProtocolVersionIPv4, 3, net.IPv4(0, 0, 0, 0), 80, 0, 1),
+		NewConnection(StateEstablished, ProtocolVersionIPv4, 100, 0,
+			net.IPv4(10, 0, 0, 1), 80, net.IPv4(198, 51, 100, 7), 40000, 33, 2),
+		NewConnection(StateEstablished, ProtocolVersionIPv4, 50, 5,
+			net.IPv4(10, 0, 0, 1), 80, net.IPv4(198, 51, 100, 7), 40001, 33, 3),
+		NewConnection(StateTimeWait, ProtocolVersionIPv4, 0, 0,
+			net.IPv4(10, 0, 0, 1), 80, net.IPv4(198, 51, 100, 8), 40002, 0, 0),
+		NewConnection(StateEstablished, ProtocolVersionIPv6, 0, 2000,
+			net.ParseIP("::ffff:10.0.0.1"), 443, net.ParseIP("::ffff:198.51.100.9"), 40003, 33, 4),
+		NewConnection(StateEstablished, ProtocolVersionIPv6, 1, 1,
+			net.ParseIP("2001:db8::1"), 443, net.ParseIP("2001:db8:0:1::5"), 40004, 1000, 5),
+	}
+}
+
+func TestAggregateByRemoteAddr(t *testing.T) {
+	groups := Aggregate(mockAggregateConns(), GroupByRemoteAddr)
+	if len(groups) != 4 {
+		t.Fatalf("expected 4 groups, got %d", len(groups))
+	}
+
+	group := groups[0]
+	if group.Key != "198.51.100.7" || group.Count != 2 || group.ByState[StateEstablished] != 2 {
+		t.Errorf("expected 2 established connections from 198.51.100.7 first, got %+v", group)
+	}
+
+	if group.ReceiveQueueBytes != 150 || group.SendQueueBytes != 5 {
+		t.Errorf("expected queues of 150 and 5 bytes, got %d and %d", group.ReceiveQueueBytes, group.SendQueueBytes)
+	}
+
+	// Groups with equal counts are ordered by key, with IPv4-mapped addresses as IPv4
+	expected := []string{"198.51.100.7", "198.51.100.8", "198.51.100.9", "2001:db8:0:1::5"}
+	for i := range expected {
+		if groups[i].Key != expected[i] {
+			t.Errorf("expected keys %v, got group %d key %q", expected, i, groups[i].Key)
+		}
+	}
+}
+
+func TestAggregateByRemotePrefix(t *testing.T) {
+	groups := Aggregate(mockAggregateConns(), GroupByRemotePrefix(24, 48))
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+
+	if groups[0].Key != "198.51.100.0/24" || groups[0].Count != 4 || len(groups[0].ByState) != 2 {
+		t.Errorf("expected 4 connections from 198.51.100.0/24 in 2 states, got %+v", groups[0])
+	}
+
+	if groups[1].Key != "2001:db8::/48" || groups[1].Count != 1 {
+		t.Errorf("expected 1 connection from 2001:db8::/48, got %+v", groups[1])
+	}
+}
+
+func TestAggregateByLocalPortAndUID(t *testing.T) {
+	groups := Aggregate(mockAggregateConns(), GroupByLocalPort)
+	if len(groups) != 2 || groups[0].Key != "80" || groups[0].Count != 4 || groups[0].ByState[StateListen] != 1 {
+		t.Fatalf("expected 4 connections on port 80 including the listener first, got %+v", groups)
+	}
+
+	// The accept backlog of the listener is not a receive queue
+	if groups[0].ReceiveQueueBytes != 150 {
+		t.Errorf("expected receive queues of 150 bytes, got %d", groups[0].ReceiveQueueBytes)
+	}
+
+	groups = Aggregate(mockAggregateConns(), GroupByUID)
+	if len(groups) != 3 || groups[0].Key != "33" || groups[0].Count != 3 {
+		t.Errorf("expected 3 connections of UID 33 first, got %+v", groups)
+	}
+}
+
+func TestTopGroups(t *testing.T) {
+	groups := Aggregate(mockAggregateConns(), GroupByRemoteAddr)
+
+	top := TopGroups(groups, GroupOrderSendQueue, 2)
+	if len(top) != 2 || top[0].Key != "198.51.100.9" || top[1].Key != "198.51.100.7" {
+		t.Errorf("expected 198.51.100.9 then 198.51.100.7 by send queue, got %+v", top)
+	}
+
+	if groups[0].Key != "198.51.100.7" {
+		t.Errorf("expected groups to be unmodified, got %+v", groups)
+	}
+
+	if top := TopGroups(groups, GroupOrderReceiveQueue, 0); len(top) != len(groups) || top[0].Key != "198.51.100.7" {
+		t.Errorf("expected all groups with 198.51.100.7 first by receive queue, got %+v", top)
+	}
+}
@@ -0,0 +1,141 @@
+// package analysis implements analysers which diagnose common TCP problems, such as
+// overflowing listen queues and leaked sockets, from successive Samples of the
+// connections and counters of a host.
+//
+// The analysers are heuristic: they report what is likely, with the evidence for it,
+// rather than what is certain, as the procfs files give only periodic views of state
+// which changes continuously.
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Sample is the state of a host at an instant, as observed by the analysers.
+type Sample struct {
+	Time        time.Time
+	Connections []*tcpconnparser.Connection
+
+	// The processes holding each socket open, keyed by inode, as returned by
+	// tcpconnparser.Parser.GetSocketOwners. Nil if not known.
+	Owners map[uint32][]tcpconnparser.Process
+
+	// The network counters of the host. Nil if not known.
+	Counters *tcpconnparser.NetCounters
+}
+
+// TakeSample returns a Sample of the IPv4 and IPv6 connections and the network counters
+// read by parser, as by tcpconnparser.Parser.GetAllConnections. If owners is set, the
+// owning processes of the sockets are also read, which may be slow on busy hosts.
+// If the parser is lenient, the Sample is returned along with the ParseErrors of any
+// lines which were skipped.
+func TakeSample(parser *tcpconnparser.Parser, owners bool) (*Sample, error) {
+	sample := &Sample{
+		Time: time.Now(),
+	}
+
+	conns, connsErr := parser.GetAllConnections()
+	if _, ok := connsErr.(tcpconnparser.ParseErrors); !ok && connsErr != nil {
+		return nil, connsErr
+	}
+
+	sample.Connections = conns
+
+	counters, err := parser.GetNetCounters()
+	if err != nil {
+		return nil, fmt.Errorf("getting network counters: %w", err)
+	}
+
+	sample.Counters = counters
+
+	if owners {
+		if sample.Owners, err = parser.GetSocketOwners(); err != nil {
+			return nil, fmt.Errorf("getting socket owners: %w", err)
+		}
+	}
+
+	// Return any errors from lenient parsing along with the sample
+	return sample, connsErr
+}
+
+// OwnersOf returns the processes holding the socket of the given connection open.
+func (s *Sample) ownersOf(conn *tcpconnparser.Connection) []tcpconnparser.Process {
+	if s.Owners == nil || conn.INode == 0 {
+		return nil
+	}
+
+	return s.Owners[conn.INode]
+}
+
+// Point is a value observed at a time.
+type point struct {
+	time  time.Time
+	value float64
+}
+
+// TrimWindow returns the suffix of points within window of the last point.
+func trimWindow(points []point, window time.Duration) []point {
+	if len(points) == 0 {
+		return points
+	}
+
+	latest := points[len(points)-1].time
+	start := 0
+	for start < len(points)-1 && latest.Sub(points[start].time) > window {
+		start++
+	}
+
+	return points[start:]
+}
+
+// Slope returns the least-squares rate of change per second of the given points,
+// or zero if they span no time.
+func slope(points []point) float64 {
+	if len(points) < 2 {
+		return 0
+	}
+
+	origin := points[0].time
+	var sumX, sumY, sumXY, sumXX float64
+
+	for _, p := range points {
+		x := p.time.Sub(origin).Seconds()
+		sumX += x
+		sumY += p.value
+		sumXY += x * p.value
+		sumXX += x * x
+	}
+
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+
+	return (n*sumXY - sumX*sumY) / denominator
+}
+
+// SocketKey identifies a socket across Samples: by its inode if it has one, otherwise,
+// e.g. for TIME-WAIT sockets, by its addresses and ports.
+type socketKey struct {
+	iNode                 uint32
+	localAddr, remoteAddr string
+	localPort, remotePort uint16
+}
+
+// NewSocketKey returns the socketKey identifying the socket of the given connection.
+func newSocketKey(conn *tcpconnparser.Connection) socketKey {
+	if conn.INode != 0 {
+		return socketKey{iNode: conn.INode}
+	}
+
+	return socketKey{
+		localAddr:  string(conn.LocalAddr.To16()),
+		remoteAddr: string(conn.RemoteAddr.To16()),
+		localPort:  conn.LocalPort,
+		remotePort: conn.RemotePort,
+	}
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"io/fs"
+	"math"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// MockLinkFS is a fstest.MapFS whose files with fs.ModeSymlink are symbolic links to their
+// Data, as read by ReadLink, which fstest.MapFS itself provides only from Go 1.25.
+type mockLinkFS struct {
+	fstest.MapFS
+}
+
+// ReadLink returns the Data of the named symbolic link.
+func (fsys mockLinkFS) ReadLink(name string) (string, error) {
+	file, ok := fsys.MapFS[name]
+	if !ok || file.Mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return string(file.Data), nil
+}
+
+func TestTakeSample(t *testing.T) {
+	mockFS := mockLinkFS{fstest.MapFS{
+		"net/tcp": {Data: []byte(`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0`)},
+		"net/snmp":    {Data: []byte("Tcp: ActiveOpens\nTcp: 42\n")},
+		"net/netstat": {Data: []byte("TcpExt: ListenOverflows\nTcpExt: 7\n")},
+		"1234/comm":   {Data: []byte("server\n")},
+		"1234/fd/3":   {Data: []byte("socket:[789829]"), Mode: fs.ModeSymlink},
+	}}
+
+	sample, err := TakeSample(tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS)), true)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(sample.Connections) != 1 {
+		t.Errorf("expected 1 connection, got %d", len(sample.Connections))
+	}
+
+	if sample.Counters.TCPExt.ListenOverflows != 7 {
+		t.Errorf("expected 7 listen overflows, got %d", sample.Counters.TCPExt.ListenOverflows)
+	}
+
+	if owners := sample.ownersOf(sample.Connections[0]); len(owners) != 1 || owners[0].PID != 1234 {
+		t.Errorf("expected owner with PID 1234, got %v", owners)
+	}
+}
+
+func TestSlope(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		input    []point
+		expected float64
+	}{
+		{nil, 0},
+		{[]point{{start, 5}}, 0},
+		{[]point{{start, 5}, {start, 9}}, 0},
+		{[]point{{start, 0}, {start.Add(time.Second), 2}, {start.Add(2 * time.Second), 4}}, 2},
+		{[]point{{start, 10}, {start.Add(10 * time.Second), 12}, {start.Add(20 * time.Second), 8}}, -0.1},
+	}
+
+	for _, test := range tests {
+		if output := slope(test.input); math.Abs(output-test.expected) > 1e-9 {
+			t.Errorf("expected slope %g, got %g for input %v", test.expected, output, test.input)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// CloseWaitGroup identifies the sockets attributed to an application by the
+// CloseWaitDetector: those of an owning process if known, otherwise those of a local port.
+type CloseWaitGroup struct {
+	Process   tcpconnparser.Process // The owning process, zero if not known
+	LocalPort uint16                // The local port, set only if the owning process is not known
+}
+
+// String returns a human-readable string representation of this CloseWaitGroup.
+func (g CloseWaitGroup) String() string {
+	if g.Process.PID != 0 {
+		return g.Process.String()
+	}
+
+	return "port " + strconv.Itoa(int(g.LocalPort))
+}
+
+// CloseWaitLeak is a group of CLOSE-WAIT sockets suspected of having been leaked by
+// an application which has not closed them after the peer did.
+type CloseWaitLeak struct {
+	Group CloseWaitGroup
+
+	Count int // The number of sockets of the group in CLOSE-WAIT in the latest Sample
+	Stale int // The number of those which have been in CLOSE-WAIT for longer than the threshold
+
+	// The longest time any socket of the group has been seen in CLOSE-WAIT. As sockets
+	// already in CLOSE-WAIT when first observed are taken to have entered it then,
+	// this is a lower bound.
+	OldestAge time.Duration
+
+	// The least-squares rate of change of Count over the window, in sockets per second.
+	// A steadily positive Growth indicates a leak, rather than a burst of slow closes.
+	Growth float64
+
+	// The stale sockets, oldest first.
+	Connections []*tcpconnparser.Connection
+}
+
+// CloseWaitDetector finds sockets which remain in CLOSE-WAIT for longer than a threshold
+// across successive Samples, grouping them by owning process, or by local port if the
+// owners are not known, and tracking the growth of each group.
+type CloseWaitDetector struct {
+	threshold, window time.Duration
+
+	latest    time.Time
+	firstSeen map[socketKey]time.Time
+	current   map[CloseWaitGroup][]*tcpconnparser.Connection
+	history   map[CloseWaitGroup][]point
+}
+
+// NewCloseWaitDetector constructs a new CloseWaitDetector reporting sockets in CLOSE-WAIT
+// for longer than threshold, with growth judged over the Samples of the last window.
+func NewCloseWaitDetector(threshold, window time.Duration) *CloseWaitDetector {
+	return &CloseWaitDetector{
+		threshold: threshold,
+		window:    window,
+		firstSeen: make(map[socketKey]time.Time),
+		history:   make(map[CloseWaitGroup][]point),
+	}
+}
+
+// Observe records the CLOSE-WAIT sockets of the given Sample, which must be later than
+// any previously observed.
+func (d *CloseWaitDetector) Observe(sample *Sample) error {
+	if !d.latest.IsZero() && !sample.Time.After(d.latest) {
+		return fmt.Errorf("sample at %v not after latest sample at %v", sample.Time, d.latest)
+	}
+
+	d.latest = sample.Time
+	d.current = make(map[CloseWaitGroup][]*tcpconnparser.Connection)
+	seen := make(map[socketKey]bool)
+
+	for _, conn := range sample.Connections {
+		if conn.State != tcpconnparser.StateCloseWait {
+			continue
+		}
+
+		key := newSocketKey(conn)
+		seen[key] = true
+		if _, ok := d.firstSeen[key]; !ok {
+			d.firstSeen[key] = sample.Time
+		}
+
+		group := CloseWaitGroup{LocalPort: conn.LocalPort}
+		if owners := sample.ownersOf(conn); len(owners) > 0 {
+			group = CloseWaitGroup{Process: owners[0]}
+		}
+
+		d.current[group] = append(d.current[group], conn)
+	}
+
+	// Forget sockets which have closed
+	for key := range d.firstSeen {
+		if !seen[key] {
+			delete(d.firstSeen, key)
+		}
+	}
+
+	for group := range d.current {
+		if _, ok := d.history[group]; !ok {
+			d.history[group] = nil
+		}
+	}
+
+	for group, points := range d.history {
+		points = trimWindow(append(points, point{sample.Time, float64(len(d.current[group]))}), d.window)
+
+		// Forget groups which have been empty throughout the window
+		empty := true
+		for _, p := range points {
+			empty = empty && p.value == 0
+		}
+
+		if empty {
+			delete(d.history, group)
+		} else {
+			d.history[group] = points
+		}
+	}
+
+	return nil
+}
+
+// Leaks returns the groups with sockets in CLOSE-WAIT for longer than the threshold as of
+// the latest Sample, those with the most stale sockets first.
+func (d *CloseWaitDetector) Leaks() []*CloseWaitLeak {
+	var leaks []*CloseWaitLeak
+
+	for group, conns := range d.current {
+		leak := &CloseWaitLeak{
+			Group:  group,
+			Count:  len(conns),
+			Growth: slope(d.history[group]),
+		}
+
+		for _, conn := range conns {
+			age := d.latest.Sub(d.firstSeen[newSocketKey(conn)])
+			if age <= d.threshold {
+				continue
+			}
+
+			leak.Stale++
+			leak.Connections = append(leak.Connections, conn)
+			if age > leak.OldestAge {
+				leak.OldestAge = age
+			}
+		}
+
+		if leak.Stale == 0 {
+			continue
+		}
+
+		sort.SliceStable(leak.Connections, func(i, j int) bool {
+			return d.firstSeen[newSocketKey(leak.Connections[i])].Before(d.firstSeen[newSocketKey(leak.Connections[j])])
+		})
+
+		leaks = append(leaks, leak)
+	}
+
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].Stale != leaks[j].Stale {
+			return leaks[i].Stale > leaks[j].Stale
+		}
+
+		return leaks[i].Group.String() < leaks[j].Group.String()
+	})
+
+	return leaks
+}
@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// MockCloseWait returns a CLOSE-WAIT connection on the given local port with the given inode.
+func mockCloseWait(localPort uint16, iNode uint32) *tcpconnparser.Connection {
+	return tcpconnparser.NewConnection(tcpconnparser.StateCloseWait, tcpconnparser.ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), localPort, net.IPv4(10, 0, 0, 2), uint16(40000+iNode), 0, iNode)
+}
+
+func TestCloseWaitDetector(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	leaker := tcpconnparser.Process{PID: 1234, Command: "leaky"}
+	detector := NewCloseWaitDetector(2*time.Minute, 10*time.Minute)
+
+	// The leaky process gains a CLOSE-WAIT socket each minute which it never closes,
+	// while a socket on port 8080 of an unknown process closes after a minute
+	var leaked []*tcpconnparser.Connection
+	owners := make(map[uint32][]tcpconnparser.Process)
+
+	for minute := 0; minute < 5; minute++ {
+		iNode := uint32(100 + minute)
+		leaked = append(leaked, mockCloseWait(80, iNode))
+		owners[iNode] = []tcpconnparser.Process{leaker}
+
+		conns := append([]*tcpconnparser.Connection(nil), leaked...)
+		if minute < 2 {
+			conns = append(conns, mockCloseWait(8080, 1))
+		}
+
+		err := detector.Observe(&Sample{
+			Time:        start.Add(time.Duration(minute) * time.Minute),
+			Connections: conns,
+			Owners:      owners,
+		})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+	}
+
+	leaks := detector.Leaks()
+	if len(leaks) != 1 {
+		t.Fatalf("expected 1 leak, got %d", len(leaks))
+	}
+
+	leak := leaks[0]
+	if leak.Group.Process != leaker || leak.Group.String() != "leaky (1234)" {
+		t.Errorf("expected group of process %v, got %v", leaker, leak.Group)
+	}
+
+	// The sockets first seen at minutes 0 and 1 are older than the threshold at minute 4
+	if leak.Count != 5 || leak.Stale != 2 || leak.OldestAge != 4*time.Minute {
+		t.Errorf("expected 5 sockets, 2 stale, oldest 4m, got %d, %d, %v", leak.Count, leak.Stale, leak.OldestAge)
+	}
+
+	if len(leak.Connections) != 2 || leak.Connections[0] != leaked[0] {
+		t.Errorf("expected oldest stale socket first, got %v", leak.Connections)
+	}
+
+	if expected := 1.0 / 60; leak.Growth < expected*0.99 || leak.Growth > expected*1.01 {
+		t.Errorf("expected growth of %g sockets per second, got %g", expected, leak.Growth)
+	}
+}
+
+func TestCloseWaitDetectorGroupsByPort(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	detector := NewCloseWaitDetector(time.Minute, 10*time.Minute)
+
+	for minute := 0; minute < 3; minute++ {
+		detector.Observe(&Sample{
+			Time:        start.Add(time.Duration(minute) * time.Minute),
+			Connections: []*tcpconnparser.Connection{mockCloseWait(8080, 1), mockCloseWait(8080, 2)},
+		})
+	}
+
+	leaks := detector.Leaks()
+	if len(leaks) != 1 || leaks[0].Group.String() != "port 8080" || leaks[0].Stale != 2 {
+		t.Fatalf("expected 2 stale sockets on port 8080, got %+v", leaks)
+	}
+
+	if leaks[0].Growth != 0 {
+		t.Errorf("expected no growth, got %g", leaks[0].Growth)
+	}
+}
+
+func TestCloseWaitDetectorOutOfOrderError(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	detector := NewCloseWaitDetector(time.Minute, 10*time.Minute)
+
+	detector.Observe(&Sample{Time: start})
+	err := detector.Observe(&Sample{Time: start})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
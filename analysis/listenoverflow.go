@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// SaturatedListener is a listener suspected of overflowing its accept queue.
+type SaturatedListener struct {
+	Listener *tcpconnparser.Connection // The listener, as seen in the later Sample
+	Owners   []tcpconnparser.Process   // The processes holding the listener open, if known
+
+	// The deepest accept queue of the listener seen in either Sample.
+	AcceptQueue uint32
+
+	// The fraction of the maximum accept backlog filled by AcceptQueue, from 0 to 1.
+	// As the backlog requested by the listener is not known, it is compared with the
+	// system-wide limit, so a listener with a smaller backlog may be full at less than 1.
+	Fill float64
+
+	// Confidence, from 0 to 1, that this listener caused the overflows, combining its
+	// Fill with its share of the accept queues of all the listeners with queued connections.
+	Confidence float64
+}
+
+// ListenOverflowReport is the result of AnalyseListenOverflows.
+type ListenOverflowReport struct {
+	Interval time.Duration // The time between the Samples
+
+	// The increases of the ListenOverflows and ListenDrops counters between the Samples.
+	// ListenDrops counts connections dropped by listeners for any reason, including overflows.
+	Overflows, Drops int64
+
+	// The listeners suspected of overflowing, most likely first. Empty if there were no
+	// overflows or drops, or if no listener had queued connections in either Sample, as
+	// happens when an overflowing queue drains between Samples.
+	Listeners []*SaturatedListener
+}
+
+// AnalyseListenOverflows attributes the increase of the ListenOverflows and ListenDrops
+// counters between the prev and cur Samples to the listeners most likely responsible,
+// judged by the depth of their accept queues in either Sample against maxAcceptBacklog,
+// as returned by tcpconnparser.Parser.GetMaxAcceptBacklog.
+//
+// Both Samples must have Counters. Listeners are matched between the Samples by inode.
+func AnalyseListenOverflows(prev, cur *Sample, maxAcceptBacklog uint32) (*ListenOverflowReport, error) {
+	if prev.Counters == nil || cur.Counters == nil {
+		return nil, errors.New("samples have no network counters")
+	}
+
+	deltas := tcpconnparser.CounterDeltas(prev.Counters, cur.Counters)["TcpExt"]
+	report := &ListenOverflowReport{
+		Interval:  cur.Time.Sub(prev.Time),
+		Overflows: deltas["ListenOverflows"],
+		Drops:     deltas["ListenDrops"],
+	}
+
+	if report.Overflows == 0 && report.Drops == 0 {
+		return report, nil
+	}
+
+	prevDepths := make(map[uint32]uint32)
+	for _, conn := range prev.Connections {
+		if conn.State == tcpconnparser.StateListen && conn.INode != 0 {
+			prevDepths[conn.INode] = conn.AcceptBacklog
+		}
+	}
+
+	var totalDepth uint64
+	for _, conn := range cur.Connections {
+		if conn.State != tcpconnparser.StateListen {
+			continue
+		}
+
+		depth := conn.AcceptBacklog
+		if prevDepth := prevDepths[conn.INode]; conn.INode != 0 && prevDepth > depth {
+			depth = prevDepth
+		}
+
+		if depth == 0 {
+			continue
+		}
+
+		listener := &SaturatedListener{
+			Listener:    conn,
+			Owners:      cur.ownersOf(conn),
+			AcceptQueue: depth,
+		}
+
+		if maxAcceptBacklog > 0 {
+			listener.Fill = float64(depth) / float64(maxAcceptBacklog)
+			if listener.Fill > 1 {
+				listener.Fill = 1
+			}
+		}
+
+		report.Listeners = append(report.Listeners, listener)
+		totalDepth += uint64(depth)
+	}
+
+	for _, listener := range report.Listeners {
+		share := float64(listener.AcceptQueue) / float64(totalDepth)
+		listener.Confidence = 1 - (1-share)*(1-listener.Fill)
+	}
+
+	sort.SliceStable(report.Listeners, func(i, j int) bool {
+		return report.Listeners[i].Confidence > report.Listeners[j].Confidence
+	})
+
+	return report, nil
+}
@@ -0,0 +1,104 @@
+package analysis
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// MockCounters returns NetCounters at the given time with the given listen counters.
+func mockCounters(at time.Time, overflows, drops int64) *tcpconnparser.NetCounters {
+	return &tcpconnparser.NetCounters{
+		Time: at,
+		Counters: map[string]map[string]int64{
+			"TcpExt": {"ListenOverflows": overflows, "ListenDrops": drops},
+		},
+	}
+}
+
+func TestAnalyseListenOverflows(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	full := tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv4,
+		128, net.IPv4(0, 0, 0, 0), 80, 0, 1)
+	drained := tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv4,
+		0, net.IPv4(0, 0, 0, 0), 443, 0, 2)
+	previouslyQueued := *drained
+	previouslyQueued.AcceptBacklog = 16
+	idle := tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv6,
+		0, net.IPv6unspecified, 22, 0, 3)
+
+	prev := &Sample{
+		Time:        start,
+		Connections: []*tcpconnparser.Connection{full, &previouslyQueued, idle},
+		Counters:    mockCounters(start, 100, 110),
+	}
+	cur := &Sample{
+		Time:        start.Add(10 * time.Second),
+		Connections: []*tcpconnparser.Connection{full, drained, idle},
+		Owners:      map[uint32][]tcpconnparser.Process{1: {{PID: 1234, Command: "nginx"}}},
+		Counters:    mockCounters(start.Add(10*time.Second), 150, 170),
+	}
+
+	report, err := AnalyseListenOverflows(prev, cur, 128)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if report.Overflows != 50 || report.Drops != 60 || report.Interval != 10*time.Second {
+		t.Errorf("expected 50 overflows and 60 drops in 10s, got %d and %d in %v",
+			report.Overflows, report.Drops, report.Interval)
+	}
+
+	if len(report.Listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(report.Listeners))
+	}
+
+	first, second := report.Listeners[0], report.Listeners[1]
+	if first.Listener != full || first.Confidence != 1 || first.Fill != 1 {
+		t.Errorf("expected full listener first with confidence 1, got %v with confidence %g", first.Listener, first.Confidence)
+	}
+
+	if len(first.Owners) != 1 || first.Owners[0].Command != "nginx" {
+		t.Errorf("expected owner nginx, got %v", first.Owners)
+	}
+
+	if second.Listener != drained || second.AcceptQueue != 16 {
+		t.Errorf("expected drained listener second with queue 16, got %v with queue %d", second.Listener, second.AcceptQueue)
+	}
+
+	if second.Confidence <= 0 || second.Confidence >= first.Confidence {
+		t.Errorf("expected confidence between 0 and %g, got %g", first.Confidence, second.Confidence)
+	}
+}
+
+func TestAnalyseListenOverflowsNone(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	conns := []*tcpconnparser.Connection{
+		tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv4,
+			128, net.IPv4(0, 0, 0, 0), 80, 0, 1),
+	}
+
+	prev := &Sample{Time: start, Connections: conns, Counters: mockCounters(start, 100, 110)}
+	cur := &Sample{Time: start.Add(time.Second), Connections: conns, Counters: mockCounters(start, 100, 110)}
+
+	report, err := AnalyseListenOverflows(prev, cur, 128)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(report.Listeners) != 0 {
+		t.Errorf("expected no listeners without overflows, got %d", len(report.Listeners))
+	}
+}
+
+func TestAnalyseListenOverflowsNoCountersError(t *testing.T) {
+	_, err := AnalyseListenOverflows(&Sample{}, &Sample{}, 128)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,396 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Sysctls giving the ports the kernel assigns to outbound connections. Although named
+// for IPv4, they apply to IPv6 too.
+const (
+	sysctlLocalPortRange     = "net.ipv4.ip_local_port_range"
+	sysctlLocalReservedPorts = "net.ipv4.ip_local_reserved_ports"
+)
+
+// TimeWaitDuration is the time a socket remains in TIME-WAIT, holding its local port,
+// after it is actively closed, as given by TCP_TIMEWAIT_LEN in kernel <net/tcp.h>.
+const timeWaitDuration = 60 * time.Second
+
+// EphemeralPorts is the set of local ports assigned by the kernel to outbound connections
+// which have not been bound to a port: the range, less the reserved ports.
+type EphemeralPorts struct {
+	Low, High uint16
+	Reserved  map[uint16]bool
+}
+
+// ReadEphemeralPorts returns the EphemeralPorts read by parser from the
+// net.ipv4.ip_local_port_range and net.ipv4.ip_local_reserved_ports sysctls.
+// No ports are taken to be reserved on kernels without the latter.
+func ReadEphemeralPorts(parser *tcpconnparser.Parser) (*EphemeralPorts, error) {
+	value, err := parser.ReadSysctl(sysctlLocalPortRange)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := new(EphemeralPorts)
+	if ports.Low, ports.High, err = parsePortRange(value); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", sysctlLocalPortRange, err)
+	}
+
+	value, err = parser.ReadSysctl(sysctlLocalReservedPorts)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ports, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ports.Reserved, err = parseReservedPorts(value); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", sysctlLocalReservedPorts, err)
+	}
+
+	return ports, nil
+}
+
+// ParsePortRange parses a port range formatted as its low and high ports separated by
+// whitespace, e.g. "32768	60999".
+func parsePortRange(str string) (low, high uint16, err error) {
+	fields := strings.Fields(str)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid port range: %q", str)
+	}
+
+	if low, err = parsePort(fields[0]); err != nil {
+		return 0, 0, err
+	}
+
+	if high, err = parsePort(fields[1]); err != nil {
+		return 0, 0, err
+	}
+
+	if low > high {
+		return 0, 0, fmt.Errorf("invalid port range: %q", str)
+	}
+
+	return low, high, nil
+}
+
+// ParseReservedPorts parses a comma-separated list of ports and inclusive port ranges,
+// e.g. "8080,9000-9010", into a set. An empty list is parsed as an empty set.
+func parseReservedPorts(str string) (map[uint16]bool, error) {
+	reserved := make(map[uint16]bool)
+	if str == "" {
+		return reserved, nil
+	}
+
+	for _, item := range strings.Split(str, ",") {
+		lowStr, highStr, isRange := strings.Cut(item, "-")
+		if !isRange {
+			highStr = lowStr
+		}
+
+		low, err := parsePort(lowStr)
+		if err != nil {
+			return nil, err
+		}
+
+		high, err := parsePort(highStr)
+		if err != nil {
+			return nil, err
+		}
+
+		if low > high {
+			return nil, fmt.Errorf("invalid port range: %q", item)
+		}
+
+		for port := int(low); port <= int(high); port++ {
+			reserved[uint16(port)] = true
+		}
+	}
+
+	return reserved, nil
+}
+
+// ParsePort parses the given decimal string as a port.
+func parsePort(str string) (uint16, error) {
+	port, err := strconv.ParseUint(strings.TrimSpace(str), 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse port %q as integer: %w", str, err)
+	}
+
+	return uint16(port), nil
+}
+
+// Contains returns whether the kernel may assign the given port to an outbound connection.
+func (p *EphemeralPorts) Contains(port uint16) bool {
+	return port >= p.Low && port <= p.High && !p.Reserved[port]
+}
+
+// Count returns the number of ports the kernel may assign to outbound connections.
+func (p *EphemeralPorts) Count() int {
+	count := int(p.High) - int(p.Low) + 1
+	for port := range p.Reserved {
+		if port >= p.Low && port <= p.High {
+			count--
+		}
+	}
+
+	return count
+}
+
+// Destination is the local address and remote endpoint shared by a set of outbound
+// connections. As a local port may be reused by connections to different remote endpoints,
+// the EphemeralPorts are exhausted independently for each Destination.
+type Destination struct {
+	LocalAddr  net.IP
+	RemoteAddr net.IP
+	RemotePort uint16
+}
+
+// String returns a human-readable string representation of this Destination.
+func (d Destination) String() string {
+	return d.LocalAddr.String() + " -> " + net.JoinHostPort(d.RemoteAddr.String(), strconv.Itoa(int(d.RemotePort)))
+}
+
+// DestinationKey identifies a Destination in a map, treating IPv4 and IPv4-mapped IPv6
+// addresses alike.
+type destinationKey struct {
+	localAddr, remoteAddr string
+	remotePort            uint16
+}
+
+// NewDestinationKey returns the destinationKey of the given connection.
+func newDestinationKey(conn *tcpconnparser.Connection) destinationKey {
+	return destinationKey{
+		localAddr:  string(conn.LocalAddr.To16()),
+		remoteAddr: string(conn.RemoteAddr.To16()),
+		remotePort: conn.RemotePort,
+	}
+}
+
+// PortUsage is the use of the EphemeralPorts by the connections to a Destination.
+type PortUsage struct {
+	Destination Destination
+
+	InUse       int     // The number of local ports in use in the latest Sample, including by TIME-WAIT sockets
+	TimeWait    int     // The number of those held by TIME-WAIT sockets
+	Available   int     // The number of EphemeralPorts
+	Utilisation float64 // InUse as a fraction of Available
+
+	// The rate at which new local ports were used over the window, in ports per second.
+	// Ports used and released between Samples are missed, so this is a lower bound.
+	Churn float64
+
+	// The least-squares rate of change of InUse over the window, in ports per second.
+	Growth float64
+
+	// The number of ports expected to be in use once the TIME-WAIT sockets reach a steady
+	// state at the current Churn: those not in TIME-WAIT, plus the Churn over the time
+	// a socket remains in TIME-WAIT. Exceeding Available forecasts exhaustion even if InUse
+	// has not yet grown.
+	Forecast float64
+
+	// The time until InUse reaches Available at the current Growth. Zero if InUse is
+	// not growing, or the ports are already exhausted.
+	TimeToExhaustion time.Duration
+}
+
+// destinationUsage is the use of ports by the connections to a Destination in a Sample.
+type destinationUsage struct {
+	destination Destination
+	ports       map[uint16]bool
+	timeWait    int
+}
+
+// PortExhaustionAnalyser tracks the local ports in use by outbound connections, including
+// those held by TIME-WAIT sockets, for each Destination across successive Samples,
+// forecasting the exhaustion of the EphemeralPorts from their growth and churn.
+//
+// Connections are taken to be outbound if their local port is one of the EphemeralPorts.
+// Inbound connections to a service listening on such a port are counted too, but as each
+// has a distinct remote endpoint, they do not appear close to exhaustion.
+type PortExhaustionAnalyser struct {
+	ports  *EphemeralPorts
+	window time.Duration
+
+	times   []time.Time
+	current map[destinationKey]*destinationUsage
+	history map[destinationKey][]point // InUse at each Sample
+	churn   map[destinationKey][]point // New ports at each Sample
+}
+
+// NewPortExhaustionAnalyser constructs a new PortExhaustionAnalyser of the use of the given
+// EphemeralPorts, with growth and churn judged over the Samples of the last window.
+func NewPortExhaustionAnalyser(ports *EphemeralPorts, window time.Duration) *PortExhaustionAnalyser {
+	return &PortExhaustionAnalyser{
+		ports:   ports,
+		window:  window,
+		current: make(map[destinationKey]*destinationUsage),
+		history: make(map[destinationKey][]point),
+		churn:   make(map[destinationKey][]point),
+	}
+}
+
+// Observe records the ports in use by the outbound connections of the given Sample,
+// which must be later than any previously observed.
+func (a *PortExhaustionAnalyser) Observe(sample *Sample) error {
+	if len(a.times) > 0 {
+		if latest := a.times[len(a.times)-1]; !sample.Time.After(latest) {
+			return fmt.Errorf("sample at %v not after latest sample at %v", sample.Time, latest)
+		}
+	}
+
+	previous := a.current
+	a.current = make(map[destinationKey]*destinationUsage)
+
+	for _, conn := range sample.Connections {
+		if conn.State == tcpconnparser.StateListen || conn.RemoteAddr == nil || !a.ports.Contains(conn.LocalPort) {
+			continue
+		}
+
+		key := newDestinationKey(conn)
+		usage, ok := a.current[key]
+		if !ok {
+			usage = &destinationUsage{
+				destination: Destination{conn.LocalAddr, conn.RemoteAddr, conn.RemotePort},
+				ports:       make(map[uint16]bool),
+			}
+			a.current[key] = usage
+		}
+
+		usage.ports[conn.LocalPort] = true
+		if conn.State == tcpconnparser.StateTimeWait {
+			usage.timeWait++
+		}
+	}
+
+	// Ports are only known to be new if there was a previous Sample to compare against
+	if len(a.times) > 0 {
+		for key, usage := range a.current {
+			var added int
+			for port := range usage.ports {
+				if previousUsage, ok := previous[key]; !ok || !previousUsage.ports[port] {
+					added++
+				}
+			}
+
+			a.churn[key] = append(a.churn[key], point{sample.Time, float64(added)})
+		}
+	}
+
+	for key := range a.current {
+		if _, ok := a.history[key]; !ok {
+			a.history[key] = nil
+		}
+	}
+
+	a.times = trimTimes(append(a.times, sample.Time), a.window)
+
+	for key, points := range a.history {
+		var inUse int
+		if usage, ok := a.current[key]; ok {
+			inUse = len(usage.ports)
+		}
+
+		points = trimWindow(append(points, point{sample.Time, float64(inUse)}), a.window)
+
+		// Forget destinations which have used no ports throughout the window
+		empty := true
+		for _, p := range points {
+			empty = empty && p.value == 0
+		}
+
+		if empty {
+			delete(a.history, key)
+			delete(a.churn, key)
+		} else {
+			a.history[key] = points
+			a.churn[key] = trimWindow(a.churn[key], a.window)
+		}
+	}
+
+	return nil
+}
+
+// TrimTimes returns the suffix of times within window of the last time.
+func trimTimes(times []time.Time, window time.Duration) []time.Time {
+	start := 0
+	for start < len(times)-1 && times[len(times)-1].Sub(times[start]) > window {
+		start++
+	}
+
+	return times[start:]
+}
+
+// Report returns the PortUsage of each Destination with ports in use as of the latest
+// Sample, those closest to exhaustion first.
+func (a *PortExhaustionAnalyser) Report() []*PortUsage {
+	available := a.ports.Count()
+	usages := make([]*PortUsage, 0, len(a.current))
+
+	for key, current := range a.current {
+		usage := &PortUsage{
+			Destination: current.destination,
+			InUse:       len(current.ports),
+			TimeWait:    current.timeWait,
+			Available:   available,
+			Churn:       a.churnRate(a.churn[key]),
+			Growth:      slope(a.history[key]),
+		}
+
+		if available > 0 {
+			usage.Utilisation = float64(usage.InUse) / float64(available)
+		}
+
+		usage.Forecast = float64(usage.InUse-usage.TimeWait) + usage.Churn*timeWaitDuration.Seconds()
+
+		if remaining := available - usage.InUse; usage.Growth > 0 && remaining > 0 {
+			usage.TimeToExhaustion = time.Duration(float64(remaining) / usage.Growth * float64(time.Second))
+		}
+
+		usages = append(usages, usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Utilisation != usages[j].Utilisation {
+			return usages[i].Utilisation > usages[j].Utilisation
+		}
+
+		if usages[i].Forecast != usages[j].Forecast {
+			return usages[i].Forecast > usages[j].Forecast
+		}
+
+		return usages[i].Destination.String() < usages[j].Destination.String()
+	})
+
+	return usages
+}
+
+// ChurnRate returns the rate per second of the new ports counted by the given points over
+// the window. The new ports of a point observed at the start of the window were used
+// before it, so are excluded.
+func (a *PortExhaustionAnalyser) churnRate(points []point) float64 {
+	if len(a.times) < 2 {
+		return 0
+	}
+
+	start, end := a.times[0], a.times[len(a.times)-1]
+
+	var added float64
+	for _, p := range points {
+		if p.time.After(start) {
+			added += p.value
+		}
+	}
+
+	return added / end.Sub(start).Seconds()
+}
@@ -0,0 +1,181 @@
+package analysis
+
+import (
+	"math"
+	"net"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+func TestReadEphemeralPorts(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"sys/net/ipv4/ip_local_port_range":     {Data: []byte("32768\t60999\n")},
+		"sys/net/ipv4/ip_local_reserved_ports": {Data: []byte("8080,32768,40000-40009\n")},
+	}
+
+	ports, err := ReadEphemeralPorts(tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS)))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if ports.Low != 32768 || ports.High != 60999 {
+		t.Errorf("expected range 32768-60999, got %d-%d", ports.Low, ports.High)
+	}
+
+	// Port 8080 is reserved, but is outside the range anyway
+	if count := ports.Count(); count != 60999-32768+1-11 {
+		t.Errorf("expected %d ports, got %d", 60999-32768+1-11, count)
+	}
+
+	for port, expected := range map[uint16]bool{8080: false, 32768: false, 32769: true, 40005: false, 60999: true, 61000: false} {
+		if contains := ports.Contains(port); contains != expected {
+			t.Errorf("expected Contains(%d) to be %t, got %t", port, expected, contains)
+		}
+	}
+}
+
+func TestReadEphemeralPortsNoReservedPorts(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"sys/net/ipv4/ip_local_port_range":     {Data: []byte("1024 1033\n")},
+		"sys/net/ipv4/ip_local_reserved_ports": {Data: []byte("\n")},
+	}
+
+	ports, err := ReadEphemeralPorts(tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS)))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if count := ports.Count(); count != 10 {
+		t.Errorf("expected 10 ports, got %d", count)
+	}
+}
+
+func TestReadEphemeralPortsInvalidRangeError(t *testing.T) {
+	for _, value := range []string{"32768", "60999 32768", "32768 65536"} {
+		mockFS := fstest.MapFS{
+			"sys/net/ipv4/ip_local_port_range": {Data: []byte(value)},
+		}
+
+		_, err := ReadEphemeralPorts(tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS)))
+		if err == nil {
+			t.Errorf("expected error for %q, got nil", value)
+		}
+
+		t.Logf("got error %q (of type %T)", err, err)
+	}
+}
+
+// MockOutbound returns an outbound connection in the given state from the given local port
+// to 198.51.100.7:443.
+func mockOutbound(state tcpconnparser.State, localPort uint16) *tcpconnparser.Connection {
+	var iNode uint32
+	if state != tcpconnparser.StateTimeWait {
+		iNode = uint32(localPort)
+	}
+
+	return tcpconnparser.NewConnection(state, tcpconnparser.ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), localPort, net.IPv4(198, 51, 100, 7), 443, 0, iNode)
+}
+
+func TestPortExhaustionAnalyser(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	ports := &EphemeralPorts{Low: 32768, High: 32867, Reserved: map[uint16]bool{32800: true}}
+	analyser := NewPortExhaustionAnalyser(ports, time.Minute)
+
+	// Each 10 seconds, the proxy opens 2 connections and closes them by the next Sample,
+	// leaving them in TIME-WAIT, while holding open a single long-lived connection
+	var timeWait []*tcpconnparser.Connection
+	nextPort := uint16(32769)
+
+	for i := 0; i < 4; i++ {
+		conns := []*tcpconnparser.Connection{
+			mockOutbound(tcpconnparser.StateEstablished, 32768),
+			tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv4, 0, net.IPv4zero, 32850, 0, 1),
+			tcpconnparser.NewConnection(tcpconnparser.StateEstablished, tcpconnparser.ProtocolVersionIPv4, 0, 0,
+				net.IPv4(10, 0, 0, 1), 22, net.IPv4(198, 51, 100, 9), 50000, 0, 2),
+		}
+
+		conns = append(conns, timeWait...)
+		for j := 0; j < 2; j++ {
+			timeWait = append(timeWait, mockOutbound(tcpconnparser.StateTimeWait, nextPort))
+			conns = append(conns, mockOutbound(tcpconnparser.StateEstablished, nextPort))
+			nextPort++
+		}
+
+		err := analyser.Observe(&Sample{
+			Time:        start.Add(time.Duration(i) * 10 * time.Second),
+			Connections: conns,
+		})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+	}
+
+	usages := analyser.Report()
+	if len(usages) != 1 {
+		t.Fatalf("expected 1 destination, got %d", len(usages))
+	}
+
+	usage := usages[0]
+	if str := usage.Destination.String(); str != "10.0.0.1 -> 198.51.100.7:443" {
+		t.Errorf("expected destination 10.0.0.1 -> 198.51.100.7:443, got %s", str)
+	}
+
+	if usage.InUse != 9 || usage.TimeWait != 6 || usage.Available != 99 {
+		t.Errorf("expected 9 of 99 ports in use, 6 in TIME-WAIT, got %d of %d, %d", usage.InUse, usage.Available, usage.TimeWait)
+	}
+
+	if math.Abs(usage.Utilisation-9.0/99) > 1e-9 {
+		t.Errorf("expected utilisation of %g, got %g", 9.0/99, usage.Utilisation)
+	}
+
+	// 2 new ports each 10 seconds, forecasting 3 ports not in TIME-WAIT plus 60 seconds of churn
+	if math.Abs(usage.Churn-0.2) > 1e-9 || math.Abs(usage.Forecast-15) > 1e-9 {
+		t.Errorf("expected churn of 0.2 per second and forecast of 15, got %g and %g", usage.Churn, usage.Forecast)
+	}
+
+	if math.Abs(usage.Growth-0.2) > 1e-9 || usage.TimeToExhaustion != 450*time.Second {
+		t.Errorf("expected growth of 0.2 per second and exhaustion in 450s, got %g and %v", usage.Growth, usage.TimeToExhaustion)
+	}
+}
+
+func TestPortExhaustionAnalyserForgetsDestinations(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	analyser := NewPortExhaustionAnalyser(&EphemeralPorts{Low: 32768, High: 60999}, time.Minute)
+
+	analyser.Observe(&Sample{
+		Time:        start,
+		Connections: []*tcpconnparser.Connection{mockOutbound(tcpconnparser.StateEstablished, 40000)},
+	})
+
+	for i := 1; i <= 7; i++ {
+		analyser.Observe(&Sample{Time: start.Add(time.Duration(i) * 10 * time.Second)})
+	}
+
+	if usages := analyser.Report(); len(usages) != 0 {
+		t.Errorf("expected no destinations, got %d", len(usages))
+	}
+
+	if len(analyser.history) != 0 || len(analyser.churn) != 0 {
+		t.Errorf("expected destination to be forgotten, got history %v and churn %v", analyser.history, analyser.churn)
+	}
+}
+
+func TestPortExhaustionAnalyserOutOfOrderError(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	analyser := NewPortExhaustionAnalyser(&EphemeralPorts{Low: 32768, High: 60999}, time.Minute)
+
+	if err := analyser.Observe(&Sample{Time: start}); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	err := analyser.Observe(&Sample{Time: start})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,458 @@
+package analysis
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Sysctls controlling the handling of SYNs by listeners.
+const (
+	sysctlSynCookies    = "net.ipv4.tcp_syncookies"
+	sysctlMaxSynBacklog = "net.ipv4.tcp_max_syn_backlog"
+)
+
+// Values of the net.ipv4.tcp_syncookies sysctl.
+const (
+	SynCookiesDisabled = 0 // SYN cookies are never sent
+	SynCookiesOnFull   = 1 // SYN cookies are sent when the SYN queue of a listener is full
+	SynCookiesAlways   = 2 // SYN cookies are always sent
+)
+
+// SynSettings are the sysctls bounding the SYN queues of listeners, which hold their
+// half-open, i.e. SYN-RECEIVED, connections.
+type SynSettings struct {
+	SynCookies       int    // net.ipv4.tcp_syncookies
+	MaxSynBacklog    uint32 // net.ipv4.tcp_max_syn_backlog
+	MaxAcceptBacklog uint32 // net.core.somaxconn
+}
+
+// ReadSynSettings returns the SynSettings read by parser.
+func ReadSynSettings(parser *tcpconnparser.Parser) (*SynSettings, error) {
+	settings := new(SynSettings)
+
+	value, err := parser.ReadSysctl(sysctlSynCookies)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.SynCookies, err = strconv.Atoi(value); err != nil {
+		return nil, fmt.Errorf("unable to parse %s %q as integer: %w", sysctlSynCookies, value, err)
+	}
+
+	value, err = parser.ReadSysctl(sysctlMaxSynBacklog)
+	if err != nil {
+		return nil, err
+	}
+
+	maxSynBacklog, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s %q as integer: %w", sysctlMaxSynBacklog, value, err)
+	}
+
+	settings.MaxSynBacklog = uint32(maxSynBacklog)
+
+	if settings.MaxAcceptBacklog, err = parser.GetMaxAcceptBacklog(); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// SynQueueLimit returns the upper bound on the number of half-open connections held by
+// a listener. The SYN queue of a listener is full when it holds as many as its accept
+// backlog, which is not known but is bounded by net.core.somaxconn. Without SYN cookies,
+// SYNs are also dropped once the queue is three quarters of net.ipv4.tcp_max_syn_backlog.
+func (s *SynSettings) SynQueueLimit() uint32 {
+	limit := s.MaxAcceptBacklog
+	if s.SynCookies == SynCookiesDisabled {
+		if synLimit := s.MaxSynBacklog - s.MaxSynBacklog/4; synLimit < limit {
+			limit = synLimit
+		}
+	}
+
+	return limit
+}
+
+// SynAlert is an alert raised by a SynFloodDetector: a *SynFloodAlert or a *SynScanAlert.
+type SynAlert interface {
+	fmt.Stringer
+	synAlert()
+}
+
+// SourceCount is the number of half-open connections from a source prefix.
+type SourceCount struct {
+	Prefix *net.IPNet
+	Count  int
+}
+
+// SynFloodAlert is raised for a local port whose listeners have filled their SYN queues
+// with half-open connections, as happens when flooded with SYNs which are never completed.
+type SynFloodAlert struct {
+	LocalPort uint16
+	Listeners []*tcpconnparser.Connection // The listeners on the port, empty if none were seen
+	Owners    []tcpconnparser.Process     // The processes holding the listeners open, if known
+
+	HalfOpen int     // The number of half-open connections to the port in the latest Sample
+	Limit    uint32  // The summed SynQueueLimit of the listeners
+	Fill     float64 // HalfOpen as a fraction of Limit, from 0 to 1
+
+	// The least-squares rate of change of HalfOpen over the window, in connections per second.
+	Growth float64
+
+	// The source prefixes of the half-open connections, most first, up to maxSynFloodSources.
+	// A flood spread thinly across many prefixes suggests spoofed source addresses.
+	Sources []SourceCount
+
+	// The number of distinct source prefixes of the half-open connections.
+	SourcePrefixes int
+
+	// The increase of the SyncookiesSent counter over the latest interval, for all
+	// listeners, or zero if the Samples have no Counters. As SYNs answered with cookies
+	// create no half-open connections, a flood met with cookies is seen here, while
+	// HalfOpen remains at the Limit.
+	SyncookiesSent int64
+}
+
+// String returns a human-readable string representation of this SynFloodAlert.
+func (a *SynFloodAlert) String() string {
+	return fmt.Sprintf("SYN flood on port %d: %d half-open of limit %d (%.0f%%) from %d source prefixes",
+		a.LocalPort, a.HalfOpen, a.Limit, 100*a.Fill, a.SourcePrefixes)
+}
+
+func (a *SynFloodAlert) synAlert() {}
+
+// SynScanAlert is raised for a source prefix with half-open connections to many local
+// ports over the window, as happens when it is scanning for open ports with SYNs.
+// Ports without listeners answer SYNs with resets, so only the open ports are seen.
+type SynScanAlert struct {
+	Source   *net.IPNet
+	Ports    []uint16 // The local ports with half-open connections from Source over the window, ascending
+	HalfOpen int      // The number of half-open connections from Source in the latest Sample
+}
+
+// String returns a human-readable string representation of this SynScanAlert.
+func (a *SynScanAlert) String() string {
+	return fmt.Sprintf("SYN scan from %s: %d ports", a.Source, len(a.Ports))
+}
+
+func (a *SynScanAlert) synAlert() {}
+
+// Maximum number of Sources of a SynFloodAlert.
+const maxSynFloodSources = 5
+
+// Defaults of the fields of SynFloodConfig.
+const (
+	DefaultSynFloodWindow   = time.Minute
+	DefaultSynFloodFill     = 0.5
+	DefaultSynFloodHalfOpen = 16
+	DefaultSynScanPorts     = 10
+)
+
+// SynFloodConfig configures a SynFloodDetector. The zero value of each field selects a default.
+type SynFloodConfig struct {
+	// The time over which growth is judged and the ports of scans are accumulated.
+	Window time.Duration
+
+	// The Fill and number of half-open connections at or above which a SynFloodAlert is raised.
+	FloodFill     float64
+	FloodHalfOpen int
+
+	// The number of local ports at or above which a SynScanAlert is raised.
+	ScanPorts int
+
+	// The prefix lengths to which source addresses are aggregated.
+	IPv4PrefixLen int
+	IPv6PrefixLen int
+}
+
+// SynFloodDetector watches the half-open connections of successive Samples, by the local
+// port of their listener and by their source prefix, raising SynAlerts for likely SYN floods
+// and scans.
+type SynFloodDetector struct {
+	settings *SynSettings
+	cfg      SynFloodConfig
+
+	latest       time.Time
+	prevCounters *tcpconnparser.NetCounters
+	cookies      int64
+
+	ports   map[uint16]*portHalfOpen
+	history map[uint16][]point // Half-open connections at each Sample, by local port
+	sources map[string]*sourceHalfOpen
+}
+
+// PortHalfOpen is the half-open connections to a local port in a Sample.
+type portHalfOpen struct {
+	listeners []*tcpconnparser.Connection
+	owners    []tcpconnparser.Process
+	halfOpen  int
+	sources   map[string]*SourceCount
+}
+
+// SourceHalfOpen is the half-open connections from a source prefix.
+type sourceHalfOpen struct {
+	prefix   *net.IPNet
+	ports    map[uint16]time.Time // The time each local port was last seen
+	halfOpen int                  // In the latest Sample
+}
+
+// NewSynFloodDetector constructs a new SynFloodDetector judging the SYN queues of listeners
+// against the given SynSettings, configured with cfg.
+func NewSynFloodDetector(settings *SynSettings, cfg SynFloodConfig) (*SynFloodDetector, error) {
+	if cfg.Window == 0 {
+		cfg.Window = DefaultSynFloodWindow
+	}
+
+	if cfg.FloodFill == 0 {
+		cfg.FloodFill = DefaultSynFloodFill
+	}
+
+	if cfg.FloodHalfOpen == 0 {
+		cfg.FloodHalfOpen = DefaultSynFloodHalfOpen
+	}
+
+	if cfg.ScanPorts == 0 {
+		cfg.ScanPorts = DefaultSynScanPorts
+	}
+
+	if cfg.IPv4PrefixLen == 0 {
+		cfg.IPv4PrefixLen = defaultIPv4PrefixLen
+	}
+
+	if cfg.IPv6PrefixLen == 0 {
+		cfg.IPv6PrefixLen = defaultIPv6PrefixLen
+	}
+
+	if cfg.IPv4PrefixLen < 0 || cfg.IPv4PrefixLen > 8*net.IPv4len {
+		return nil, fmt.Errorf("illegal IPv4 prefix length: %d", cfg.IPv4PrefixLen)
+	}
+
+	if cfg.IPv6PrefixLen < 0 || cfg.IPv6PrefixLen > 8*net.IPv6len {
+		return nil, fmt.Errorf("illegal IPv6 prefix length: %d", cfg.IPv6PrefixLen)
+	}
+
+	return &SynFloodDetector{
+		settings: settings,
+		cfg:      cfg,
+		ports:    make(map[uint16]*portHalfOpen),
+		history:  make(map[uint16][]point),
+		sources:  make(map[string]*sourceHalfOpen),
+	}, nil
+}
+
+// Observe records the listeners and half-open connections of the given Sample, which must
+// be later than any previously observed.
+func (d *SynFloodDetector) Observe(sample *Sample) error {
+	if !d.latest.IsZero() && !sample.Time.After(d.latest) {
+		return fmt.Errorf("sample at %v not after latest sample at %v", sample.Time, d.latest)
+	}
+
+	d.latest = sample.Time
+
+	d.cookies = 0
+	if d.prevCounters != nil && sample.Counters != nil {
+		d.cookies = tcpconnparser.CounterDeltas(d.prevCounters, sample.Counters)["TcpExt"]["SyncookiesSent"]
+	}
+
+	d.prevCounters = sample.Counters
+
+	d.ports = make(map[uint16]*portHalfOpen)
+	port := func(localPort uint16) *portHalfOpen {
+		p, ok := d.ports[localPort]
+		if !ok {
+			p = &portHalfOpen{sources: make(map[string]*SourceCount)}
+			d.ports[localPort] = p
+		}
+
+		return p
+	}
+
+	for _, source := range d.sources {
+		source.halfOpen = 0
+	}
+
+	for _, conn := range sample.Connections {
+		switch conn.State {
+		case tcpconnparser.StateListen:
+			p := port(conn.LocalPort)
+			p.listeners = append(p.listeners, conn)
+			p.owners = append(p.owners, sample.ownersOf(conn)...)
+		case tcpconnparser.StateSynReceived:
+			prefix := tcpconnparser.Prefix(conn.RemoteAddr, d.cfg.IPv4PrefixLen, d.cfg.IPv6PrefixLen)
+			key := prefix.String()
+
+			p := port(conn.LocalPort)
+			p.halfOpen++
+			if _, ok := p.sources[key]; !ok {
+				p.sources[key] = &SourceCount{Prefix: prefix}
+			}
+			p.sources[key].Count++
+
+			source, ok := d.sources[key]
+			if !ok {
+				source = &sourceHalfOpen{prefix: prefix, ports: make(map[uint16]time.Time)}
+				d.sources[key] = source
+			}
+			source.ports[conn.LocalPort] = sample.Time
+			source.halfOpen++
+		}
+	}
+
+	// Forget the ports of sources not seen within the window
+	for key, source := range d.sources {
+		for localPort, seen := range source.ports {
+			if sample.Time.Sub(seen) > d.cfg.Window {
+				delete(source.ports, localPort)
+			}
+		}
+
+		if len(source.ports) == 0 {
+			delete(d.sources, key)
+		}
+	}
+
+	for localPort, p := range d.ports {
+		if _, ok := d.history[localPort]; !ok && p.halfOpen > 0 {
+			d.history[localPort] = nil
+		}
+	}
+
+	for localPort, points := range d.history {
+		var halfOpen int
+		if p, ok := d.ports[localPort]; ok {
+			halfOpen = p.halfOpen
+		}
+
+		points = trimWindow(append(points, point{sample.Time, float64(halfOpen)}), d.cfg.Window)
+
+		// Forget ports which have had no half-open connections throughout the window
+		empty := true
+		for _, p := range points {
+			empty = empty && p.value == 0
+		}
+
+		if empty {
+			delete(d.history, localPort)
+		} else {
+			d.history[localPort] = points
+		}
+	}
+
+	return nil
+}
+
+// Alerts returns the SynAlerts raised as of the latest Sample: SynFloodAlerts, fullest
+// first, followed by SynScanAlerts, most ports first.
+func (d *SynFloodDetector) Alerts() []SynAlert {
+	var floods []*SynFloodAlert
+	for localPort, p := range d.ports {
+		if p.halfOpen < d.cfg.FloodHalfOpen {
+			continue
+		}
+
+		// Each listener of the port, e.g. of each family or of a SO_REUSEPORT group,
+		// has its own SYN queue
+		listeners := len(p.listeners)
+		if listeners == 0 {
+			listeners = 1
+		}
+
+		alert := &SynFloodAlert{
+			LocalPort:      localPort,
+			Listeners:      p.listeners,
+			Owners:         p.owners,
+			HalfOpen:       p.halfOpen,
+			Limit:          uint32(listeners) * d.settings.SynQueueLimit(),
+			Growth:         slope(d.history[localPort]),
+			SourcePrefixes: len(p.sources),
+			SyncookiesSent: d.cookies,
+		}
+
+		if alert.Limit > 0 {
+			alert.Fill = float64(alert.HalfOpen) / float64(alert.Limit)
+			if alert.Fill > 1 {
+				alert.Fill = 1
+			}
+		}
+
+		if alert.Fill < d.cfg.FloodFill {
+			continue
+		}
+
+		for _, source := range p.sources {
+			alert.Sources = append(alert.Sources, *source)
+		}
+
+		sort.Slice(alert.Sources, func(i, j int) bool {
+			if alert.Sources[i].Count != alert.Sources[j].Count {
+				return alert.Sources[i].Count > alert.Sources[j].Count
+			}
+
+			return alert.Sources[i].Prefix.String() < alert.Sources[j].Prefix.String()
+		})
+
+		if len(alert.Sources) > maxSynFloodSources {
+			alert.Sources = alert.Sources[:maxSynFloodSources]
+		}
+
+		floods = append(floods, alert)
+	}
+
+	sort.Slice(floods, func(i, j int) bool {
+		if floods[i].Fill != floods[j].Fill {
+			return floods[i].Fill > floods[j].Fill
+		}
+
+		return floods[i].LocalPort < floods[j].LocalPort
+	})
+
+	var scans []*SynScanAlert
+	for _, source := range d.sources {
+		if len(source.ports) < d.cfg.ScanPorts {
+			continue
+		}
+
+		alert := &SynScanAlert{
+			Source:   source.prefix,
+			Ports:    make([]uint16, 0, len(source.ports)),
+			HalfOpen: source.halfOpen,
+		}
+
+		for localPort := range source.ports {
+			alert.Ports = append(alert.Ports, localPort)
+		}
+
+		sort.Slice(alert.Ports, func(i, j int) bool { return alert.Ports[i] < alert.Ports[j] })
+		scans = append(scans, alert)
+	}
+
+	sort.Slice(scans, func(i, j int) bool {
+		if len(scans[i].Ports) != len(scans[j].Ports) {
+			return len(scans[i].Ports) > len(scans[j].Ports)
+		}
+
+		return scans[i].Source.String() < scans[j].Source.String()
+	})
+
+	alerts := make([]SynAlert, 0, len(floods)+len(scans))
+	for _, alert := range floods {
+		alerts = append(alerts, alert)
+	}
+
+	for _, alert := range scans {
+		alerts = append(alerts, alert)
+	}
+
+	return alerts
+}
+
+// Default prefix lengths to which source addresses are aggregated.
+const (
+	defaultIPv4PrefixLen = 24
+	defaultIPv6PrefixLen = 64
+)
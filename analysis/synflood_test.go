@@ -0,0 +1,208 @@
+package analysis
+
+import (
+	"math"
+	"net"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+func TestReadSynSettings(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"sys/net/ipv4/tcp_syncookies":      {Data: []byte("1\n")},
+		"sys/net/ipv4/tcp_max_syn_backlog": {Data: []byte("512\n")},
+		"sys/net/core/somaxconn":           {Data: []byte("4096\n")},
+	}
+
+	settings, err := ReadSynSettings(tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS)))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	expected := SynSettings{SynCookies: SynCookiesOnFull, MaxSynBacklog: 512, MaxAcceptBacklog: 4096}
+	if *settings != expected {
+		t.Errorf("expected %+v, got %+v", expected, *settings)
+	}
+}
+
+func TestReadSynSettingsMissingSysctlError(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"sys/net/ipv4/tcp_syncookies": {Data: []byte("1\n")},
+	}
+
+	_, err := ReadSynSettings(tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS)))
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestSynQueueLimit(t *testing.T) {
+	tests := []struct {
+		input    SynSettings
+		expected uint32
+	}{
+		{SynSettings{SynCookies: SynCookiesOnFull, MaxSynBacklog: 512, MaxAcceptBacklog: 4096}, 4096},
+		{SynSettings{SynCookies: SynCookiesDisabled, MaxSynBacklog: 512, MaxAcceptBacklog: 4096}, 384},
+		{SynSettings{SynCookies: SynCookiesDisabled, MaxSynBacklog: 8192, MaxAcceptBacklog: 128}, 128},
+	}
+
+	for _, test := range tests {
+		if output := test.input.SynQueueLimit(); output != test.expected {
+			t.Errorf("expected limit %d, got %d for input %+v", test.expected, output, test.input)
+		}
+	}
+}
+
+// MockSynRecv returns a half-open connection to the given local port from the given address.
+func mockSynRecv(localPort uint16, remoteAddr net.IP, remotePort uint16) *tcpconnparser.Connection {
+	return tcpconnparser.NewConnection(tcpconnparser.StateSynReceived, tcpconnparser.ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), localPort, remoteAddr, remotePort, 0, 0)
+}
+
+func TestSynFloodDetectorFlood(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	settings := &SynSettings{SynCookies: SynCookiesOnFull, MaxSynBacklog: 512, MaxAcceptBacklog: 100}
+	detector, err := NewSynFloodDetector(settings, SynFloodConfig{})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	listener := tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv4, 0, net.IPv4zero, 443, 0, 1)
+	owner := tcpconnparser.Process{PID: 1234, Command: "server"}
+
+	// The listener's SYN queue fills over 3 Samples, mostly from spoofed addresses across
+	// many prefixes, while a few legitimate clients connect to port 22
+	for i := 1; i <= 3; i++ {
+		conns := []*tcpconnparser.Connection{
+			listener,
+			mockSynRecv(22, net.IPv4(192, 0, 2, 1), 50000),
+		}
+
+		for j := 0; j < 30*i; j++ {
+			conns = append(conns, mockSynRecv(443, net.IPv4(203, 0, byte(j%20), byte(j)), uint16(40000+j)))
+		}
+
+		err := detector.Observe(&Sample{
+			Time:        start.Add(time.Duration(i) * 10 * time.Second),
+			Connections: conns,
+			Owners:      map[uint32][]tcpconnparser.Process{1: {owner}},
+			Counters: &tcpconnparser.NetCounters{
+				Counters: map[string]map[string]int64{"TcpExt": {"SyncookiesSent": int64(100 * i)}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+	}
+
+	alerts := detector.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %v", alerts)
+	}
+
+	alert, ok := alerts[0].(*SynFloodAlert)
+	if !ok {
+		t.Fatalf("expected *SynFloodAlert, got %T", alerts[0])
+	}
+
+	if alert.LocalPort != 443 || len(alert.Listeners) != 1 || len(alert.Owners) != 1 || alert.Owners[0] != owner {
+		t.Errorf("expected alert on listener of port 443 owned by %v, got %+v", owner, alert)
+	}
+
+	if alert.HalfOpen != 90 || alert.Limit != 100 || alert.Fill != 0.9 {
+		t.Errorf("expected 90 half-open of limit 100, got %d of %d (%g)", alert.HalfOpen, alert.Limit, alert.Fill)
+	}
+
+	if math.Abs(alert.Growth-3) > 1e-9 || alert.SyncookiesSent != 100 {
+		t.Errorf("expected growth of 3 per second and 100 cookies sent, got %g and %d", alert.Growth, alert.SyncookiesSent)
+	}
+
+	if alert.SourcePrefixes != 20 || len(alert.Sources) != maxSynFloodSources {
+		t.Errorf("expected 20 source prefixes, %d listed, got %d, %d", maxSynFloodSources, alert.SourcePrefixes, len(alert.Sources))
+	}
+
+	if source := alert.Sources[0]; source.Count != 5 || source.Prefix.String() != "203.0.0.0/24" {
+		t.Errorf("expected 5 half-open from 203.0.0.0/24 first, got %d from %s", source.Count, source.Prefix)
+	}
+
+	if str := alert.String(); str != "SYN flood on port 443: 90 half-open of limit 100 (90%) from 20 source prefixes" {
+		t.Errorf("unexpected string %q", str)
+	}
+}
+
+func TestSynFloodDetectorScan(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	settings := &SynSettings{SynCookies: SynCookiesOnFull, MaxSynBacklog: 512, MaxAcceptBacklog: 4096}
+	detector, err := NewSynFloodDetector(settings, SynFloodConfig{Window: 30 * time.Second, ScanPorts: 5})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	// The scanner probes a port each 10 seconds, so only the ports of the last 30 seconds
+	// are counted, while a client connects repeatedly to a single port
+	for i := 0; i < 8; i++ {
+		err := detector.Observe(&Sample{
+			Time: start.Add(time.Duration(i) * 10 * time.Second),
+			Connections: []*tcpconnparser.Connection{
+				mockSynRecv(uint16(1000+i), net.IPv4(198, 51, 100, 7), 60000),
+				mockSynRecv(80, net.IPv4(192, 0, 2, 1), uint16(50000+i)),
+			},
+		})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+	}
+
+	alerts := detector.Alerts()
+	if len(alerts) != 0 {
+		t.Errorf("expected no alerts below 5 ports, got %v", alerts)
+	}
+
+	err = detector.Observe(&Sample{
+		Time: start.Add(80 * time.Second),
+		Connections: []*tcpconnparser.Connection{
+			mockSynRecv(3000, net.IPv4(198, 51, 100, 7), 60000),
+			mockSynRecv(3001, net.IPv4(198, 51, 100, 8), 60000),
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	alerts = detector.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %v", alerts)
+	}
+
+	alert, ok := alerts[0].(*SynScanAlert)
+	if !ok {
+		t.Fatalf("expected *SynScanAlert, got %T", alerts[0])
+	}
+
+	// Ports 1005-1007 are within 30 seconds of the latest Sample
+	expected := []uint16{1005, 1006, 1007, 3000, 3001}
+	if alert.Source.String() != "198.51.100.0/24" || alert.HalfOpen != 2 || len(alert.Ports) != len(expected) {
+		t.Fatalf("expected scan of %v from 198.51.100.0/24 with 2 half-open, got %+v", expected, alert)
+	}
+
+	for i := range expected {
+		if alert.Ports[i] != expected[i] {
+			t.Errorf("expected ports %v, got %v", expected, alert.Ports)
+			break
+		}
+	}
+}
+
+func TestNewSynFloodDetectorIllegalPrefixLenError(t *testing.T) {
+	_, err := NewSynFloodDetector(&SynSettings{}, SynFloodConfig{IPv6PrefixLen: 129})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
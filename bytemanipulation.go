@@ -1,8 +1,10 @@
 package tcpconnparser
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // ReverseBytesInHexWord reverses the bytes given in the hexadecimal encoded string
@@ -14,17 +16,16 @@ func reverseBytesInHexWord(hexWord string) ([]byte, error) {
 		return nil, fmt.Errorf("hex string %q has odd number of nibbles", hexWord)
 	}
 
-	src := []rune(hexWord)
-	dst := make([]byte, len(src)/2)
+	// Operate on bytes rather than runes, so that multi-byte characters are
+	// rejected by the integer parsing rather than causing bad slicing.
+	dst := make([]byte, len(hexWord)/2)
 
-	for i, j := 0, len(dst)-1; i < len(src); {
-		buf := make([]rune, 2)
-		buf[0] = src[i]
-		buf[1] = src[i+1]
+	for i, j := 0, len(dst)-1; i < len(hexWord); {
+		octet := hexWord[i : i+2]
 
-		octetUint64, err := strconv.ParseUint(string(buf), 16, 8)
+		octetUint64, err := strconv.ParseUint(octet, 16, 8)
 		if err != nil {
-			return nil, fmt.Errorf("unable to parse hex octet %q as integer: %w", string(buf), err)
+			return nil, fmt.Errorf("unable to parse hex octet %q as integer: %w", octet, err)
 		}
 
 		dst[j] = byte(octetUint64)
@@ -35,3 +36,14 @@ func reverseBytesInHexWord(hexWord string) ([]byte, error) {
 
 	return dst, nil
 }
+
+// HexWordFromReversedBytes reverses the given slice of bytes, and returns the result encoded
+// as an upper-case hexadecimal string. It is the inverse of reverseBytesInHexWord.
+func hexWordFromReversedBytes(word []byte) string {
+	reversed := make([]byte, len(word))
+	for i, j := 0, len(word)-1; i < len(word); i, j = i+1, j-1 {
+		reversed[j] = word[i]
+	}
+
+	return strings.ToUpper(hex.EncodeToString(reversed))
+}
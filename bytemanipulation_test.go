@@ -2,6 +2,7 @@ package tcpconnparser
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -42,3 +43,31 @@ func TestReverseBytesInHexWordNonIntegerNibbleError(t *testing.T) {
 
 	t.Logf("got error %q (of type %T)", err, err)
 }
+
+func TestReverseBytesInHexWordMultiByteRuneError(t *testing.T) {
+	input := "00\u00e9"
+
+	_, err := reverseBytesInHexWord(input)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func FuzzReverseBytesInHexWord(f *testing.F) {
+	for _, seed := range []string{"0DF0FECA", "0100007F", "00000000000000000000000001000000", "DF0FECA", "GDF0FECA", ""} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		output, err := reverseBytesInHexWord(input)
+		if err != nil {
+			return
+		}
+
+		if hexWordFromReversedBytes(output) != strings.ToUpper(input) {
+			t.Errorf("expected reversing %X to give %q", output, input)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+	"github.com/jhwbarlow/tcpconnparser/metrics"
+)
+
+// Exporter is an http.Handler serving the metrics of the connections read by a Parser.
+type exporter struct {
+	parser    *tcpconnparser.Parser
+	collector *metrics.Collector
+	cacheTTL  time.Duration
+	now       func() time.Time
+
+	mu             sync.Mutex
+	families       []*metrics.Family // Cached metrics of the last successful snapshot
+	snapshotTime   time.Time
+	up             bool // Whether the last snapshot taken succeeded
+	scrapeDuration time.Duration
+	snapshots      int
+	snapshotErrors int
+	parseErrors    int
+}
+
+// NewExporter constructs a new exporter, caching snapshots for cacheTTL.
+func newExporter(parser *tcpconnparser.Parser, collector *metrics.Collector, cacheTTL time.Duration) *exporter {
+	return &exporter{
+		parser:    parser,
+		collector: collector,
+		cacheTTL:  cacheTTL,
+		now:       time.Now,
+	}
+}
+
+// ServeHTTP serves the metrics of the cached snapshot, taking a new snapshot if it
+// has expired. If taking a snapshot fails, the metrics of the previous snapshot are
+// served, and the failure is reported by the up gauge, which is zero until a snapshot
+// succeeds, and by the snapshot error count. The age of the served snapshot is given
+// by the snapshot timestamp. As the snapshot is not cached after a failure, the next
+// scrape takes a new snapshot.
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.families == nil || e.now().Sub(e.snapshotTime) >= e.cacheTTL {
+		e.refresh()
+	}
+
+	families := make([]*metrics.Family, 0, len(e.families)+6)
+	families = append(families, e.families...)
+	families = append(families, e.selfFamilies()...)
+
+	w.Header().Set("Content-Type", metrics.TextContentType)
+	// The status has been sent by the time writing fails, so the error cannot be reported
+	_ = metrics.WriteText(w, families)
+}
+
+// Refresh takes a new snapshot and caches its metrics.
+func (e *exporter) refresh() {
+	start := e.now()
+	conns, err := e.readConnections()
+	e.scrapeDuration = e.now().Sub(start)
+	e.snapshots++
+
+	if err != nil {
+		e.snapshotErrors++
+		e.up = false
+		return
+	}
+
+	collector := e.collector
+	if maxAcceptBacklog, err := e.parser.GetMaxAcceptBacklog(); err == nil {
+		collector = e.collector.WithMaxAcceptBacklog(maxAcceptBacklog)
+	}
+
+	e.families = collector.Collect(conns)
+	e.snapshotTime = start
+	e.up = true
+}
+
+// ReadConnections reads the connections of each protocol version. Protocol versions
+// whose files do not exist, such as IPv6 on hosts where it is disabled, are skipped.
+// Lines which cannot be parsed are counted and skipped.
+func (e *exporter) readConnections() ([]*tcpconnparser.Connection, error) {
+	conns, err := e.parser.GetAllConnections()
+
+	var parseErrs tcpconnparser.ParseErrors
+	if errors.As(err, &parseErrs) {
+		e.parseErrors += len(parseErrs)
+		return conns, nil
+	}
+
+	return conns, err
+}
+
+// SelfFamilies returns the metrics describing the exporter itself.
+func (e *exporter) selfFamilies() []*metrics.Family {
+	gauge := func(name, help string, value float64) *metrics.Family {
+		return &metrics.Family{
+			Name:    metrics.DefaultNamespace + "_" + name,
+			Help:    help,
+			Type:    metrics.TypeGauge,
+			Samples: []metrics.Sample{{Value: value}},
+		}
+	}
+
+	counter := func(name, help string, value float64) *metrics.Family {
+		family := gauge(name, help, value)
+		family.Type = metrics.TypeCounter
+		return family
+	}
+
+	up := 0.0
+	if e.up {
+		up = 1
+	}
+
+	families := []*metrics.Family{
+		gauge("up",
+			"Whether the last read of the procfs files succeeded, so that the served metrics are current.",
+			up),
+		gauge("scrape_duration_seconds",
+			"Duration of the last read of the procfs files, in seconds.",
+			e.scrapeDuration.Seconds()),
+		counter("scrapes_total",
+			"Number of reads of the procfs files.",
+			float64(e.snapshots)),
+		counter("scrape_errors_total",
+			"Number of reads of the procfs files which failed.",
+			float64(e.snapshotErrors)),
+		counter("parse_errors_total",
+			"Number of lines of the procfs files which could not be parsed.",
+			float64(e.parseErrors)),
+	}
+
+	if !e.snapshotTime.IsZero() {
+		families = append(families, gauge("snapshot_timestamp_seconds",
+			"Time at which the served snapshot was taken, in seconds since the epoch.",
+			float64(e.snapshotTime.UnixNano())/float64(time.Second)))
+	}
+
+	return families
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+	"github.com/jhwbarlow/tcpconnparser/metrics"
+)
+
+const mockTCP = `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0
+1: 0301A8C0:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1`
+
+const mockTCP6 = `sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+2: 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 31267 1 0000000000000000 100 0 0 10 0`
+
+// MockClock is a clock which only advances when told to.
+type mockClock struct {
+	now time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	return c.now
+}
+
+func newMockExporter(t *testing.T, mockFS fstest.MapFS, clock *mockClock) *exporter {
+	t.Helper()
+
+	collector, err := metrics.NewCollector(metrics.Config{LocalPorts: true})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	parser := tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS),
+		tcpconnparser.WithLenientParsing(maxParseErrors))
+
+	e := newExporter(parser, collector, 10*time.Second)
+	e.now = clock.Now
+
+	return e
+}
+
+func scrape(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != metrics.TextContentType {
+		t.Errorf("expected content type %q, got %q", metrics.TextContentType, contentType)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	return string(body)
+}
+
+func TestExporterServesMetrics(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp":                {Data: []byte(mockTCP)},
+		"net/tcp6":               {Data: []byte(mockTCP6)},
+		"sys/net/core/somaxconn": {Data: []byte("4096\n")},
+	}
+	clock := &mockClock{now: time.Unix(1700000000, 0)}
+
+	server := httptest.NewServer(newMockExporter(t, mockFS, clock))
+	defer server.Close()
+
+	body := scrape(t, server)
+	expected := []string{
+		`tcpconn_connections{family="IPv4",state="ESTABLISHED",port="54176"} 1`,
+		`tcpconn_connections{family="IPv4",state="LISTEN",port="6789"} 1`,
+		`tcpconn_connections{family="IPv6",state="LISTEN",port="631"} 1`,
+		`tcpconn_listener_accept_queue{family="IPv4",port="6789"} 50`,
+		`tcpconn_listener_max_backlog{family="IPv4",port="6789"} 4096`,
+		"tcpconn_up 1",
+		"tcpconn_scrapes_total 1",
+		"tcpconn_scrape_errors_total 0",
+		"tcpconn_parse_errors_total 0",
+		"tcpconn_snapshot_timestamp_seconds 1.7e+09",
+		"# TYPE tcpconn_scrape_duration_seconds gauge",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in body:\n%s", line, body)
+		}
+	}
+}
+
+func TestExporterCachesSnapshot(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp":  {Data: []byte(mockTCP)},
+		"net/tcp6": {Data: []byte(mockTCP6)},
+	}
+	clock := &mockClock{now: time.Unix(1700000000, 0)}
+
+	server := httptest.NewServer(newMockExporter(t, mockFS, clock))
+	defer server.Close()
+
+	scrape(t, server)
+
+	// The removed file is not read again until the cache expires, after which the
+	// missing protocol version is skipped
+	delete(mockFS, "net/tcp6")
+	clock.now = clock.now.Add(5 * time.Second)
+
+	body := scrape(t, server)
+	if !strings.Contains(body, "tcpconn_scrapes_total 1\n") {
+		t.Errorf("expected cached snapshot, got body:\n%s", body)
+	}
+
+	clock.now = clock.now.Add(5 * time.Second)
+
+	body = scrape(t, server)
+	if !strings.Contains(body, "tcpconn_scrapes_total 2\n") {
+		t.Errorf("expected new snapshot, got body:\n%s", body)
+	}
+
+	if strings.Contains(body, `family="IPv6"`) {
+		t.Errorf("expected no IPv6 connections, got body:\n%s", body)
+	}
+}
+
+func TestExporterServesPreviousSnapshotOnError(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp":  {Data: []byte(mockTCP)},
+		"net/tcp6": {Data: []byte(mockTCP6)},
+	}
+	clock := &mockClock{now: time.Unix(1700000000, 0)}
+
+	server := httptest.NewServer(newMockExporter(t, mockFS, clock))
+	defer server.Close()
+
+	scrape(t, server)
+
+	mockFS["net/tcp"] = &fstest.MapFile{Data: []byte("bogus header")}
+	clock.now = clock.now.Add(time.Minute)
+
+	body := scrape(t, server)
+	expected := []string{
+		`tcpconn_connections{family="IPv4",state="LISTEN",port="6789"} 1`,
+		"tcpconn_up 0",
+		"tcpconn_scrapes_total 2",
+		"tcpconn_scrape_errors_total 1",
+		"tcpconn_snapshot_timestamp_seconds 1.7e+09",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in body:\n%s", line, body)
+		}
+	}
+
+	// The failed snapshot is not cached, so the next scrape recovers
+	mockFS["net/tcp"] = &fstest.MapFile{Data: []byte(mockTCP)}
+	clock.now = clock.now.Add(time.Second)
+
+	body = scrape(t, server)
+	expected = []string{
+		"tcpconn_up 1",
+		"tcpconn_scrapes_total 3",
+		"tcpconn_snapshot_timestamp_seconds 1.700000061e+09",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in body:\n%s", line, body)
+		}
+	}
+}
+
+func TestExporterDownWithoutSnapshot(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte("bogus header")},
+	}
+	clock := &mockClock{now: time.Unix(1700000000, 0)}
+
+	server := httptest.NewServer(newMockExporter(t, mockFS, clock))
+	defer server.Close()
+
+	body := scrape(t, server)
+	if !strings.Contains(body, "tcpconn_up 0\n") {
+		t.Errorf("expected line %q in body:\n%s", "tcpconn_up 0", body)
+	}
+
+	if strings.Contains(body, "tcpconn_snapshot_timestamp_seconds") {
+		t.Errorf("expected no snapshot timestamp, got body:\n%s", body)
+	}
+}
+
+func TestParseRemoteAddrModeError(t *testing.T) {
+	_, err := parseRemoteAddrMode("bogus")
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,113 @@
+// Command tcpconn-exporter serves metrics describing the TCP connections of the host
+// in the Prometheus text format, for scraping by Prometheus or an OpenMetrics-compatible
+// collector. Snapshots of the connections are cached between scrapes, so that frequent
+// scrapes by multiple collectors do not repeatedly read the procfs files.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+	"github.com/jhwbarlow/tcpconnparser/metrics"
+)
+
+// Maximum number of unparseable lines tolerated in each snapshot.
+const maxParseErrors = 100
+
+// Timeouts of the HTTP server, bounding the resources held by slow or idle clients.
+// Writing a response includes taking a snapshot, so is given longer.
+const (
+	readHeaderTimeout = 10 * time.Second
+	writeTimeout      = time.Minute
+	idleTimeout       = 2 * time.Minute
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// Run runs the command with the given arguments, returning the exit code.
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tcpconn-exporter", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	listenAddr := flags.String("listen", ":9873", "address on which to serve metrics")
+	metricsPath := flags.String("path", "/metrics", "HTTP path on which to serve metrics")
+	cacheTTL := flags.Duration("cache-ttl", 5*time.Second, "duration for which a snapshot is reused between scrapes")
+	procRoot := flags.String("proc-root", "/proc", "directory from which procfs files are read")
+	localPorts := flags.Bool("local-ports", true, "label connection counts with their local port")
+	remoteAddrs := flags.String("remote", "drop", "labelling of connection counts with their remote address: drop, prefix or full")
+	ipv4PrefixLen := flags.Int("ipv4-prefix", metrics.DefaultIPv4PrefixLen, "prefix length to which remote IPv4 addresses are aggregated")
+	ipv6PrefixLen := flags.Int("ipv6-prefix", metrics.DefaultIPv6PrefixLen, "prefix length to which remote IPv6 addresses are aggregated")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	remoteAddrMode, err := parseRemoteAddrMode(*remoteAddrs)
+	if err != nil {
+		fmt.Fprintf(stderr, "tcpconn-exporter: %v\n", err)
+		return 2
+	}
+
+	collector, err := metrics.NewCollector(metrics.Config{
+		LocalPorts:    *localPorts,
+		RemoteAddrs:   remoteAddrMode,
+		IPv4PrefixLen: *ipv4PrefixLen,
+		IPv6PrefixLen: *ipv6PrefixLen,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "tcpconn-exporter: creating collector: %v\n", err)
+		return 2
+	}
+
+	parser := tcpconnparser.NewParser(tcpconnparser.WithProcRoot(*procRoot),
+		tcpconnparser.WithLenientParsing(maxParseErrors),
+		tcpconnparser.WithUnknownStates())
+
+	mux := http.NewServeMux()
+	mux.Handle(*metricsPath, newExporter(parser, collector, *cacheTTL))
+
+	logger := log.New(stderr, "tcpconn-exporter: ", log.LstdFlags)
+	logger.Printf("serving metrics on %s%s", *listenAddr, *metricsPath)
+
+	if err := newServer(*listenAddr, mux).ListenAndServe(); err != nil {
+		logger.Printf("serving metrics: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// NewServer constructs a new http.Server serving handler on addr, with timeouts.
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readHeaderTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// ParseRemoteAddrMode returns the metrics.RemoteAddrMode with the given name.
+func parseRemoteAddrMode(str string) (metrics.RemoteAddrMode, error) {
+	switch str {
+	case "drop":
+		return metrics.RemoteAddrDrop, nil
+	case "prefix":
+		return metrics.RemoteAddrPrefix, nil
+	case "full":
+		return metrics.RemoteAddrFull, nil
+	default:
+		return 0, errors.New("remote address labelling must be one of drop, prefix or full")
+	}
+}
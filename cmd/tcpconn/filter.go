@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Filter is a predicate on connections, parsed from an expression by parseFilter.
+type filter func(conn *tcpconnparser.Connection) bool
+
+// Fields of a connection which may be compared in a filter expression.
+var filterFields = map[string]func(conn *tcpconnparser.Connection) any{
+	"state":  func(conn *tcpconnparser.Connection) any { return conn.State },
+	"family": func(conn *tcpconnparser.Connection) any { return conn.ProtocolVersion },
+	"laddr":  func(conn *tcpconnparser.Connection) any { return conn.LocalAddr },
+	"lport":  func(conn *tcpconnparser.Connection) any { return uint64(conn.LocalPort) },
+	"raddr":  func(conn *tcpconnparser.Connection) any { return conn.RemoteAddr },
+	"rport":  func(conn *tcpconnparser.Connection) any { return uint64(conn.RemotePort) },
+	"rxq":    func(conn *tcpconnparser.Connection) any { return uint64(conn.ReceiveBufferSize) },
+	"txq":    func(conn *tcpconnparser.Connection) any { return uint64(conn.SendBufferSize) },
+	"uid":    func(conn *tcpconnparser.Connection) any { return uint64(conn.UID) },
+	"inode":  func(conn *tcpconnparser.Connection) any { return uint64(conn.INode) },
+}
+
+// Comparison operators of a filter expression.
+var filterOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// ParseFilter parses a filter expression, made up of comparisons of a connection field
+// with a value, combined with "&&", "||" and "!", and grouped with parentheses, e.g.
+//
+//	state == ESTABLISHED && (rport == 443 || raddr == 10.0.0.0/8) && rxq > 0
+//
+// The fields are state, family, laddr, lport, raddr, rport, rxq, txq, uid and inode.
+// Numeric fields may be compared with any of ==, !=, <, <=, > and >=, and the others only
+// with == and !=. States and families are compared case-insensitively, states also being
+// given by their kernel names, e.g. TIME_WAIT, and an unknown state or family is an error.
+// Addresses may be compared with a prefix in CIDR notation, matching any address within it.
+// An empty expression matches all connections.
+func parseFilter(expr string) (filter, error) {
+	p := &filterParser{tokens: tokenizeFilter(expr)}
+	if len(p.tokens) == 0 {
+		return func(*tcpconnparser.Connection) bool { return true }, nil
+	}
+
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+
+	return f, nil
+}
+
+// TokenizeFilter splits a filter expression into operators, parentheses and words.
+func tokenizeFilter(expr string) []string {
+	var tokens []string
+
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, expr[i:i+1])
+			i++
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			j := i + 1
+			if j < len(expr) && strings.ContainsRune("=&|", rune(expr[j])) {
+				j++
+			}
+
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(expr) && !unicode.IsSpace(rune(expr[j])) && !strings.ContainsRune("()=!<>&|", rune(expr[j])) {
+				j++
+			}
+
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+
+	return tokens
+}
+
+// FilterParser is a recursive descent parser of tokenized filter expressions.
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+// Peek returns the next token, or the empty string at the end of the expression.
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+
+	return ""
+}
+
+// Next consumes and returns the next token, failing at the end of the expression.
+func (p *filterParser) next(expected string) (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("expected %s at end of expression", expected)
+	}
+
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+// ParseOr parses a disjunction of conjunctions.
+func (p *filterParser) parseOr() (filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(conn *tcpconnparser.Connection) bool { return l(conn) || right(conn) }
+	}
+
+	return left, nil
+}
+
+// ParseAnd parses a conjunction of unary expressions.
+func (p *filterParser) parseAnd() (filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(conn *tcpconnparser.Connection) bool { return l(conn) && right(conn) }
+	}
+
+	return left, nil
+}
+
+// ParseUnary parses a negation, a parenthesised expression or a comparison.
+func (p *filterParser) parseUnary() (filter, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(conn *tcpconnparser.Connection) bool { return !operand(conn) }, nil
+	case "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if token, err := p.next(`")"`); err != nil {
+			return nil, err
+		} else if token != ")" {
+			return nil, fmt.Errorf(`expected ")", got %q`, token)
+		}
+
+		return inner, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+// ParseComparison parses the comparison of a field with a value.
+func (p *filterParser) parseComparison() (filter, error) {
+	name, err := p.next("field")
+	if err != nil {
+		return nil, err
+	}
+
+	field, ok := filterFields[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", name)
+	}
+
+	op, err := p.next("operator")
+	if err != nil {
+		return nil, err
+	}
+
+	if !isFilterOperator(op) {
+		return nil, fmt.Errorf("expected operator after %q, got %q", name, op)
+	}
+
+	value, err := p.next("value")
+	if err != nil {
+		return nil, err
+	}
+
+	// The type of the field is found from its value in a zero connection
+	switch field(&tcpconnparser.Connection{}).(type) {
+	case uint64:
+		return numericComparison(field, op, value)
+	case net.IP:
+		return addressComparison(field, op, value)
+	default:
+		return equalityComparison(field, op, value)
+	}
+}
+
+// IsFilterOperator returns whether token is a comparison operator.
+func isFilterOperator(token string) bool {
+	for _, op := range filterOperators {
+		if token == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NumericComparison returns a filter comparing the numeric field with value using op.
+func numericComparison(field func(*tcpconnparser.Connection) any, op, value string) (filter, error) {
+	operand, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %q as integer: %w", value, err)
+	}
+
+	compare := map[string]func(a, b uint64) bool{
+		"==": func(a, b uint64) bool { return a == b },
+		"!=": func(a, b uint64) bool { return a != b },
+		"<":  func(a, b uint64) bool { return a < b },
+		"<=": func(a, b uint64) bool { return a <= b },
+		">":  func(a, b uint64) bool { return a > b },
+		">=": func(a, b uint64) bool { return a >= b },
+	}[op]
+
+	return func(conn *tcpconnparser.Connection) bool {
+		return compare(field(conn).(uint64), operand)
+	}, nil
+}
+
+// AddressComparison returns a filter matching the address field against the address or
+// prefix value using op.
+func addressComparison(field func(*tcpconnparser.Connection) any, op, value string) (filter, error) {
+	if op != "==" && op != "!=" {
+		return nil, fmt.Errorf("addresses may only be compared with == or !=, got %q", op)
+	}
+
+	var prefix *net.IPNet
+	if strings.Contains(value, "/") {
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing prefix: %w", err)
+		}
+
+		prefix = ipNet
+	} else {
+		addr := net.ParseIP(value)
+		if addr == nil {
+			return nil, fmt.Errorf("invalid IP address: %q", value)
+		}
+
+		bits := 8 * net.IPv6len
+		if addr.To4() != nil {
+			bits = 8 * net.IPv4len
+		}
+
+		prefix = &net.IPNet{IP: addr, Mask: net.CIDRMask(bits, bits)}
+	}
+
+	return func(conn *tcpconnparser.Connection) bool {
+		addr, _ := field(conn).(net.IP)
+		matched := addr != nil && prefix.Contains(addr)
+
+		return matched == (op == "==")
+	}, nil
+}
+
+// EqualityComparison returns a filter comparing the state or family field with value
+// using op, which must be == or !=. An error is returned if value names no state or
+// family, as it would match no connections.
+func equalityComparison(field func(*tcpconnparser.Connection) any, op, value string) (filter, error) {
+	if op != "==" && op != "!=" {
+		return nil, fmt.Errorf("states and families may only be compared with == or !=, got %q", op)
+	}
+
+	var operand any
+	var err error
+
+	switch field(&tcpconnparser.Connection{}).(type) {
+	case tcpconnparser.State:
+		operand, err = parseFilterState(value)
+	default:
+		operand, err = parseFilterFamily(value)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(conn *tcpconnparser.Connection) bool {
+		return (field(conn) == operand) == (op == "==")
+	}, nil
+}
+
+// ParseFilterState returns the State named by value case-insensitively, either as the
+// State itself, e.g. SYN-RECEIVED, or as the kernel state, e.g. TCP_SYN_RECV or SYN_RECV.
+// Underscores may be given in place of the hyphens of a State.
+func parseFilterState(value string) (tcpconnparser.State, error) {
+	name := strings.ToUpper(value)
+	hyphenated := strings.ReplaceAll(name, "_", "-")
+
+	if hyphenated == string(tcpconnparser.StateUnknown) {
+		return tcpconnparser.StateUnknown, nil
+	}
+
+	for ks := tcpconnparser.KernelStateEstablished; ks.Known(); ks++ {
+		state, err := ks.State()
+		if err != nil {
+			continue
+		}
+
+		if hyphenated == string(state) ||
+			name == ks.String() ||
+			"TCP_"+name == ks.String() {
+			return state, nil
+		}
+	}
+
+	return tcpconnparser.StateNone, fmt.Errorf("unknown state %q", value)
+}
+
+// ParseFilterFamily returns the protocol version named by value case-insensitively,
+// e.g. IPv4.
+func parseFilterFamily(value string) (tcpconnparser.ProtocolVersion, error) {
+	for _, protocolVersion := range []tcpconnparser.ProtocolVersion{
+		tcpconnparser.ProtocolVersionIPv4,
+		tcpconnparser.ProtocolVersionIPv6,
+	} {
+		if strings.EqualFold(value, protocolVersion.String()) {
+			return protocolVersion, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown family %q", value)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+func TestParseFilter(t *testing.T) {
+	established := tcpconnparser.NewConnection(tcpconnparser.StateEstablished, tcpconnparser.ProtocolVersionIPv4,
+		10, 0, net.IPv4(10, 0, 0, 1), 8080, net.IPv4(10, 1, 2, 3), 443, 1000, 101)
+	timeWait6 := tcpconnparser.NewConnection(tcpconnparser.StateTimeWait, tcpconnparser.ProtocolVersionIPv6,
+		0, 0, net.ParseIP("2001:db8::1"), 8080, net.ParseIP("2001:db8:1::5"), 40000, 0, 0)
+
+	tests := []struct {
+		input                  string
+		established, timeWait6 bool
+	}{
+		{"", true, true},
+		{"state == ESTABLISHED", true, false},
+		{"state == time_wait", false, true},
+		{"state != established", false, true},
+		{"family == ipv6", false, true},
+		{"state == TCP_TIME_WAIT", false, true},
+		{"raddr == 10.0.0.0/8", true, false},
+		{"raddr == 2001:db8:1::/48", false, true},
+		{"laddr == 10.0.0.1", true, false},
+		{"rxq > 0 || rport >= 40000", true, true},
+		{"lport == 8080 && !(uid == 1000)", false, true},
+		{"(state==ESTABLISHED||family==IPv6)&&inode<=101", true, true},
+		{"rport < 443", false, false},
+	}
+
+	for _, test := range tests {
+		f, err := parseFilter(test.input)
+		if err != nil {
+			t.Errorf("expected nil error, got %v (of type %T) for input %q", err, err, test.input)
+			continue
+		}
+
+		if output := f(established); output != test.established {
+			t.Errorf("expected %t for established connection, got %t for input %q", test.established, output, test.input)
+		}
+
+		if output := f(timeWait6); output != test.timeWait6 {
+			t.Errorf("expected %t for IPv6 TIME-WAIT connection, got %t for input %q", test.timeWait6, output, test.input)
+		}
+	}
+}
+
+func TestParseFilterError(t *testing.T) {
+	inputs := []string{
+		"bogus == 1",
+		"state",
+		"state ==",
+		"state < ESTABLISHED",
+		"rport == http",
+		"raddr > 10.0.0.1",
+		"raddr == 10.0.0.0/33",
+		"(state == LISTEN",
+		"state == LISTEN)",
+		"state == LISTEN &&",
+		"state = LISTEN",
+		"state == FOO",
+		"family == IPv5",
+	}
+
+	for _, input := range inputs {
+		_, err := parseFilter(input)
+		if err == nil {
+			t.Errorf("expected error, got nil for input %q", input)
+			continue
+		}
+
+		t.Logf("got error %q (of type %T) for input %q", err, err, input)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Column headings of the groupings selectable with the -by flag of tcpconn group.
+var groupHeadings = map[string]string{
+	"raddr":  "Remote",
+	"prefix": "Prefix",
+	"lport":  "Port",
+	"uid":    "UID",
+}
+
+// Orders selectable with the -sort flag of tcpconn group.
+var groupOrders = map[string]tcpconnparser.GroupOrder{
+	"count": tcpconnparser.GroupOrderCount,
+	"rxq":   tcpconnparser.GroupOrderReceiveQueue,
+	"txq":   tcpconnparser.GroupOrderSendQueue,
+}
+
+// RunGroup writes the top groups of connections by remote address, remote prefix, local
+// port or UID, with their counts by state and summed queues.
+func runGroup(env *env, args []string) int {
+	flags := flag.NewFlagSet("tcpconn group", flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(env.stderr, "Usage: tcpconn group [flags] [captured file...]")
+		flags.PrintDefaults()
+	}
+
+	src := source{stdin: env.stdin}
+	src.addFlags(flags)
+	by := flags.String("by", "raddr", "group connections by `key`: raddr, prefix, lport or uid")
+	order := flags.String("sort", "count", "sort groups by `order`: count, rxq or txq")
+	top := flags.Int("top", 10, "show only the first `n` groups, or all if 0")
+	ipv4PrefixLen := flags.Int("ipv4-prefix", 24, "prefix `length` of IPv4 addresses grouped by prefix")
+	ipv6PrefixLen := flags.Int("ipv6-prefix", 64, "prefix `length` of IPv6 addresses grouped by prefix")
+	numeric := flags.Bool("n", false, "show numeric addresses rather than resolving host names")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+
+		return 2
+	}
+
+	groupOrder, ok := groupOrders[*order]
+	if !ok {
+		fmt.Fprintf(env.stderr, "tcpconn: illegal sort order: %q\n", *order)
+		return 2
+	}
+
+	var key tcpconnparser.GroupKey
+	switch *by {
+	case "raddr":
+		key = tcpconnparser.GroupByRemoteAddr
+	case "prefix":
+		if *ipv4PrefixLen < 0 || *ipv4PrefixLen > 8*net.IPv4len || *ipv6PrefixLen < 0 || *ipv6PrefixLen > 8*net.IPv6len {
+			fmt.Fprintf(env.stderr, "tcpconn: illegal prefix length: %d or %d\n", *ipv4PrefixLen, *ipv6PrefixLen)
+			return 2
+		}
+
+		key = tcpconnparser.GroupByRemotePrefix(*ipv4PrefixLen, *ipv6PrefixLen)
+	case "lport":
+		key = tcpconnparser.GroupByLocalPort
+	case "uid":
+		key = tcpconnparser.GroupByUID
+	default:
+		fmt.Fprintf(env.stderr, "tcpconn: illegal grouping: %q\n", *by)
+		return 2
+	}
+
+	conns, err := src.connections(flags.Args())
+	if err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		return 1
+	}
+
+	groups := tcpconnparser.TopGroups(tcpconnparser.Aggregate(conns, key), groupOrder, *top)
+	if err := writeGroups(env.stdout, groups, *by, newResolver(env.lookupAddr, *numeric)); err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// WriteGroups writes the given groups, keyed by the named grouping, to writer as a table
+// with aligned columns. Remote addresses are resolved by resolver.
+func writeGroups(writer io.Writer, groups []*tcpconnparser.Group, by string, resolver *resolver) error {
+	tw := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tConnections\tRecv-Q\tSend-Q\tStates\n", groupHeadings[by])
+
+	for _, group := range groups {
+		key := group.Key
+		if addr := net.ParseIP(key); by == "raddr" && addr != nil {
+			key = resolver.host(addr)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n",
+			key,
+			group.Count,
+			group.ReceiveQueueBytes,
+			group.SendQueueBytes,
+			formatStates(group.ByState))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing groups: %w", err)
+	}
+
+	return nil
+}
+
+// FormatStates formats the given counts of connections by state as a comma-separated list
+// of state=count, ordered as by summaryStates, omitting states without connections.
+func formatStates(byState map[tcpconnparser.State]int) string {
+	var states []string
+	for _, state := range summaryStates() {
+		if count := byState[state]; count > 0 {
+			states = append(states, string(state)+"="+strconv.Itoa(count))
+		}
+	}
+
+	return strings.Join(states, ",")
+}
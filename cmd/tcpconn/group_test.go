@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupByLocalPort(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP, "net/tcp6": mockTCP6})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"group", "-by", "lport", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	expected := `Port   Connections  Recv-Q  Send-Q  States
+6789   2            0       0       ESTABLISHED=1,LISTEN=1
+54176  1            32      16      ESTABLISHED=1
+631    1            0       0       LISTEN=1
+`
+	if stdout.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, stdout)
+	}
+}
+
+func TestGroupByRemoteAddrResolved(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP, "net/tcp6": mockTCP6})
+	env, stdout, stderr := mockEnv(map[string]string{"::1": "localhost."})
+
+	if code := run([]string{"group", "-sort", "rxq", "-top", "1", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	expected := `Remote         Connections  Recv-Q  Send-Q  States
+88.221.16.125  1            32      16      ESTABLISHED=1
+`
+	if stdout.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, stdout)
+	}
+
+	env, stdout, stderr = mockEnv(map[string]string{"::1": "localhost."})
+	if code := run([]string{"group", "-6", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	if !strings.Contains(stdout.String(), "localhost  1") {
+		t.Errorf("expected resolved remote address, got:\n%s", stdout)
+	}
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"group", "-by", "prefix", "-ipv4-prefix", "16", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	if !strings.Contains(stdout.String(), "88.221.0.0/16  1") {
+		t.Errorf("expected connections grouped by /16, got:\n%s", stdout)
+	}
+}
+
+func TestGroupIllegalFlagsError(t *testing.T) {
+	for _, args := range [][]string{
+		{"group", "-by", "state"},
+		{"group", "-sort", "inode"},
+		{"group", "-by", "prefix", "-ipv6-prefix", "129"},
+	} {
+		env, _, stderr := mockEnv(nil)
+		if code := run(args, env); code != 2 {
+			t.Errorf("expected exit code 2 for %v, got %d", args, code)
+		}
+
+		t.Logf("got error %q", stderr)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// RunList lists connections in aligned columns in the style of ss, or as JSON lines.
+func runList(env *env, args []string) int {
+	flags := flag.NewFlagSet("tcpconn list", flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(env.stderr, "Usage: tcpconn list [flags] [captured file...]")
+		flags.PrintDefaults()
+	}
+
+	src := source{stdin: env.stdin}
+	src.addFlags(flags)
+	listening := flags.Bool("l", false, "show only listening sockets")
+	all := flags.Bool("a", false, "show both listening and non-listening sockets")
+	numeric := flags.Bool("n", false, "show numeric addresses rather than resolving host names")
+	jsonOutput := flags.Bool("json", false, "write connections as JSON lines")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+
+		return 2
+	}
+
+	conns, err := src.connections(flags.Args())
+	if err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		return 1
+	}
+
+	conns = filterListening(conns, *listening, *all)
+
+	if *jsonOutput {
+		err = tcpconnparser.NewJSONLinesEncoder(env.stdout).Encode(conns...)
+	} else {
+		resolver := newResolver(env.lookupAddr, *numeric)
+		err = writeTable(env.stdout, conns, resolver)
+	}
+
+	if err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// FilterListening returns the listening connections if listening is set, all connections
+// if all is set, and the non-listening connections otherwise, as ss does.
+func filterListening(conns []*tcpconnparser.Connection, listening, all bool) []*tcpconnparser.Connection {
+	if all {
+		return conns
+	}
+
+	filtered := make([]*tcpconnparser.Connection, 0, len(conns))
+	for _, conn := range conns {
+		if (conn.State == tcpconnparser.StateListen) == listening {
+			filtered = append(filtered, conn)
+		}
+	}
+
+	return filtered
+}
+
+// WriteTable writes the given connections to writer as a table with aligned columns.
+// As with ss, the Recv-Q of a listener is its accept queue.
+func writeTable(writer io.Writer, conns []*tcpconnparser.Connection, resolver *resolver) error {
+	tw := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "State\tRecv-Q\tSend-Q\tLocal\tPeer\tUID\tInode")
+
+	for _, conn := range conns {
+		recvQ, sendQ := conn.ReceiveBufferSize, conn.SendBufferSize
+		peer := resolver.endpoint(conn.RemoteAddr, strconv.Itoa(int(conn.RemotePort)))
+
+		if conn.State == tcpconnparser.StateListen {
+			recvQ, sendQ = conn.AcceptBacklog, 0
+			peer = resolver.endpoint(unspecifiedAddr(conn.ProtocolVersion), "*")
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%d\t%d\n",
+			conn.State,
+			recvQ,
+			sendQ,
+			resolver.endpoint(conn.LocalAddr, strconv.Itoa(int(conn.LocalPort))),
+			peer,
+			conn.UID,
+			conn.INode)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing table: %w", err)
+	}
+
+	return nil
+}
+
+// UnspecifiedAddr returns the unspecified address of the given protocol version.
+func unspecifiedAddr(protocolVersion tcpconnparser.ProtocolVersion) net.IP {
+	if protocolVersion == tcpconnparser.ProtocolVersionIPv6 {
+		return net.IPv6unspecified
+	}
+
+	return net.IPv4zero
+}
+
+// Resolver formats endpoints, resolving the host names of addresses unless numeric.
+// Lookups are cached, as the same peers commonly appear on many connections.
+type resolver struct {
+	lookupAddr func(addr string) ([]string, error)
+	numeric    bool
+	names      map[string]string
+}
+
+// NewResolver constructs a new resolver looking up addresses with lookupAddr.
+func newResolver(lookupAddr func(addr string) ([]string, error), numeric bool) *resolver {
+	return &resolver{
+		lookupAddr: lookupAddr,
+		numeric:    numeric,
+		names:      make(map[string]string),
+	}
+}
+
+// Endpoint formats the given address and port as "host:port", with IPv6 addresses
+// bracketed. Unspecified addresses and addresses without names are left numeric.
+func (r *resolver) endpoint(addr net.IP, port string) string {
+	if addr == nil {
+		addr = net.IPv4zero
+	}
+
+	return net.JoinHostPort(r.host(addr), port)
+}
+
+// Host returns the host name of the given address, or the address itself if numeric
+// or it cannot be resolved.
+func (r *resolver) host(addr net.IP) string {
+	numericHost := addr.String()
+	if r.numeric || addr.IsUnspecified() {
+		return numericHost
+	}
+
+	if name, ok := r.names[numericHost]; ok {
+		return name
+	}
+
+	name := numericHost
+	if names, err := r.lookupAddr(numericHost); err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	r.names[numericHost] = name
+	return name
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+func TestListNumeric(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP, "net/tcp6": mockTCP6})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"list", "-n", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	expected := `State        Recv-Q  Send-Q  Local              Peer               UID   Inode
+ESTABLISHED  32      16      192.168.1.3:54176  88.221.16.125:443  1000  380687
+ESTABLISHED  0       0       [::1]:6789         [::1]:49018        1000  394269
+`
+	if stdout.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, stdout)
+	}
+}
+
+func TestListListening(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP, "net/tcp6": mockTCP6})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"list", "-l", "-n", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	expected := `State   Recv-Q  Send-Q  Local           Peer       UID   Inode
+LISTEN  50      0       127.0.0.1:6789  0.0.0.0:*  1000  789829
+LISTEN  0       0       [::1]:631       [::]:*     0     31267
+`
+	if stdout.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, stdout)
+	}
+}
+
+func TestListAllIPv6Resolved(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP, "net/tcp6": mockTCP6})
+	env, stdout, stderr := mockEnv(map[string]string{"::1": "localhost."})
+
+	if code := run([]string{"list", "-a", "-6", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	output := stdout.String()
+	if strings.Contains(output, "192.168.1.3") {
+		t.Errorf("expected no IPv4 connections, got:\n%s", output)
+	}
+
+	for _, expected := range []string{"localhost:631", "[::]:*", "localhost:6789", "localhost:49018"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"list", "-a", "-json", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d:\n%s", len(lines), stdout)
+	}
+
+	conn := new(tcpconnparser.Connection)
+	if err := json.Unmarshal([]byte(lines[0]), conn); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if conn.State != tcpconnparser.StateListen || conn.LocalPort != 6789 {
+		t.Errorf("unexpected connection %v", conn)
+	}
+}
+
+func TestListCapturedFiles(t *testing.T) {
+	dir := t.TempDir()
+	tcpFile := filepath.Join(dir, "tcp.txt")
+	tcp6File := filepath.Join(dir, "tcp6.txt")
+	os.WriteFile(tcpFile, []byte(mockTCP), 0o644)
+	os.WriteFile(tcp6File, []byte(mockTCP6), 0o644)
+
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"list", "-a", "-n", "-proc-root", "/nonexistent", tcpFile, tcp6File}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	if lines := strings.Count(stdout.String(), "\n"); lines != 5 {
+		t.Errorf("expected header and 4 connections, got:\n%s", stdout)
+	}
+}
+
+func TestListCapturedStdin(t *testing.T) {
+	env, stdout, stderr := mockEnv(nil)
+	env.stdin = strings.NewReader(mockTCP)
+
+	if code := run([]string{"list", "-a", "-n", "-proc-root", "/nonexistent", "-"}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	if lines := strings.Count(stdout.String(), "\n"); lines < 2 {
+		t.Errorf("expected header and connections, got:\n%s", stdout)
+	}
+}
+
+func TestListMissingFileError(t *testing.T) {
+	env, _, stderr := mockEnv(nil)
+
+	if code := run([]string{"list", filepath.Join(t.TempDir(), "missing")}, env); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+
+	t.Logf("got stderr %q", stderr)
+}
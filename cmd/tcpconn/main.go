@@ -0,0 +1,95 @@
+// Command tcpconn inspects the TCP connections of the host, as read from procfs, for use
+// where tools such as ss and netstat are not installed, e.g. minimal containers.
+//
+// Usage:
+//
+//	tcpconn <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	list     list connections in the style of ss (the default if no command is given)
+//	top      show a continuously refreshed table of connections
+//	summary  summarise connections in the style of ss -s
+//	group    show the top groups of connections by remote address, prefix, local port or UID
+//	services show the inbound connections accepted by each listening service
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Command is a subcommand of tcpconn.
+type command struct {
+	name, summary string
+	run           func(env *env, args []string) int
+}
+
+// Commands are the subcommands of tcpconn, the first being run when none is named.
+var commands = []*command{
+	{"list", "list connections in the style of ss", runList},
+	{"top", "show a continuously refreshed table of connections", runTop},
+	{"summary", "summarise connections in the style of ss -s", runSummary},
+	{"group", "show the top groups of connections by remote address, prefix, local port or UID", runGroup},
+	{"services", "show the inbound connections accepted by each listening service", runServices},
+}
+
+// Env is the environment in which a command runs, replaced in tests.
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	lookupAddr     func(addr string) ([]string, error)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], &env{
+		stdin:      os.Stdin,
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
+		lookupAddr: net.LookupAddr,
+	}))
+}
+
+// Run runs the command named by the first argument with the remaining arguments,
+// returning the exit code. If the first argument does not name a command, the
+// default command is run with all the arguments.
+func run(args []string, env *env) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			usage(env.stderr)
+			return 0
+		}
+
+		for _, cmd := range commands {
+			if args[0] == cmd.name {
+				return cmd.run(env, args[1:])
+			}
+		}
+
+		if !strings.HasPrefix(args[0], "-") {
+			fmt.Fprintf(env.stderr, "tcpconn: unknown command %q\n", args[0])
+			usage(env.stderr)
+			return 2
+		}
+	}
+
+	return commands[0].run(env, args)
+}
+
+// Usage writes the usage of tcpconn to writer.
+func usage(writer io.Writer) {
+	fmt.Fprintln(writer, "Usage: tcpconn <command> [flags] [arguments]")
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "Commands:")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(writer, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, `Run "tcpconn <command> -h" for the flags of a command.`)
+}
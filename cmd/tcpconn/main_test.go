@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mockTCP = `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0
+1: 0301A8C0:D3A0 7D10DD58:01BB 01 00000010:00000020 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1`
+
+const mockTCP6 = `sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+2: 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 31267 1 0000000000000000 100 0 0 10 0
+5: 00000000000000000000000001000000:1A85 00000000000000000000000001000000:BF7A 01 00000000:00000000 00:00000000 00000000  1000        0 394269 1 0000000000000000 20 0 0 10 -1`
+
+// MockProcRoot writes the given files beneath a temporary directory, returning its path.
+func mockProcRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+	}
+
+	return root
+}
+
+// MockEnv returns an env writing to the returned buffers, resolving addresses from names.
+func mockEnv(names map[string]string) (*env, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	return &env{
+		stdout: stdout,
+		stderr: stderr,
+		lookupAddr: func(addr string) ([]string, error) {
+			if name, ok := names[addr]; ok {
+				return []string{name}, nil
+			}
+
+			return nil, errors.New("mock lookup failure")
+		},
+	}, stdout, stderr
+}
+
+func TestRunDefaultsToList(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"-n", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	if !strings.Contains(stdout.String(), "ESTABLISHED") {
+		t.Errorf("expected listed connections, got:\n%s", stdout)
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	env, _, stderr := mockEnv(nil)
+
+	if code := run([]string{"bogus"}, env); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+
+	if !strings.Contains(stderr.String(), "Commands:") {
+		t.Errorf("expected usage, got %q", stderr)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// RunServices writes each listening service with the number of inbound connections it
+// accepted, by state.
+func runServices(env *env, args []string) int {
+	flags := flag.NewFlagSet("tcpconn services", flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(env.stderr, "Usage: tcpconn services [flags] [captured file...]")
+		flags.PrintDefaults()
+	}
+
+	src := source{stdin: env.stdin}
+	src.addFlags(flags)
+	numeric := flags.Bool("n", false, "show numeric addresses rather than resolving host names")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+
+		return 2
+	}
+
+	conns, err := src.connections(flags.Args())
+	if err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		return 1
+	}
+
+	groups := tcpconnparser.MatchListeners(conns).Groups
+	if err := writeServices(env.stdout, groups, newResolver(env.lookupAddr, *numeric)); err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// WriteServices writes the given listener groups to writer as a table with aligned columns,
+// those with the most inbound connections first. The Accept-Q of a group is the summed
+// accept queues of its listeners.
+func writeServices(writer io.Writer, groups []*tcpconnparser.ListenerGroup, resolver *resolver) error {
+	groups = append([]*tcpconnparser.ListenerGroup(nil), groups...)
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].Accepted) != len(groups[j].Accepted) {
+			return len(groups[i].Accepted) > len(groups[j].Accepted)
+		}
+
+		return groups[i].LocalPort < groups[j].LocalPort
+	})
+
+	tw := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Service\tListeners\tAccept-Q\tConnections\tStates")
+
+	for _, group := range groups {
+		var acceptQueue uint64
+		for _, listener := range group.Listeners {
+			acceptQueue += uint64(listener.AcceptBacklog)
+		}
+
+		byState := make(map[tcpconnparser.State]int)
+		for _, conn := range group.Accepted {
+			byState[conn.State]++
+		}
+
+		states := formatStates(byState)
+		if states == "" {
+			states = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n",
+			resolver.endpoint(group.LocalAddr, strconv.Itoa(int(group.LocalPort))),
+			len(group.Listeners),
+			acceptQueue,
+			len(group.Accepted),
+			states)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing services: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// MockTCP6Wildcard is mockTCP6 with a wildcard listener on port 6789.
+const mockTCP6Wildcard = mockTCP6 + `
+6: 00000000000000000000000000000000:1A85 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 400000 1 0000000000000000 100 0 0 10 0`
+
+func TestServices(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP, "net/tcp6": mockTCP6Wildcard})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"services", "-n", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	// The outbound IPv4 connection matches no listener, and the IPv6 connection to port
+	// 6789 matches the IPv6 wildcard listener rather than the IPv4 listener
+	expected := `Service         Listeners  Accept-Q  Connections  States
+[::]:6789       1          0         1            ESTABLISHED=1
+[::1]:631       1          0         0            -
+127.0.0.1:6789  1          50        0            -
+`
+	if stdout.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, stdout)
+	}
+}
+
+func TestServicesResolved(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP, "net/tcp6": mockTCP6Wildcard})
+	env, stdout, stderr := mockEnv(map[string]string{"::1": "localhost."})
+
+	if code := run([]string{"services", "-6", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	output := stdout.String()
+	if !strings.Contains(output, "localhost:631") || strings.Contains(output, "127.0.0.1") {
+		t.Errorf("expected only resolved IPv6 services, got:\n%s", output)
+	}
+}
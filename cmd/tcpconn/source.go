@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Source selects where connections are read from, shared by the commands.
+type source struct {
+	procRoot   string
+	ipv4, ipv6 bool
+	stdin      io.Reader // Read for a captured file named "-"
+}
+
+// AddFlags defines the flags selecting the source on flags.
+func (s *source) addFlags(flags *flag.FlagSet) {
+	flags.StringVar(&s.procRoot, "proc-root", "/proc", "directory from which procfs files are read")
+	flags.BoolVar(&s.ipv4, "4", false, "show only IPv4 connections")
+	flags.BoolVar(&s.ipv6, "6", false, "show only IPv6 connections")
+}
+
+// ProtocolVersions returns the protocol versions selected by the flags, both if neither
+// or both of -4 and -6 are given.
+func (s *source) protocolVersions() []tcpconnparser.ProtocolVersion {
+	switch {
+	case s.ipv4 && !s.ipv6:
+		return []tcpconnparser.ProtocolVersion{tcpconnparser.ProtocolVersionIPv4}
+	case s.ipv6 && !s.ipv4:
+		return []tcpconnparser.ProtocolVersion{tcpconnparser.ProtocolVersionIPv6}
+	default:
+		return []tcpconnparser.ProtocolVersion{tcpconnparser.ProtocolVersionIPv4, tcpconnparser.ProtocolVersionIPv6}
+	}
+}
+
+// Parser returns a Parser reading from the selected procfs root.
+func (s *source) parser() *tcpconnparser.Parser {
+	return tcpconnparser.NewParser(tcpconnparser.WithProcRoot(s.procRoot),
+		tcpconnparser.WithUnknownStates())
+}
+
+// Connections returns the connections of the selected protocol versions, read from the
+// captured files if any are given, or from procfs otherwise. Protocol versions whose
+// procfs files do not exist, such as IPv6 on hosts where it is disabled, are skipped.
+func (s *source) connections(files []string) ([]*tcpconnparser.Connection, error) {
+	if len(files) > 0 {
+		return s.fileConnections(files)
+	}
+
+	return s.parser().GetAllConnections(s.protocolVersions()...)
+}
+
+// FileConnections returns the connections of the selected protocol versions read from the
+// given captured copies of /proc/net/tcp or /proc/net/tcp6. The protocol version of each
+// file is detected from its contents. A file named "-" is read from standard input.
+func (s *source) fileConnections(files []string) ([]*tcpconnparser.Connection, error) {
+	parser := s.parser()
+	selected := make(map[tcpconnparser.ProtocolVersion]bool)
+	for _, protocolVersion := range s.protocolVersions() {
+		selected[protocolVersion] = true
+	}
+
+	var conns []*tcpconnparser.Connection
+
+	for _, file := range files {
+		data, err := s.readFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		protocolVersion, err := tcpconnparser.DetectProtocolVersion(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file, err)
+		}
+
+		if !selected[protocolVersion] {
+			continue
+		}
+
+		fileConns, err := parser.GetConnectionsFromReader(bytes.NewReader(data), protocolVersion)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file, err)
+		}
+
+		conns = append(conns, fileConns...)
+	}
+
+	return conns, nil
+}
+
+// ReadFile reads the named file, or the standard input of the source if named "-".
+func (s *source) readFile(name string) ([]byte, error) {
+	var data []byte
+	var err error
+
+	if name == "-" {
+		data, err = io.ReadAll(s.stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	return data, nil
+}
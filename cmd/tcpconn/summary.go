@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// RunSummary writes summary statistics of the connections in the style of ss -s,
+// alongside the socket statistics reported by the kernel.
+func runSummary(env *env, args []string) int {
+	flags := flag.NewFlagSet("tcpconn summary", flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(env.stderr, "Usage: tcpconn summary [flags] [captured file...]")
+		flags.PrintDefaults()
+	}
+
+	src := source{stdin: env.stdin}
+	src.addFlags(flags)
+	numeric := flags.Bool("n", false, "show numeric addresses rather than resolving host names")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+
+		return 2
+	}
+
+	conns, err := src.connections(flags.Args())
+	if err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		return 1
+	}
+
+	// The kernel statistics describe the host, so are not shown for captured files
+	var sockStat *tcpconnparser.SockStat
+	if flags.NArg() == 0 {
+		if sockStat, err = src.parser().GetSockStat(); err != nil {
+			fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		}
+	}
+
+	summary := tcpconnparser.Summarise(conns)
+	if err := writeSummary(env.stdout, summary, sockStat, newResolver(env.lookupAddr, *numeric)); err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// WriteSummary writes the given summary, and the kernel socket statistics if not nil, to writer.
+func writeSummary(writer io.Writer,
+	summary *tcpconnparser.Summary,
+	sockStat *tcpconnparser.SockStat,
+	resolver *resolver) error {
+	tw := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Total:\t%d connections (%d listening, %d TIME-WAIT, %d orphaned)\n",
+		summary.Total, summary.Listeners, summary.TimeWait, summary.Orphans)
+
+	if sockStat != nil {
+		fmt.Fprintf(tw, "Kernel:\t%d sockets used; TCP: %d in use (%d IPv6), %d orphaned, %d TIME-WAIT, %d allocated, %d pages of buffers\n",
+			sockStat.SocketsUsed,
+			sockStat.TCP.InUse,
+			sockStat.TCP6InUse,
+			sockStat.TCP.Orphan,
+			sockStat.TCP.TimeWait,
+			sockStat.TCP.Alloc,
+			sockStat.TCP.MemPages)
+	}
+
+	fmt.Fprintf(tw, "Queues:\t%d bytes received, %d bytes to send\n",
+		summary.ReceiveQueueBytes, summary.SendQueueBytes)
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Family\tConnections")
+	for _, protocolVersion := range []tcpconnparser.ProtocolVersion{
+		tcpconnparser.ProtocolVersionIPv4,
+		tcpconnparser.ProtocolVersionIPv6,
+	} {
+		fmt.Fprintf(tw, "%s\t%d\n", protocolVersion, summary.ByProtocolVersion[protocolVersion])
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "State\tConnections")
+	for _, state := range summaryStates() {
+		if count := summary.ByState[state]; count > 0 {
+			fmt.Fprintf(tw, "%s\t%d\n", state, count)
+		}
+	}
+
+	if len(summary.BackloggedListeners) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "Listener\tAccept-Q")
+		for _, conn := range summary.BackloggedListeners {
+			fmt.Fprintf(tw, "%s\t%d\n",
+				resolver.endpoint(conn.LocalAddr, strconv.Itoa(int(conn.LocalPort))),
+				conn.AcceptBacklog)
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing summary: %w", err)
+	}
+
+	return nil
+}
+
+// SummaryStates returns the States in the order of the kernel states, followed by StateUnknown.
+func summaryStates() []tcpconnparser.State {
+	var states []tcpconnparser.State
+	seen := make(map[tcpconnparser.State]bool)
+
+	for ks := tcpconnparser.KernelStateEstablished; ks.Known(); ks++ {
+		if state, err := ks.State(); err == nil && !seen[state] {
+			states = append(states, state)
+			seen[state] = true
+		}
+	}
+
+	return append(states, tcpconnparser.StateUnknown)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{
+		"net/tcp":       mockTCP,
+		"net/tcp6":      mockTCP6,
+		"net/sockstat":  "sockets: used 42\nTCP: inuse 4 orphan 1 tw 2 alloc 7 mem 3\n",
+		"net/sockstat6": "TCP6: inuse 2\n",
+	})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"summary", "-n", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	output := stdout.String()
+	expected := []string{
+		"Total:   4 connections (2 listening, 0 TIME-WAIT, 0 orphaned)",
+		"Kernel:  42 sockets used; TCP: 4 in use (2 IPv6), 1 orphaned, 2 TIME-WAIT, 7 allocated, 3 pages of buffers",
+		"Queues:  32 bytes received, 16 bytes to send",
+		"IPv4    2\n",
+		"IPv6    2\n",
+		"ESTABLISHED  2\n",
+		"LISTEN       2\n",
+		"Listener        Accept-Q\n127.0.0.1:6789  50\n",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected %q in output:\n%s", line, output)
+		}
+	}
+}
+
+func TestSummaryWithoutSockStat(t *testing.T) {
+	root := mockProcRoot(t, map[string]string{"net/tcp": mockTCP})
+	env, stdout, stderr := mockEnv(nil)
+
+	if code := run([]string{"summary", "-proc-root", root}, env); code != 0 {
+		t.Fatalf("expected exit code 0, got %d with stderr %q", code, stderr)
+	}
+
+	if strings.Contains(stdout.String(), "Kernel:") {
+		t.Errorf("expected no kernel statistics, got:\n%s", stdout)
+	}
+
+	if stderr.Len() == 0 {
+		t.Error("expected missing sockstat to be reported")
+	}
+}
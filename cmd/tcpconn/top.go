@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences used to draw the display.
+const (
+	ansiClear   = "\x1b[H\x1b[2J"
+	ansiReverse = "\x1b[7m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiRed     = "\x1b[31m"
+	ansiReset   = "\x1b[0m"
+)
+
+// Keys handled by top.
+const (
+	keyQuit      = 'q'
+	keySort      = 's'
+	keyReverse   = 'r'
+	keyFilter    = '/'
+	keyRefresh   = ' '
+	keyEnter     = '\r'
+	keyNewline   = '\n'
+	keyEscape    = 0x1b
+	keyBackspace = 0x7f
+	keyCtrlH     = 0x08
+	keyInterrupt = 0x03
+)
+
+// Number of lines of the display above the table rows.
+const topHeaderLines = 3
+
+// SortKey is an order in which top lists connections.
+type sortKey int
+
+const (
+	sortByQueue  sortKey = iota // Descending summed receive and send queues
+	sortByState                 // State, in the order of the kernel states
+	sortByRemote                // Remote address and port
+
+	noOfSortKeys
+)
+
+// String returns the name of this sortKey as shown in the display.
+func (k sortKey) String() string {
+	switch k {
+	case sortByQueue:
+		return "queue"
+	case sortByState:
+		return "state"
+	case sortByRemote:
+		return "remote"
+	default:
+		return "sortKey(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Terminal is the display on which top draws, replaced in tests by a fake.
+type terminal interface {
+	io.Writer
+
+	// Size returns the width and height of the display, in characters.
+	size() (width, height int)
+}
+
+// Top is the state of the top display.
+type top struct {
+	term     terminal
+	source   func() ([]*tcpconnparser.Connection, error)
+	resolver *resolver
+
+	sortKey    sortKey
+	reverse    bool
+	filter     filter
+	filterExpr string
+
+	editing bool   // Whether a filter expression is being typed
+	input   []byte // The filter expression being typed
+
+	conns  []*tcpconnparser.Connection
+	diff   *tcpconnparser.Diff
+	added  map[*tcpconnparser.Connection]bool
+	change map[*tcpconnparser.Connection]bool
+	status string
+	took   time.Time
+}
+
+// NewTop constructs a new top drawing on term the connections returned by source.
+func newTop(term terminal, source func() ([]*tcpconnparser.Connection, error), resolver *resolver) *top {
+	return &top{
+		term:     term,
+		source:   source,
+		resolver: resolver,
+		filter:   func(*tcpconnparser.Connection) bool { return true },
+	}
+}
+
+// RunTop shows a continuously refreshed table of connections, highlighting connections
+// opened, changed and closed since the previous refresh.
+func runTop(env *env, args []string) int {
+	flags := flag.NewFlagSet("tcpconn top", flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(env.stderr, "Usage: tcpconn top [flags]")
+		fmt.Fprintln(env.stderr)
+		fmt.Fprintln(env.stderr, "Keys: s cycle sort order, r reverse sort, / filter, space refresh, q quit.")
+		fmt.Fprintln(env.stderr, "Filters compare fields with values, combined with &&, || and !, e.g.")
+		fmt.Fprintln(env.stderr, "  state == ESTABLISHED && (rport == 443 || raddr == 10.0.0.0/8) && rxq > 0")
+		fmt.Fprintln(env.stderr)
+		flags.PrintDefaults()
+	}
+
+	var src source
+	src.addFlags(flags)
+	interval := flags.Duration("interval", 2*time.Second, "interval between refreshes")
+	numeric := flags.Bool("n", false, "show numeric addresses rather than resolving host names")
+	filterExpr := flags.String("filter", "", "initial filter expression")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+
+		return 2
+	}
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if *interval <= 0 {
+		fmt.Fprintf(env.stderr, "tcpconn: illegal interval: %v\n", *interval)
+		return 2
+	}
+
+	// The filter is checked before the terminal, so that it is reported as a usage error
+	filter, err := parseFilter(*filterExpr)
+	if err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: parsing filter: %v\n", err)
+		return 2
+	}
+
+	stdin, ok := env.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(stdin.Fd())) {
+		fmt.Fprintln(env.stderr, "tcpconn: top requires a terminal")
+		return 1
+	}
+
+	t := newTop(&ttyTerminal{writer: env.stdout, fd: int(stdin.Fd())},
+		func() ([]*tcpconnparser.Connection, error) { return src.connections(nil) },
+		newResolver(env.lookupAddr, *numeric))
+
+	t.filter, t.filterExpr = filter, strings.TrimSpace(*filterExpr)
+
+	state, err := term.MakeRaw(int(stdin.Fd()))
+	if err != nil {
+		fmt.Fprintf(env.stderr, "tcpconn: setting terminal to raw mode: %v\n", err)
+		return 1
+	}
+	defer term.Restore(int(stdin.Fd()), state)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	t.run(readKeys(stdin), ticker.C)
+	fmt.Fprint(env.stdout, ansiClear)
+
+	return 0
+}
+
+// ReadKeys returns a channel of the bytes read from reader, closed when reading fails.
+func readKeys(reader io.Reader) <-chan byte {
+	keys := make(chan byte)
+
+	go func() {
+		defer close(keys)
+
+		buf := make([]byte, 1)
+		for {
+			if _, err := reader.Read(buf); err != nil {
+				return
+			}
+
+			keys <- buf[0]
+		}
+	}()
+
+	return keys
+}
+
+// Run refreshes and draws the display, then handles keys and refreshes at each tick
+// until the quit key is pressed or keys is closed.
+func (t *top) run(keys <-chan byte, ticks <-chan time.Time) {
+	t.refresh()
+	t.draw()
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || t.handleKey(key) {
+				return
+			}
+		case <-ticks:
+			t.refresh()
+		}
+
+		t.draw()
+	}
+}
+
+// Refresh reads the connections afresh, diffing them with those previously read.
+// If reading fails, the previous connections are kept and the error is shown.
+func (t *top) refresh() {
+	conns, err := t.source()
+	if err != nil {
+		t.status = err.Error()
+		return
+	}
+
+	if t.conns != nil {
+		t.diff = tcpconnparser.DiffConnections(t.conns, conns)
+	} else {
+		t.diff = new(tcpconnparser.Diff)
+	}
+
+	t.added = make(map[*tcpconnparser.Connection]bool, len(t.diff.Added))
+	for _, conn := range t.diff.Added {
+		t.added[conn] = true
+	}
+
+	t.change = make(map[*tcpconnparser.Connection]bool, len(t.diff.Changed))
+	for _, conn := range t.diff.Changed {
+		t.change[conn] = true
+	}
+
+	t.conns = conns
+	t.status = ""
+	t.took = time.Now()
+}
+
+// HandleKey handles a key press, returning whether top should quit.
+func (t *top) handleKey(key byte) bool {
+	if key == keyInterrupt {
+		return true
+	}
+
+	if t.editing {
+		switch key {
+		case keyEnter, keyNewline:
+			if err := t.setFilter(string(t.input)); err != nil {
+				t.status = err.Error()
+				return false
+			}
+
+			t.editing = false
+			t.status = ""
+		case keyEscape:
+			t.editing = false
+			t.status = ""
+		case keyBackspace, keyCtrlH:
+			if len(t.input) > 0 {
+				t.input = t.input[:len(t.input)-1]
+			}
+		default:
+			if key >= ' ' && key < keyBackspace {
+				t.input = append(t.input, key)
+			}
+		}
+
+		return false
+	}
+
+	switch key {
+	case keyQuit:
+		return true
+	case keySort:
+		t.sortKey = (t.sortKey + 1) % noOfSortKeys
+	case keyReverse:
+		t.reverse = !t.reverse
+	case keyFilter:
+		t.editing = true
+		t.input = []byte(t.filterExpr)
+	case keyRefresh:
+		t.refresh()
+	}
+
+	return false
+}
+
+// SetFilter sets the filter expression of the display.
+func (t *top) setFilter(expr string) error {
+	f, err := parseFilter(expr)
+	if err != nil {
+		return err
+	}
+
+	t.filter = f
+	t.filterExpr = strings.TrimSpace(expr)
+
+	return nil
+}
+
+// Row is a connection shown in the display, and the colour in which it is highlighted.
+type row struct {
+	conn   *tcpconnparser.Connection
+	colour string
+	closed bool
+}
+
+// Rows returns the connections to show, filtered and sorted, including those closed
+// since the previous refresh.
+func (t *top) rows() []row {
+	rows := make([]row, 0, len(t.conns))
+	for _, conn := range t.conns {
+		if !t.filter(conn) {
+			continue
+		}
+
+		r := row{conn: conn}
+		switch {
+		case t.added[conn]:
+			r.colour = ansiGreen
+		case t.change[conn]:
+			r.colour = ansiYellow
+		}
+
+		rows = append(rows, r)
+	}
+
+	if t.diff != nil {
+		for _, conn := range t.diff.Removed {
+			if t.filter(conn) {
+				rows = append(rows, row{conn: conn, colour: ansiRed, closed: true})
+			}
+		}
+	}
+
+	less := t.less()
+	sort.SliceStable(rows, func(i, j int) bool {
+		if t.reverse {
+			return less(rows[j].conn, rows[i].conn)
+		}
+
+		return less(rows[i].conn, rows[j].conn)
+	})
+
+	return rows
+}
+
+// Less returns the ordering of connections by the selected sortKey.
+func (t *top) less() func(a, b *tcpconnparser.Connection) bool {
+	switch t.sortKey {
+	case sortByState:
+		return func(a, b *tcpconnparser.Connection) bool {
+			return a.KernelState < b.KernelState
+		}
+	case sortByRemote:
+		return func(a, b *tcpconnparser.Connection) bool {
+			if c := bytes.Compare(a.RemoteAddr.To16(), b.RemoteAddr.To16()); c != 0 {
+				return c < 0
+			}
+
+			return a.RemotePort < b.RemotePort
+		}
+	default:
+		return func(a, b *tcpconnparser.Connection) bool {
+			return queueSize(a) > queueSize(b)
+		}
+	}
+}
+
+// QueueSize returns the summed queues of the given connection, being the accept
+// queue of a listener.
+func queueSize(conn *tcpconnparser.Connection) uint64 {
+	if conn.State == tcpconnparser.StateListen {
+		return uint64(conn.AcceptBacklog)
+	}
+
+	return uint64(conn.ReceiveBufferSize) + uint64(conn.SendBufferSize)
+}
+
+// Draw draws the display on the terminal, truncated to its size.
+func (t *top) draw() {
+	width, height := t.term.size()
+	rows := t.rows()
+
+	var out strings.Builder
+	out.WriteString(ansiClear)
+
+	added, removed, changed := 0, 0, 0
+	if t.diff != nil {
+		added, removed, changed = len(t.diff.Added), len(t.diff.Removed), len(t.diff.Changed)
+	}
+
+	writeLine(&out, width, "", fmt.Sprintf("tcpconn top - %s - %d connections, %d shown: %d new, %d changed, %d closed",
+		t.took.Format("15:04:05"), len(t.conns), len(rows), added, changed, removed))
+
+	sortOrder := t.sortKey.String()
+	if t.reverse {
+		sortOrder += " (reversed)"
+	}
+
+	switch {
+	case t.editing && t.status != "":
+		writeLine(&out, width, "", "filter: "+string(t.input)+"_  ("+t.status+")")
+	case t.editing:
+		writeLine(&out, width, "", "filter: "+string(t.input)+"_")
+	case t.status != "":
+		writeLine(&out, width, ansiRed, t.status)
+	default:
+		writeLine(&out, width, "", fmt.Sprintf("sort: %s  filter: %s  [s]ort [r]everse [/]filter [q]uit",
+			sortOrder, t.filterExpr))
+	}
+
+	maxRows := height - topHeaderLines
+	if maxRows < 0 {
+		maxRows = 0
+	}
+
+	if len(rows) > maxRows {
+		rows = rows[:maxRows]
+	}
+
+	// Align the columns without the highlighting, which would skew the widths
+	var table bytes.Buffer
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "State\tRecv-Q\tSend-Q\tLocal\tPeer\tUID\tInode")
+
+	for _, r := range rows {
+		conn := r.conn
+		state := string(conn.State)
+		if r.closed {
+			state = "(closed)"
+		}
+
+		recvQ, sendQ := conn.ReceiveBufferSize, conn.SendBufferSize
+		peer := t.resolver.endpoint(conn.RemoteAddr, strconv.Itoa(int(conn.RemotePort)))
+
+		if conn.State == tcpconnparser.StateListen {
+			recvQ, sendQ = conn.AcceptBacklog, 0
+			peer = t.resolver.endpoint(unspecifiedAddr(conn.ProtocolVersion), "*")
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%d\t%d\n",
+			state,
+			recvQ,
+			sendQ,
+			t.resolver.endpoint(conn.LocalAddr, strconv.Itoa(int(conn.LocalPort))),
+			peer,
+			conn.UID,
+			conn.INode)
+	}
+
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	writeLine(&out, width, ansiReverse, lines[0])
+	for i, line := range lines[1:] {
+		writeLine(&out, width, rows[i].colour, line)
+	}
+
+	io.WriteString(t.term, out.String())
+}
+
+// WriteLine writes line to out in the given colour, if any, truncated to width.
+// Lines are terminated with a carriage return, as the terminal is in raw mode.
+func writeLine(out *strings.Builder, width int, colour, line string) {
+	if width > 0 && len(line) > width {
+		line = line[:width]
+	}
+
+	if colour != "" {
+		out.WriteString(colour)
+		out.WriteString(line)
+		out.WriteString(ansiReset)
+	} else {
+		out.WriteString(line)
+	}
+
+	out.WriteString("\r\n")
+}
+
+// TTYTerminal is a terminal writing to writer, sized by the terminal fd.
+type ttyTerminal struct {
+	writer io.Writer
+	fd     int
+}
+
+func (t *ttyTerminal) Write(p []byte) (int, error) {
+	return t.writer.Write(p)
+}
+
+// Size returns the size of the terminal, or 80 by 24 if it cannot be found.
+func (t *ttyTerminal) size() (int, int) {
+	width, height, err := term.GetSize(t.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+
+	return width, height
+}
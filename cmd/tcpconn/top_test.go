@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// FakeTerminal is a terminal of a fixed size recording what is drawn on it.
+type fakeTerminal struct {
+	bytes.Buffer
+	width, height int
+}
+
+func (t *fakeTerminal) size() (int, int) {
+	return t.width, t.height
+}
+
+// Frame returns the last frame drawn on the terminal, split into lines.
+func (t *fakeTerminal) frame() []string {
+	frames := strings.Split(t.String(), ansiClear)
+	return strings.Split(strings.TrimSuffix(frames[len(frames)-1], "\r\n"), "\r\n")
+}
+
+// ScriptedSource returns a source returning each of the given snapshots in turn,
+// repeating the last.
+func scriptedSource(snapshots ...[]*tcpconnparser.Connection) func() ([]*tcpconnparser.Connection, error) {
+	return func() ([]*tcpconnparser.Connection, error) {
+		snapshot := snapshots[0]
+		if len(snapshots) > 1 {
+			snapshots = snapshots[1:]
+		}
+
+		return snapshot, nil
+	}
+}
+
+var (
+	mockListener = tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv4,
+		3, net.IPv4(0, 0, 0, 0), 80, 0, 100)
+	mockBusy = tcpconnparser.NewConnection(tcpconnparser.StateEstablished, tcpconnparser.ProtocolVersionIPv4,
+		500, 20, net.IPv4(10, 0, 0, 1), 80, net.IPv4(10, 0, 0, 9), 40000, 0, 101)
+	mockIdle = tcpconnparser.NewConnection(tcpconnparser.StateEstablished, tcpconnparser.ProtocolVersionIPv4,
+		0, 0, net.IPv4(10, 0, 0, 1), 80, net.IPv4(10, 0, 0, 2), 40001, 0, 102)
+	mockOpened = tcpconnparser.NewConnection(tcpconnparser.StateEstablished, tcpconnparser.ProtocolVersionIPv4,
+		10, 0, net.IPv4(10, 0, 0, 1), 80, net.IPv4(192, 0, 2, 1), 40002, 0, 103)
+)
+
+// NewMockTop returns a top drawing on a fake terminal the given scripted snapshots.
+func newMockTop(height int, snapshots ...[]*tcpconnparser.Connection) (*top, *fakeTerminal) {
+	term := &fakeTerminal{width: 120, height: height}
+	return newTop(term, scriptedSource(snapshots...), newResolver(nil, true)), term
+}
+
+// RunScript runs t, pressing each of keys in turn, then closes the keys to quit.
+func runScript(t *top, ticks <-chan time.Time, keys string) {
+	keyCh := make(chan byte, len(keys))
+	for i := 0; i < len(keys); i++ {
+		keyCh <- keys[i]
+	}
+
+	close(keyCh)
+	t.run(keyCh, ticks)
+}
+
+// RowOf returns the line of frame containing str, or the empty string.
+func rowOf(frame []string, str string) string {
+	for _, line := range frame {
+		if strings.Contains(line, str) {
+			return line
+		}
+	}
+
+	return ""
+}
+
+func TestTopSortsByQueueDescending(t *testing.T) {
+	top, term := newMockTop(24, []*tcpconnparser.Connection{mockIdle, mockListener, mockBusy})
+	runScript(top, nil, "")
+
+	frame := term.frame()
+	if len(frame) != topHeaderLines+3 {
+		t.Fatalf("expected %d lines, got %d:\n%s", topHeaderLines+3, len(frame), strings.Join(frame, "\n"))
+	}
+
+	for i, expected := range []string{"10.0.0.9:40000", "0.0.0.0:*", "10.0.0.2:40001"} {
+		if line := frame[topHeaderLines+i]; !strings.Contains(line, expected) {
+			t.Errorf("expected row %d to contain %q, got %q", i, expected, line)
+		}
+	}
+}
+
+func TestTopSortKeys(t *testing.T) {
+	top, term := newMockTop(24, []*tcpconnparser.Connection{mockIdle, mockListener, mockBusy})
+
+	// Sort by state, then by remote address reversed
+	runScript(top, nil, "s")
+	if line := term.frame()[topHeaderLines]; !strings.Contains(line, "ESTABLISHED") {
+		t.Errorf("expected first row to be established, got %q", line)
+	}
+
+	top, term = newMockTop(24, []*tcpconnparser.Connection{mockIdle, mockListener, mockBusy})
+	runScript(top, nil, "ssr")
+
+	frame := term.frame()
+	if !strings.Contains(frame[1], "sort: remote (reversed)") {
+		t.Errorf("expected sort order in status line, got %q", frame[1])
+	}
+
+	if line := frame[topHeaderLines]; !strings.Contains(line, "10.0.0.9:40000") {
+		t.Errorf("expected first row to have the highest remote address, got %q", line)
+	}
+}
+
+func TestTopHighlightsDiff(t *testing.T) {
+	closing := *mockIdle
+	closing.State = tcpconnparser.StateCloseWait
+
+	top, term := newMockTop(24,
+		[]*tcpconnparser.Connection{mockListener, mockBusy, mockIdle},
+		[]*tcpconnparser.Connection{mockListener, &closing, mockOpened})
+
+	ticks := make(chan time.Time, 1)
+	ticks <- time.Now()
+
+	// Block the keys until the tick has been handled
+	keys := make(chan byte)
+	done := make(chan struct{})
+	go func() {
+		top.run(keys, ticks)
+		close(done)
+	}()
+
+	for len(ticks) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	keys <- keyRefresh // Redraws the second snapshot without a further diff
+	close(keys)
+	<-done
+
+	frames := strings.Split(term.String(), ansiClear)
+	frame := strings.Split(frames[2], "\r\n")
+
+	if !strings.Contains(frame[0], "1 new, 1 changed, 1 closed") {
+		t.Errorf("expected diff summary, got %q", frame[0])
+	}
+
+	tests := []struct {
+		contains, colour string
+	}{
+		{"192.0.2.1:40002", ansiGreen},
+		{"CLOSE-WAIT", ansiYellow},
+		{"(closed)", ansiRed},
+	}
+
+	for _, test := range tests {
+		line := rowOf(frame, test.contains)
+		if !strings.HasPrefix(line, test.colour) {
+			t.Errorf("expected row containing %q to be highlighted with %q, got %q", test.contains, test.colour, line)
+		}
+	}
+
+	if line := rowOf(frame, "0.0.0.0:*"); strings.HasPrefix(line, "\x1b") {
+		t.Errorf("expected unchanged row not to be highlighted, got %q", line)
+	}
+
+	if !strings.Contains(term.frame()[0], "0 new, 0 changed, 0 closed") {
+		t.Errorf("expected no differences after refresh, got %q", term.frame()[0])
+	}
+}
+
+func TestTopFilter(t *testing.T) {
+	top, term := newMockTop(24, []*tcpconnparser.Connection{mockIdle, mockListener, mockBusy})
+	runScript(top, nil, "/state == established && rxq > 0\r")
+
+	frame := term.frame()
+	if len(frame) != topHeaderLines+1 {
+		t.Fatalf("expected 1 row, got:\n%s", strings.Join(frame, "\n"))
+	}
+
+	if !strings.Contains(frame[topHeaderLines], "10.0.0.9:40000") {
+		t.Errorf("expected filtered row, got %q", frame[topHeaderLines])
+	}
+
+	if !strings.Contains(frame[1], "filter: state == established && rxq > 0") {
+		t.Errorf("expected filter in status line, got %q", frame[1])
+	}
+}
+
+func TestTopFilterEditing(t *testing.T) {
+	top, term := newMockTop(24, []*tcpconnparser.Connection{mockIdle, mockListener, mockBusy})
+
+	// An invalid filter is reported and left open for editing, and escape abandons it
+	runScript(top, nil, "/bogus\r")
+	if line := term.frame()[1]; !strings.Contains(line, "unknown field") {
+		t.Errorf("expected filter error, got %q", line)
+	}
+
+	runScript(top, nil, "\x1b/lport == 8\x7f80\r")
+	if lines := len(term.frame()); lines != topHeaderLines+3 {
+		t.Errorf("expected 3 rows, got %d", lines-topHeaderLines)
+	}
+}
+
+func TestTopTruncatesToTerminal(t *testing.T) {
+	top, term := newMockTop(topHeaderLines+1, []*tcpconnparser.Connection{mockIdle, mockListener, mockBusy})
+	term.width = 20
+	runScript(top, nil, "")
+
+	frame := term.frame()
+	if len(frame) != topHeaderLines+1 {
+		t.Errorf("expected %d lines, got %d", topHeaderLines+1, len(frame))
+	}
+
+	for _, line := range frame {
+		plain := strings.NewReplacer(ansiReverse, "", ansiReset, "").Replace(line)
+		if len(plain) > 20 {
+			t.Errorf("expected line of at most 20 characters, got %q", plain)
+		}
+	}
+}
+
+func TestTopShowsSourceError(t *testing.T) {
+	term := &fakeTerminal{width: 120, height: 24}
+	top := newTop(term, func() ([]*tcpconnparser.Connection, error) {
+		return nil, errors.New("mock error")
+	}, newResolver(nil, true))
+	runScript(top, nil, "")
+
+	if line := term.frame()[1]; !strings.Contains(line, "mock error") {
+		t.Errorf("expected error in status line, got %q", line)
+	}
+}
+
+func TestTopQuits(t *testing.T) {
+	top, _ := newMockTop(24, []*tcpconnparser.Connection{mockIdle})
+
+	keys := make(chan byte, 1)
+	keys <- keyQuit
+	top.run(keys, nil) // Returns without keys being closed
+}
+
+func TestTopIllegalFilterError(t *testing.T) {
+	for _, expr := range []string{"state == FOO", "family == IPv5"} {
+		env, _, stderr := mockEnv(nil)
+		if code := run([]string{"top", "-filter", expr}, env); code != 2 {
+			t.Errorf("expected exit code 2 for filter %q, got %d", expr, code)
+		}
+
+		t.Logf("got error %q", stderr)
+	}
+}
+
+func TestTopIllegalIntervalError(t *testing.T) {
+	for _, interval := range []string{"0", "-1s"} {
+		env, _, stderr := mockEnv(nil)
+		if code := run([]string{"top", "-interval", interval}, env); code != 2 {
+			t.Errorf("expected exit code 2 for interval %s, got %d", interval, code)
+		}
+
+		t.Logf("got error %q", stderr)
+	}
+}
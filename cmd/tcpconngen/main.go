@@ -0,0 +1,261 @@
+// Command tcpconngen writes synthetic /proc/net/tcp and /proc/net/tcp6 tables, for use
+// as fixtures when benchmarking and testing. The tables are written beneath the output
+// directory in the layout of procfs, so that they can be read by a Parser configured
+// with WithProcRoot. When more than one snapshot is requested, each is written to its
+// own numbered directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jhwbarlow/tcpconnparser"
+	"github.com/jhwbarlow/tcpconnparser/generator"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// Run runs the command with the given arguments, returning the exit code.
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tcpconngen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	seed := flags.Int64("seed", 1, "seed from which the tables are generated")
+	sockets := flags.Int("sockets", 10000, "number of non-listening sockets in each snapshot")
+	ipv6Fraction := flags.Float64("ipv6", 0.2, "fraction of sockets which are IPv6")
+	states := flags.String("states", "", "comma-separated relative state weights, e.g. TCP_ESTABLISHED=60,TCP_TIME_WAIT=40")
+	listenPorts := flags.String("listen-ports", "", "comma-separated local ports of listeners (default 22,80,443,8080)")
+	maxBacklog := flags.Uint("max-backlog", 128, "largest accept backlog of a listener")
+	inboundFraction := flags.Float64("inbound", 0.5, "fraction of sockets which are inbound to a listener")
+	remotePorts := flags.String("remote-ports", "", "comma-separated remote ports of outbound sockets (default common services)")
+	localIPv4 := flags.String("local-ipv4", "", "comma-separated local IPv4 addresses (default documentation and loopback addresses)")
+	localIPv6 := flags.String("local-ipv6", "", "comma-separated local IPv6 addresses (default documentation and loopback addresses)")
+	remoteIPv4 := flags.String("remote-ipv4", "", "comma-separated IPv4 prefixes of remote addresses, e.g. 198.51.100.0/24 (default documentation prefixes)")
+	remoteIPv6 := flags.String("remote-ipv6", "", "comma-separated IPv6 prefixes of remote addresses, e.g. 2001:db8:1::/48 (default documentation prefixes)")
+	churn := flags.Float64("churn", 0.05, "fraction of sockets replaced between snapshots")
+	snapshots := flags.Int("snapshots", 1, "number of successive snapshots to write")
+	out := flags.String("out", "proc", "directory beneath which the tables are written")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := generator.DefaultConfig()
+	cfg.Seed = *seed
+	cfg.Sockets = *sockets
+	cfg.IPv6Fraction = *ipv6Fraction
+	cfg.MaxAcceptBacklog = uint32(*maxBacklog)
+	cfg.InboundFraction = *inboundFraction
+	cfg.Churn = *churn
+
+	if *states != "" {
+		weights, err := parseStateWeights(*states)
+		if err != nil {
+			fmt.Fprintf(stderr, "tcpconngen: parsing states: %v\n", err)
+			return 2
+		}
+
+		cfg.StateWeights = weights
+	}
+
+	var err error
+	if cfg.ListenPorts, err = parsePorts(*listenPorts); err != nil {
+		fmt.Fprintf(stderr, "tcpconngen: parsing listen ports: %v\n", err)
+		return 2
+	}
+
+	if cfg.RemoteDestPorts, err = parsePorts(*remotePorts); err != nil {
+		fmt.Fprintf(stderr, "tcpconngen: parsing remote ports: %v\n", err)
+		return 2
+	}
+
+	if cfg.LocalIPv4Addrs, err = parseAddrs(*localIPv4, false); err != nil {
+		fmt.Fprintf(stderr, "tcpconngen: parsing local IPv4 addresses: %v\n", err)
+		return 2
+	}
+
+	if cfg.LocalIPv6Addrs, err = parseAddrs(*localIPv6, true); err != nil {
+		fmt.Fprintf(stderr, "tcpconngen: parsing local IPv6 addresses: %v\n", err)
+		return 2
+	}
+
+	if cfg.RemoteIPv4Nets, err = parseNets(*remoteIPv4, false); err != nil {
+		fmt.Fprintf(stderr, "tcpconngen: parsing remote IPv4 prefixes: %v\n", err)
+		return 2
+	}
+
+	if cfg.RemoteIPv6Nets, err = parseNets(*remoteIPv6, true); err != nil {
+		fmt.Fprintf(stderr, "tcpconngen: parsing remote IPv6 prefixes: %v\n", err)
+		return 2
+	}
+
+	if err := generate(cfg, *snapshots, *out); err != nil {
+		fmt.Fprintf(stderr, "tcpconngen: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// Generate writes the given number of snapshots generated from cfg beneath the directory out.
+func generate(cfg generator.Config, snapshots int, out string) error {
+	g, err := generator.New(cfg)
+	if err != nil {
+		return fmt.Errorf("creating generator: %w", err)
+	}
+
+	for i := 0; i < snapshots; i++ {
+		if i > 0 {
+			g.Next()
+		}
+
+		dir := out
+		if snapshots > 1 {
+			dir = filepath.Join(out, fmt.Sprintf("%04d", i))
+		}
+
+		if err := writeSnapshot(g, dir); err != nil {
+			return fmt.Errorf("writing snapshot %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteSnapshot writes the current snapshot of g to the net/tcp and net/tcp6 files beneath dir.
+func writeSnapshot(g *generator.Generator, dir string) error {
+	netDir := filepath.Join(dir, "net")
+	if err := os.MkdirAll(netDir, 0o755); err != nil {
+		return fmt.Errorf("creating directory %q: %w", netDir, err)
+	}
+
+	files := map[string]tcpconnparser.ProtocolVersion{
+		"tcp":  tcpconnparser.ProtocolVersionIPv4,
+		"tcp6": tcpconnparser.ProtocolVersionIPv6,
+	}
+
+	for name, protocolVersion := range files {
+		path := filepath.Join(netDir, name)
+
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("creating %q: %w", path, err)
+		}
+
+		if err := g.Write(file, protocolVersion); err != nil {
+			file.Close()
+			return fmt.Errorf("writing %q: %w", path, err)
+		}
+
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("closing %q: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// ParseStateWeights parses a comma-separated list of kernel state names and weights,
+// e.g. "TCP_ESTABLISHED=60,TCP_TIME_WAIT=40".
+func parseStateWeights(str string) (map[tcpconnparser.KernelState]float64, error) {
+	weights := make(map[tcpconnparser.KernelState]float64)
+
+	for _, pair := range strings.Split(str, ",") {
+		name, weightStr, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid format: expected NAME=WEIGHT, got %q", pair)
+		}
+
+		state, err := tcpconnparser.ParseKernelStateName(name)
+		if err != nil {
+			return nil, fmt.Errorf("parsing state: %w", err)
+		}
+
+		weight, err := strconv.ParseFloat(weightStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse weight %q as number: %w", weightStr, err)
+		}
+
+		weights[state] = weight
+	}
+
+	return weights, nil
+}
+
+// ParsePorts parses a comma-separated list of ports, e.g. "80,443". An empty string
+// gives no ports, so that the generator default is used.
+func parsePorts(str string) ([]uint16, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var ports []uint16
+	for _, portStr := range strings.Split(str, ",") {
+		port, err := strconv.ParseUint(strings.TrimSpace(portStr), 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid port %q", portStr)
+		}
+
+		ports = append(ports, uint16(port))
+	}
+
+	return ports, nil
+}
+
+// ParseAddrs parses a comma-separated list of IPv4 addresses, or of IPv6 addresses if
+// ipv6 is true. An empty string gives no addresses, so that the generator default is used.
+func parseAddrs(str string, ipv6 bool) ([]net.IP, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var addrs []net.IP
+	for _, addrStr := range strings.Split(str, ",") {
+		addr := net.ParseIP(strings.TrimSpace(addrStr))
+		if addr == nil || (addr.To4() == nil) != ipv6 {
+			return nil, fmt.Errorf("invalid %s address %q", familyName(ipv6), addrStr)
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	return addrs, nil
+}
+
+// ParseNets parses a comma-separated list of IPv4 prefixes in CIDR notation, or of IPv6
+// prefixes if ipv6 is true. An empty string gives no prefixes, so that the generator
+// default is used.
+func parseNets(str string, ipv6 bool) ([]*net.IPNet, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var nets []*net.IPNet
+	for _, netStr := range strings.Split(str, ",") {
+		addr, ipNet, err := net.ParseCIDR(strings.TrimSpace(netStr))
+		if err != nil || (addr.To4() == nil) != ipv6 {
+			return nil, fmt.Errorf("invalid %s prefix %q", familyName(ipv6), netStr)
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// FamilyName returns the name of the IPv6 family if ipv6 is true, else that of IPv4.
+func familyName(ipv6 bool) string {
+	if ipv6 {
+		return "IPv6"
+	}
+
+	return "IPv4"
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+func TestParseStateWeights(t *testing.T) {
+	input := "TCP_ESTABLISHED=60, TCP_TIME_WAIT=40"
+
+	output, err := parseStateWeights(input)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(output) != 2 ||
+		output[tcpconnparser.KernelStateEstablished] != 60 ||
+		output[tcpconnparser.KernelStateTimeWait] != 40 {
+		t.Errorf("unexpected weights %v for input %q", output, input)
+	}
+
+	t.Logf("got output %v for input %q", output, input)
+}
+
+func TestParseStateWeightsUnknownStateError(t *testing.T) {
+	input := "TCP_BOGUS=1"
+
+	_, err := parseStateWeights(input)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestRunWritesReadableProcRoot(t *testing.T) {
+	out := t.TempDir()
+
+	code := run([]string{"-sockets", "100", "-snapshots", "2", "-out", out}, io.Discard)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	parser := tcpconnparser.NewParser(tcpconnparser.WithProcRoot(filepath.Join(out, "0001")))
+	conns, err := parser.GetConnections(tcpconnparser.ProtocolVersionIPv4, tcpconnparser.ProtocolVersionIPv6)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(conns) < 100 {
+		t.Errorf("expected at least 100 connections, got %d", len(conns))
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	input := "80, 8443"
+
+	output, err := parsePorts(input)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(output) != 2 || output[0] != 80 || output[1] != 8443 {
+		t.Errorf("unexpected ports %v for input %q", output, input)
+	}
+}
+
+func TestParsePortsIllegalPortError(t *testing.T) {
+	inputs := []string{"0", "65536", "http", "80,"}
+
+	for _, input := range inputs {
+		_, err := parsePorts(input)
+		if err == nil {
+			t.Errorf("expected error for input %q, got nil", input)
+		}
+
+		t.Logf("got error %q (of type %T) for input %q", err, err, input)
+	}
+}
+
+func TestParseAddrs(t *testing.T) {
+	output, err := parseAddrs("10.0.0.1,10.0.0.2", false)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(output) != 2 || !output[1].Equal(net.ParseIP("10.0.0.2")) {
+		t.Errorf("unexpected addresses %v", output)
+	}
+
+	if _, err := parseAddrs("10.0.0.1", true); err == nil {
+		t.Error("expected error for IPv4 address given as IPv6, got nil")
+	}
+}
+
+func TestParseNets(t *testing.T) {
+	output, err := parseNets("2001:db8:2::/48", true)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(output) != 1 || output[0].String() != "2001:db8:2::/48" {
+		t.Errorf("unexpected prefixes %v", output)
+	}
+
+	inputs := []string{"2001:db8:2::/48", "10.0.0.1", "10.0.0.0/33"}
+	for _, input := range inputs {
+		_, err := parseNets(input, false)
+		if err == nil {
+			t.Errorf("expected error for input %q, got nil", input)
+		}
+
+		t.Logf("got error %q (of type %T) for input %q", err, err, input)
+	}
+}
+
+func TestRunConfiguresAddressesAndPorts(t *testing.T) {
+	out := t.TempDir()
+
+	args := []string{
+		"-sockets", "100", "-ipv6", "0", "-inbound", "1", "-states", "TCP_ESTABLISHED=1", "-out", out,
+		"-listen-ports", "8443", "-local-ipv4", "10.0.0.1", "-remote-ipv4", "10.1.0.0/16",
+	}
+
+	code := run(args, io.Discard)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	parser := tcpconnparser.NewParser(tcpconnparser.WithProcRoot(out))
+	conns, err := parser.GetConnections(tcpconnparser.ProtocolVersionIPv4)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	_, remoteNet, _ := net.ParseCIDR("10.1.0.0/16")
+	for _, conn := range conns {
+		if !conn.LocalAddr.Equal(net.ParseIP("10.0.0.1")) || conn.LocalPort != 8443 {
+			t.Errorf("expected local address 10.0.0.1:8443, got %v:%d", conn.LocalAddr, conn.LocalPort)
+		}
+
+		if conn.State != tcpconnparser.StateListen && !remoteNet.Contains(conn.RemoteAddr) {
+			t.Errorf("expected remote address in %v, got %v", remoteNet, conn.RemoteAddr)
+		}
+	}
+}
+
+func TestRunIllegalFlagError(t *testing.T) {
+	argSets := [][]string{
+		{"-listen-ports", "http"},
+		{"-remote-ports", "0"},
+		{"-local-ipv4", "::1"},
+		{"-local-ipv6", "127.0.0.1"},
+		{"-remote-ipv4", "10.0.0.1"},
+		{"-remote-ipv6", "10.0.0.0/8"},
+	}
+
+	for _, args := range argSets {
+		code := run(append(args, "-out", t.TempDir()), io.Discard)
+		if code != 2 {
+			t.Errorf("expected exit code 2 for arguments %q, got %d", args, code)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 // Connection represents a TCP connection within the kernel.
 type Connection struct {
 	State                             State
+	KernelState                       KernelState
 	ReceiveBufferSize, SendBufferSize uint32 // Zero-valued for listening conns
 	AcceptBacklog                     uint32 // Zero-valued for non-listening conns
 	ProtocolVersion                   ProtocolVersion
@@ -26,6 +27,7 @@ func NewListeningConnection(protocolVersion ProtocolVersion,
 	iNode uint32) *Connection {
 	return &Connection{
 		State:           StateListen,
+		KernelState:     KernelStateListen,
 		ProtocolVersion: protocolVersion,
 		AcceptBacklog:   acceptBacklog,
 		LocalAddr:       localAddr,
@@ -36,6 +38,7 @@ func NewListeningConnection(protocolVersion ProtocolVersion,
 }
 
 // NewConnection constructs a new Connection in a non-listening state.
+// The KernelState of the Connection is that which most commonly represents the given state.
 func NewConnection(state State,
 	protocolVersion ProtocolVersion,
 	receiveBufferSize uint32,
@@ -48,6 +51,7 @@ func NewConnection(state State,
 	iNode uint32) *Connection {
 	return &Connection{
 		State:             state,
+		KernelState:       state.kernelState(),
 		ProtocolVersion:   protocolVersion,
 		ReceiveBufferSize: receiveBufferSize,
 		SendBufferSize:    SendBufferSize,
@@ -64,9 +68,10 @@ func NewConnection(state State,
 // String returns a human-readable string representation of this Connection.
 func (c *Connection) String() string {
 	if c.State == StateListen {
-		return fmt.Sprintf("State: %s, Protocol Version: %s, Accept Backlog: %d, "+
+		return fmt.Sprintf("State: %s (%s), Protocol Version: %s, Accept Backlog: %d, "+
 			"Local Address: %s:%d, UID: %d, INode: %d",
 			c.State,
+			c.KernelState,
 			c.ProtocolVersion,
 			c.AcceptBacklog,
 			c.LocalAddr,
@@ -75,10 +80,11 @@ func (c *Connection) String() string {
 			c.INode)
 	}
 
-	return fmt.Sprintf("State: %s, Protocol Version: %s, "+
+	return fmt.Sprintf("State: %s (%s), Protocol Version: %s, "+
 		"Receive Buffer Size: %d, Send Buffer Size: %d, "+
 		"Local Address: %s:%d, Remote Address: %s:%d, UID: %d, INode: %d",
 		c.State,
+		c.KernelState,
 		c.ProtocolVersion,
 		c.ReceiveBufferSize,
 		c.SendBufferSize,
@@ -97,6 +103,7 @@ func (c *Connection) Equal(conn *Connection) bool {
 	}
 
 	return c.State == conn.State &&
+		c.KernelState == conn.KernelState &&
 		c.ReceiveBufferSize == conn.ReceiveBufferSize &&
 		c.SendBufferSize == conn.SendBufferSize &&
 		c.AcceptBacklog == conn.AcceptBacklog &&
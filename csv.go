@@ -0,0 +1,383 @@
+package tcpconnparser
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Column is the name of a column of a CSV or TSV connection table.
+type Column string
+
+// Columns of a CSV or TSV connection table.
+const (
+	ColumnState       Column = "state"
+	ColumnKernelState Column = "kernel_state"
+	ColumnFamily      Column = "family"
+	ColumnLocalAddr   Column = "laddr"
+	ColumnLocalPort   Column = "lport"
+	ColumnRemoteAddr  Column = "raddr"
+	ColumnRemotePort  Column = "rport"
+	ColumnRXQueue     Column = "rxq"
+	ColumnTXQueue     Column = "txq"
+	ColumnBacklog     Column = "backlog"
+	ColumnUID         Column = "uid"
+	ColumnINode       Column = "inode"
+
+	// The processes holding the socket open, as given by the Owners of a TableEncoder.
+	// As command names are chosen by the processes, values which a spreadsheet would
+	// read as a formula, beginning with "=", "+", "-" or "@", are prefixed with "'".
+	// This column is ignored when decoding.
+	ColumnOwners Column = "owners"
+)
+
+// DefaultColumns are the columns of a connection table written if none are selected.
+var DefaultColumns = []Column{
+	ColumnState,
+	ColumnFamily,
+	ColumnLocalAddr,
+	ColumnLocalPort,
+	ColumnRemoteAddr,
+	ColumnRemotePort,
+	ColumnRXQueue,
+	ColumnTXQueue,
+	ColumnBacklog,
+	ColumnUID,
+	ColumnINode,
+}
+
+// Separator of the processes within the owners column.
+const ownersSeparator = ";"
+
+// Prefix of owners column values which would otherwise be read as a formula.
+const formulaEscape = "'"
+
+// TableEncoder writes Connections as a CSV or TSV table, with a header row naming the columns.
+type TableEncoder struct {
+	writer        *csv.Writer
+	columns       []Column
+	headerWritten bool
+
+	// Owners of each socket, keyed by inode, as returned by Parser.GetSocketOwners.
+	// Used only to write ColumnOwners.
+	Owners map[uint32][]Process
+}
+
+// NewCSVEncoder constructs a new TableEncoder writing a comma-separated table to writer,
+// with the given columns in order, or DefaultColumns if none are given.
+func NewCSVEncoder(writer io.Writer, columns ...Column) (*TableEncoder, error) {
+	return newTableEncoder(writer, ',', columns)
+}
+
+// NewTSVEncoder constructs a new TableEncoder writing a tab-separated table to writer,
+// with the given columns in order, or DefaultColumns if none are given.
+func NewTSVEncoder(writer io.Writer, columns ...Column) (*TableEncoder, error) {
+	return newTableEncoder(writer, '\t', columns)
+}
+
+// NewTableEncoder constructs a new TableEncoder writing a table separated by comma.
+func newTableEncoder(writer io.Writer, comma rune, columns []Column) (*TableEncoder, error) {
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	for _, column := range columns {
+		if !column.valid() {
+			return nil, fmt.Errorf("illegal column: %q", column)
+		}
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Comma = comma
+
+	return &TableEncoder{
+		writer:  csvWriter,
+		columns: columns,
+	}, nil
+}
+
+// Encode writes each of the given Connections as a row, preceded by the header
+// row if this is the first call.
+func (e *TableEncoder) Encode(conns ...*Connection) error {
+	if !e.headerWritten {
+		header := make([]string, 0, len(e.columns))
+		for _, column := range e.columns {
+			header = append(header, string(column))
+		}
+
+		if err := e.writer.Write(header); err != nil {
+			return fmt.Errorf("writing header: %w", err)
+		}
+
+		e.headerWritten = true
+	}
+
+	row := make([]string, len(e.columns))
+	for _, conn := range conns {
+		for i, column := range e.columns {
+			row[i] = e.format(conn, column)
+		}
+
+		if err := e.writer.Write(row); err != nil {
+			return fmt.Errorf("writing connection: %w", err)
+		}
+	}
+
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		return fmt.Errorf("flushing connections: %w", err)
+	}
+
+	return nil
+}
+
+// Format returns the value of the given column for the given Connection.
+// Columns which are not relevant to the state of the connection are left empty.
+func (e *TableEncoder) format(conn *Connection, column Column) string {
+	listening := conn.State == StateListen
+
+	switch column {
+	case ColumnState:
+		return string(conn.State)
+	case ColumnKernelState:
+		return conn.KernelState.String()
+	case ColumnFamily:
+		return conn.ProtocolVersion.String()
+	case ColumnLocalAddr:
+		return formatTextAddr(conn.LocalAddr, conn.ProtocolVersion)
+	case ColumnLocalPort:
+		return strconv.FormatUint(uint64(conn.LocalPort), 10)
+	case ColumnRemoteAddr:
+		if listening {
+			return ""
+		}
+
+		return formatTextAddr(conn.RemoteAddr, conn.ProtocolVersion)
+	case ColumnRemotePort:
+		if listening {
+			return ""
+		}
+
+		return strconv.FormatUint(uint64(conn.RemotePort), 10)
+	case ColumnRXQueue:
+		if listening {
+			return ""
+		}
+
+		return strconv.FormatUint(uint64(conn.ReceiveBufferSize), 10)
+	case ColumnTXQueue:
+		if listening {
+			return ""
+		}
+
+		return strconv.FormatUint(uint64(conn.SendBufferSize), 10)
+	case ColumnBacklog:
+		if !listening {
+			return ""
+		}
+
+		return strconv.FormatUint(uint64(conn.AcceptBacklog), 10)
+	case ColumnUID:
+		return strconv.FormatUint(uint64(conn.UID), 10)
+	case ColumnINode:
+		return strconv.FormatUint(uint64(conn.INode), 10)
+	case ColumnOwners:
+		owners := make([]string, 0, len(e.Owners[conn.INode]))
+		for _, owner := range e.Owners[conn.INode] {
+			owners = append(owners, owner.String())
+		}
+
+		return escapeFormula(strings.Join(owners, ownersSeparator))
+	default:
+		return ""
+	}
+}
+
+// EscapeFormula returns the given value prefixed with formulaEscape if it begins with
+// a character which would make a spreadsheet read it as a formula.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return formulaEscape + value
+	}
+
+	return value
+}
+
+// TableDecoder reads Connections from a CSV or TSV table written by a TableEncoder.
+// The columns of the table are given by its header row. Columns other than state, family,
+// laddr and lport may be omitted, in which case the corresponding fields are zero-valued.
+type TableDecoder struct {
+	reader *csv.Reader
+}
+
+// NewCSVDecoder constructs a new TableDecoder reading a comma-separated table from reader.
+func NewCSVDecoder(reader io.Reader) *TableDecoder {
+	return newTableDecoder(reader, ',')
+}
+
+// NewTSVDecoder constructs a new TableDecoder reading a tab-separated table from reader.
+func NewTSVDecoder(reader io.Reader) *TableDecoder {
+	return newTableDecoder(reader, '\t')
+}
+
+// NewTableDecoder constructs a new TableDecoder reading a table separated by comma.
+func newTableDecoder(reader io.Reader, comma rune) *TableDecoder {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = comma
+	csvReader.ReuseRecord = true
+
+	return &TableDecoder{
+		reader: csvReader,
+	}
+}
+
+// Decode reads all the Connections of the table.
+func (d *TableDecoder) Decode() ([]*Connection, error) {
+	header, err := d.reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	indices := make(map[Column]int, len(header))
+	for i, name := range header {
+		indices[Column(name)] = i
+	}
+
+	for _, column := range []Column{ColumnState, ColumnFamily, ColumnLocalAddr, ColumnLocalPort} {
+		if _, ok := indices[column]; !ok {
+			return nil, fmt.Errorf("invalid header: missing column %q", column)
+		}
+	}
+
+	conns := make([]*Connection, 0, 2048)
+
+	for {
+		row, err := d.reader.Read()
+		if errors.Is(err, io.EOF) {
+			return conns, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+
+		conn, err := parseRow(row, indices)
+		if err != nil {
+			line, _ := d.reader.FieldPos(0)
+			return nil, fmt.Errorf("parsing row on line %d: %w", line, err)
+		}
+
+		conns = append(conns, conn)
+	}
+}
+
+// ParseRow converts the given row into a Connection, finding each column at the index given
+// in indices.
+func parseRow(row []string, indices map[Column]int) (*Connection, error) {
+	value := func(column Column) string {
+		if i, ok := indices[column]; ok {
+			return row[i]
+		}
+
+		return ""
+	}
+
+	conn := &Connection{
+		State: State(value(ColumnState)),
+	}
+
+	if !conn.State.valid() {
+		return nil, fmt.Errorf("illegal state: %q", value(ColumnState))
+	}
+
+	conn.KernelState = conn.State.kernelState()
+	if str := value(ColumnKernelState); str != "" {
+		kernelState, err := ParseKernelStateName(str)
+		if err != nil {
+			return nil, fmt.Errorf("parsing kernel state: %w", err)
+		}
+
+		conn.KernelState = kernelState
+	}
+
+	protocolVersion, err := parseProtocolVersion(value(ColumnFamily))
+	if err != nil {
+		return nil, fmt.Errorf("parsing family: %w", err)
+	}
+
+	conn.ProtocolVersion = protocolVersion
+
+	if conn.LocalAddr, err = parseTextAddr(value(ColumnLocalAddr)); err != nil {
+		return nil, fmt.Errorf("parsing local address: %w", err)
+	}
+
+	if str := value(ColumnRemoteAddr); str != "" {
+		if conn.RemoteAddr, err = parseTextAddr(str); err != nil {
+			return nil, fmt.Errorf("parsing remote address: %w", err)
+		}
+	}
+
+	ports := []struct {
+		column Column
+		dst    *uint16
+	}{
+		{ColumnLocalPort, &conn.LocalPort},
+		{ColumnRemotePort, &conn.RemotePort},
+	}
+
+	for _, port := range ports {
+		if err := parseColumnUint(value(port.column), 16, func(v uint64) { *port.dst = uint16(v) }); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", port.column, err)
+		}
+	}
+
+	counts := []struct {
+		column Column
+		dst    *uint32
+	}{
+		{ColumnRXQueue, &conn.ReceiveBufferSize},
+		{ColumnTXQueue, &conn.SendBufferSize},
+		{ColumnBacklog, &conn.AcceptBacklog},
+		{ColumnUID, &conn.UID},
+		{ColumnINode, &conn.INode},
+	}
+
+	for _, count := range counts {
+		if err := parseColumnUint(value(count.column), 32, func(v uint64) { *count.dst = uint32(v) }); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", count.column, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// ParseColumnUint parses the given decimal string as an unsigned integer of the given
+// bit size, passing it to set. Empty strings are skipped.
+func parseColumnUint(str string, bitSize int, set func(uint64)) error {
+	if str == "" {
+		return nil
+	}
+
+	v, err := strconv.ParseUint(str, 10, bitSize)
+	if err != nil {
+		return fmt.Errorf("unable to parse %q as integer: %w", str, err)
+	}
+
+	set(v)
+	return nil
+}
+
+// Valid returns whether this Column is one defined by this package.
+func (c Column) valid() bool {
+	switch c {
+	case ColumnState, ColumnKernelState, ColumnFamily,
+		ColumnLocalAddr, ColumnLocalPort, ColumnRemoteAddr, ColumnRemotePort,
+		ColumnRXQueue, ColumnTXQueue, ColumnBacklog, ColumnUID, ColumnINode, ColumnOwners:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,170 @@
+package tcpconnparser
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestTableEncoderCSV(t *testing.T) {
+	conns := []*Connection{
+		NewListeningConnection(ProtocolVersionIPv4,
+			50,
+			net.IPv4(127, 0, 0, 1),
+			6789,
+			1000,
+			789829),
+		NewConnection(StateEstablished,
+			ProtocolVersionIPv4,
+			10,
+			20,
+			net.IPv4(192, 168, 1, 3),
+			54176,
+			net.IPv4(88, 221, 16, 125),
+			443,
+			1000,
+			380687),
+	}
+	expected := `state,laddr,lport,raddr,rport,backlog,owners
+LISTEN,127.0.0.1,6789,,,50,nginx (1234);worker (5678)
+ESTABLISHED,192.168.1.3,54176,88.221.16.125,443,,
+`
+
+	buf := new(bytes.Buffer)
+	encoder, err := NewCSVEncoder(buf,
+		ColumnState,
+		ColumnLocalAddr,
+		ColumnLocalPort,
+		ColumnRemoteAddr,
+		ColumnRemotePort,
+		ColumnBacklog,
+		ColumnOwners)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	encoder.Owners = map[uint32][]Process{
+		789829: {{PID: 1234, Command: "nginx"}, {PID: 5678, Command: "worker"}},
+	}
+
+	if err := encoder.Encode(conns...); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	t.Logf("got output %q", buf.String())
+}
+
+func TestTableEncoderCSVEscapesFormulaOwners(t *testing.T) {
+	conn := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(127, 0, 0, 1), 80, 0, 789829)
+
+	inputs := map[string]string{
+		"=cmd|' /C calc'!A0": "'=cmd|' /C calc'!A0 (1234)",
+		"+sum":               "'+sum (1234)",
+		"-x":                 "'-x (1234)",
+		"@sum":               "'@sum (1234)",
+		"nginx":              "nginx (1234)",
+	}
+
+	for command, expected := range inputs {
+		buf := new(bytes.Buffer)
+		encoder, err := NewCSVEncoder(buf, ColumnOwners)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		encoder.Owners = map[uint32][]Process{789829: {{PID: 1234, Command: command}}}
+		if err := encoder.Encode(conn); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if output := strings.TrimPrefix(buf.String(), "owners\n"); output != expected+"\n" {
+			t.Errorf("expected %q for command %q, got %q", expected+"\n", command, output)
+		}
+	}
+}
+
+func TestNewCSVEncoderIllegalColumnError(t *testing.T) {
+	_, err := NewCSVEncoder(new(bytes.Buffer), Column("bogus"))
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestTableDecoderTSVRoundTrip(t *testing.T) {
+	conns := []*Connection{
+		NewListeningConnection(ProtocolVersionIPv6,
+			3,
+			net.ParseIP("::1"),
+			631,
+			0,
+			31267),
+		NewConnection(StateSynReceived,
+			ProtocolVersionIPv4,
+			0,
+			0,
+			net.IPv4(192, 168, 1, 3),
+			443,
+			net.IPv4(88, 221, 16, 125),
+			54176,
+			0,
+			0),
+	}
+	conns[1].KernelState = KernelStateNewSynRecv
+
+	// The backlog of the listener is written by default
+	columns := append([]Column{ColumnKernelState}, DefaultColumns...)
+
+	buf := new(bytes.Buffer)
+	encoder, err := NewTSVEncoder(buf, columns...)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if err := encoder.Encode(conns...); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	output, err := NewTSVDecoder(buf).Decode()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(output) != len(conns) {
+		t.Fatalf("expected %d connections, got %d", len(conns), len(output))
+	}
+
+	for i := range conns {
+		if !output[i].Equal(conns[i]) {
+			t.Errorf("expected connection to be equal to %q, but was %q", conns[i], output[i])
+		}
+	}
+}
+
+func TestTableDecoderMissingColumnError(t *testing.T) {
+	input := "state,laddr,lport\nLISTEN,127.0.0.1,80\n"
+
+	_, err := NewCSVDecoder(strings.NewReader(input)).Decode()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestTableDecoderBadPortError(t *testing.T) {
+	input := "state,family,laddr,lport\nLISTEN,IPv4,127.0.0.1,99999\n"
+
+	_, err := NewCSVDecoder(strings.NewReader(input)).Decode()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
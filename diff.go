@@ -0,0 +1,77 @@
+package tcpconnparser
+
+// Diff is the difference between two successive sets of connections, such as those
+// returned by successive calls to GetConnections or the Connections of successive
+// Snapshots.
+//
+// Sockets are matched between the sets by their protocol version, addresses and ports.
+// Their inodes only distinguish sockets reusing the same addresses and ports, as the
+// inode of a socket is lost when it enters TIME-WAIT, and only gained when a SYN-RECV
+// request is accepted.
+type Diff struct {
+	Added   []*Connection // Connections whose sockets are only in the current set
+	Removed []*Connection // Connections whose sockets are only in the previous set
+	Changed []*Connection // Connections of the current set whose sockets changed State
+}
+
+// DiffConnections returns the Diff between the previous and current sets of connections.
+// The Connections of the Diff are in the order of the set they are taken from.
+func DiffConnections(prev, cur []*Connection) *Diff {
+	prevByKey := make(map[connKey][]*Connection, len(prev))
+	for _, conn := range prev {
+		key := newAddressKey(conn)
+		prevByKey[key] = append(prevByKey[key], conn)
+	}
+
+	matches := make(map[*Connection]*Connection, len(cur))
+	matched := make(map[*Connection]bool, len(prev))
+
+	match := func(conn *Connection, sameSocket func(prevConn *Connection) bool) {
+		for _, prevConn := range prevByKey[newAddressKey(conn)] {
+			if !matched[prevConn] && sameSocket(prevConn) {
+				matches[conn] = prevConn
+				matched[prevConn] = true
+				return
+			}
+		}
+	}
+
+	// Sockets with the same inode are matched first, so that those without an inode do
+	// not take the place of a socket which still has it
+	for _, conn := range cur {
+		if conn.INode != 0 {
+			match(conn, func(prevConn *Connection) bool { return prevConn.INode == conn.INode })
+		}
+	}
+
+	for _, conn := range cur {
+		if _, ok := matches[conn]; !ok {
+			match(conn, func(prevConn *Connection) bool { return prevConn.INode == 0 || conn.INode == 0 })
+		}
+	}
+
+	diff := new(Diff)
+
+	for _, conn := range cur {
+		prevConn, ok := matches[conn]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, conn)
+		case prevConn.State != conn.State:
+			diff.Changed = append(diff.Changed, conn)
+		}
+	}
+
+	for _, conn := range prev {
+		if !matched[conn] {
+			diff.Removed = append(diff.Removed, conn)
+		}
+	}
+
+	return diff
+}
+
+// Empty returns whether the sets of connections compared by this Diff were the same.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
@@ -0,0 +1,95 @@
+package tcpconnparser
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDiffConnections(t *testing.T) {
+	listener := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(0, 0, 0, 0), 80, 0, 100)
+	closing := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 80, net.IPv4(10, 0, 0, 2), 40000, 0, 101)
+	closed := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 80, net.IPv4(10, 0, 0, 3), 40001, 0, 102)
+	timeWait := NewConnection(StateTimeWait, ProtocolVersionIPv6, 0, 0,
+		net.ParseIP("2001:db8::1"), 443, net.ParseIP("2001:db8::2"), 40002, 0, 0)
+
+	closingNow := *closing
+	closingNow.State = StateCloseWait
+	opened := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 80, net.IPv4(10, 0, 0, 4), 40003, 0, 103)
+	timeWaitNow := *timeWait
+	openedTimeWait := NewConnection(StateTimeWait, ProtocolVersionIPv6, 0, 0,
+		net.ParseIP("2001:db8::1"), 443, net.ParseIP("2001:db8::2"), 40004, 0, 0)
+
+	prev := []*Connection{listener, closing, closed, timeWait}
+	cur := []*Connection{listener, &closingNow, opened, &timeWaitNow, openedTimeWait}
+
+	diff := DiffConnections(prev, cur)
+
+	if len(diff.Added) != 2 || diff.Added[0] != opened || diff.Added[1] != openedTimeWait {
+		t.Errorf("expected added connections %v, got %v", []*Connection{opened, openedTimeWait}, diff.Added)
+	}
+
+	if len(diff.Removed) != 1 || diff.Removed[0] != closed {
+		t.Errorf("expected removed connections %v, got %v", []*Connection{closed}, diff.Removed)
+	}
+
+	if len(diff.Changed) != 1 || diff.Changed[0] != &closingNow {
+		t.Errorf("expected changed connections %v, got %v", []*Connection{&closingNow}, diff.Changed)
+	}
+
+	if diff.Empty() {
+		t.Error("expected non-empty diff")
+	}
+}
+
+func TestDiffConnectionsEnteringTimeWait(t *testing.T) {
+	established := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 40000, net.IPv4(10, 0, 0, 2), 443, 0, 101)
+	timeWait := NewConnection(StateTimeWait, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 40000, net.IPv4(10, 0, 0, 2), 443, 0, 0)
+
+	diff := DiffConnections([]*Connection{established}, []*Connection{timeWait})
+
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 {
+		t.Errorf("expected no added or removed connections, got %v and %v", diff.Added, diff.Removed)
+	}
+
+	if len(diff.Changed) != 1 || diff.Changed[0] != timeWait {
+		t.Errorf("expected changed connections %v, got %v", []*Connection{timeWait}, diff.Changed)
+	}
+}
+
+func TestDiffConnectionsReusedAddresses(t *testing.T) {
+	closed := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 40000, net.IPv4(10, 0, 0, 2), 443, 0, 101)
+	reopened := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 40000, net.IPv4(10, 0, 0, 2), 443, 0, 102)
+	reusePort1 := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(0, 0, 0, 0), 8080, 0, 3)
+	reusePort2 := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(0, 0, 0, 0), 8080, 0, 4)
+
+	diff := DiffConnections([]*Connection{closed, reusePort1, reusePort2}, []*Connection{reopened, reusePort2})
+
+	if len(diff.Added) != 1 || diff.Added[0] != reopened {
+		t.Errorf("expected added connections %v, got %v", []*Connection{reopened}, diff.Added)
+	}
+
+	if len(diff.Removed) != 2 || diff.Removed[0] != closed || diff.Removed[1] != reusePort1 {
+		t.Errorf("expected removed connections %v, got %v", []*Connection{closed, reusePort1}, diff.Removed)
+	}
+
+	if len(diff.Changed) != 0 {
+		t.Errorf("expected no changed connections, got %v", diff.Changed)
+	}
+}
+
+func TestDiffConnectionsUnchanged(t *testing.T) {
+	conns := []*Connection{
+		NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(0, 0, 0, 0), 80, 0, 100),
+	}
+
+	if diff := DiffConnections(conns, conns); !diff.Empty() {
+		t.Errorf("expected empty diff, got %+v", diff)
+	}
+}
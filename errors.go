@@ -0,0 +1,104 @@
+package tcpconnparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Names of the fields within a /proc/net/tcp* pseudo-file, as given in its header.
+const (
+	fieldSlot          = "sl"
+	fieldLocalAddress  = "local_address"
+	fieldRemoteAddress = "rem_address"
+	fieldState         = "st"
+	fieldTXQueue       = "tx_queue"
+	fieldRXQueue       = "rx_queue"
+	fieldQueues        = fieldTXQueue + ":" + fieldRXQueue
+	fieldUID           = "uid"
+	fieldINode         = "inode"
+)
+
+// ParseError describes a failure to parse a line of a /proc/net/tcp* pseudo-file.
+type ParseError struct {
+	Path  string // Path of the file being parsed, empty if read from an unnamed Reader
+	Line  int    // 1-based line number within the file
+	Field string // Name of the offending field, as given in the file header, if known
+	Token string // Text of the offending field, if known
+	Raw   string // The full text of the offending line
+	Err   error  // The underlying error
+}
+
+// Error returns a human-readable description of this ParseError.
+func (e *ParseError) Error() string {
+	location := fmt.Sprintf("line %d", e.Line)
+	if e.Path != "" {
+		location = fmt.Sprintf("%s:%d", e.Path, e.Line)
+	}
+
+	if e.Field == "" {
+		return fmt.Sprintf("parsing %s: %v", location, e.Err)
+	}
+
+	return fmt.Sprintf("parsing %s: field %s (%q): %v", location, e.Field, e.Token, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// NewFieldError constructs a ParseError describing a failure to parse the given field,
+// whose location is to be filled in by the caller.
+func newFieldError(field, token string, err error) *ParseError {
+	return &ParseError{
+		Field: field,
+		Token: token,
+		Err:   err,
+	}
+}
+
+// ParseErrors is a list of ParseErrors, describing the lines skipped by lenient parsing.
+type ParseErrors []*ParseError
+
+// Error returns a human-readable description of these ParseErrors.
+func (e ParseErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("%d unparseable lines: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the individual ParseErrors, so that they can be inspected with
+// errors.Is and errors.As.
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// SetPath sets the path of each of these ParseErrors.
+func (e ParseErrors) setPath(path string) {
+	for _, err := range e {
+		err.Path = path
+	}
+}
+
+// SetErrorPath sets the path of any ParseError or ParseErrors wrapped by the given error.
+func setErrorPath(err error, path string) {
+	var parseErrs ParseErrors
+	if errors.As(err, &parseErrs) {
+		parseErrs.setPath(path)
+		return
+	}
+
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Path = path
+	}
+}
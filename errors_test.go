@@ -0,0 +1,59 @@
+package tcpconnparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorFromReader(t *testing.T) {
+	badLine := `1: 0301A8C0:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  BADUID        0 380687 2 0000000000000000 22 4 2 10 -1`
+	mockFile := `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0
+` + badLine
+
+	_, err := GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected error to wrap a *ParseError, got %q (of type %T)", err, err)
+	}
+
+	if parseErr.Line != 3 {
+		t.Errorf("expected line 3, got %d", parseErr.Line)
+	}
+
+	if parseErr.Field != fieldUID {
+		t.Errorf("expected field %q, got %q", fieldUID, parseErr.Field)
+	}
+
+	if parseErr.Token != "BADUID" {
+		t.Errorf("expected token %q, got %q", "BADUID", parseErr.Token)
+	}
+
+	if parseErr.Raw != badLine {
+		t.Errorf("expected raw line %q, got %q", badLine, parseErr.Raw)
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestParseErrorString(t *testing.T) {
+	parseErr := &ParseError{
+		Path:  "/proc/net/tcp",
+		Line:  7,
+		Field: fieldState,
+		Token: "ZZ",
+		Err:   errors.New("bad state"),
+	}
+	expected := `parsing /proc/net/tcp:7: field st ("ZZ"): bad state`
+
+	if parseErr.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, parseErr.Error())
+	}
+
+	t.Logf("got error %q", parseErr)
+}
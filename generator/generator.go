@@ -0,0 +1,424 @@
+// package generator implements a generator of synthetic TCP connection tables,
+// for use as fixtures when benchmarking and testing consumers of the Linux kernel
+// procfs /proc/net/tcp and /proc/net/tcp6 files.
+//
+// Tables are generated deterministically from a seed, so that the same Config
+// always produces the same sequence of snapshots.
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Range of ephemeral ports used for the local port of outbound connections and
+// the remote port of inbound connections, per the Linux default.
+const (
+	ephemeralPortMin = 32768
+	ephemeralPortMax = 60999
+)
+
+// Largest queue size generated for connections with non-empty queues.
+const maxQueueSize = 65536
+
+// Fraction of connections and listeners generated with non-empty queues.
+const nonEmptyQueueFraction = 0.05
+
+// DefaultStateWeights are the relative weights of the states of non-listening connections
+// used if none are configured, roughly resembling a busy server.
+var DefaultStateWeights = map[tcpconnparser.KernelState]float64{
+	tcpconnparser.KernelStateEstablished: 60,
+	tcpconnparser.KernelStateTimeWait:    25,
+	tcpconnparser.KernelStateCloseWait:   3,
+	tcpconnparser.KernelStateSynSent:     2,
+	tcpconnparser.KernelStateNewSynRecv:  3,
+	tcpconnparser.KernelStateFinWait1:    2,
+	tcpconnparser.KernelStateFinWait2:    3,
+	tcpconnparser.KernelStateLastAck:     1,
+	tcpconnparser.KernelStateClosing:     1,
+}
+
+// Default address pools used if none are configured.
+var (
+	defaultLocalIPv4Addrs  = []net.IP{net.IPv4(10, 0, 0, 1), net.IPv4(127, 0, 0, 1)}
+	defaultLocalIPv6Addrs  = []net.IP{net.ParseIP("2001:db8::1"), net.IPv6loopback}
+	defaultRemoteIPv4Nets  = []*net.IPNet{mustParseCIDR("198.51.100.0/24"), mustParseCIDR("203.0.113.0/24")}
+	defaultRemoteIPv6Nets  = []*net.IPNet{mustParseCIDR("2001:db8:1::/64")}
+	defaultListenPorts     = []uint16{22, 80, 443, 8080}
+	defaultRemoteDestPorts = []uint16{53, 80, 443, 5432, 6379}
+)
+
+// Config configures a Generator. Its counts and fractions are used as given, a fraction
+// of zero meaning none, so that the zero Config generates only IPv4 listeners, with no
+// other connections and no churn; DefaultConfig returns a Config generating a busy server.
+// Its slices and maps, and MaxAcceptBacklog, take the documented default when empty or
+// zero.
+type Config struct {
+	Seed int64 // Seed from which the tables are generated
+
+	Sockets      int     // Number of non-listening connections in each snapshot
+	IPv6Fraction float64 // Fraction of connections which are IPv6, none if zero
+
+	// Relative weights of the states of non-listening connections.
+	// Defaults to DefaultStateWeights.
+	StateWeights map[tcpconnparser.KernelState]float64
+
+	// Local ports on which listeners are bound, for each local address.
+	// Defaults to 22, 80, 443 and 8080.
+	ListenPorts      []uint16
+	MaxAcceptBacklog uint32 // Largest accept backlog of a listener, defaults to 128
+
+	// Fraction of non-listening connections which are inbound to a listener, rather than
+	// outbound to one of RemoteDestPorts, none if zero. Connections in SYN-RECEIVED are
+	// always inbound, and those in SYN-SENT always outbound.
+	InboundFraction float64
+	RemoteDestPorts []uint16 // Remote ports of outbound connections, defaults to common services
+
+	// Local addresses, and prefixes from which remote addresses are drawn. Default to
+	// documentation and loopback addresses.
+	LocalIPv4Addrs []net.IP
+	LocalIPv6Addrs []net.IP
+	RemoteIPv4Nets []*net.IPNet
+	RemoteIPv6Nets []*net.IPNet
+
+	// Fraction of non-listening connections which are closed and replaced by
+	// new connections between successive snapshots, none if zero.
+	Churn float64
+}
+
+// DefaultConfig returns the Config of a busy server, with 10000 connections, a fifth of
+// which are IPv6, half inbound, and of which 5% are replaced between snapshots.
+func DefaultConfig() Config {
+	return Config{
+		Seed:            1,
+		Sockets:         10000,
+		IPv6Fraction:    0.2,
+		InboundFraction: 0.5,
+		Churn:           0.05,
+	}
+}
+
+// Generator generates a sequence of synthetic connection tables.
+type Generator struct {
+	cfg       Config
+	rng       *rand.Rand
+	states    []tcpconnparser.KernelState
+	cumWeight []float64
+
+	listeners []*tcpconnparser.Connection
+	conns     []*tcpconnparser.Connection
+	nextINode uint32
+}
+
+// New constructs a new Generator from the given Config, and generates its first snapshot.
+func New(cfg Config) (*Generator, error) {
+	if cfg.Sockets < 0 {
+		return nil, fmt.Errorf("illegal socket count: %d", cfg.Sockets)
+	}
+
+	if cfg.IPv6Fraction < 0 || cfg.IPv6Fraction > 1 {
+		return nil, fmt.Errorf("illegal IPv6 fraction: %v", cfg.IPv6Fraction)
+	}
+
+	if cfg.InboundFraction < 0 || cfg.InboundFraction > 1 {
+		return nil, fmt.Errorf("illegal inbound fraction: %v", cfg.InboundFraction)
+	}
+
+	if cfg.Churn < 0 || cfg.Churn > 1 {
+		return nil, fmt.Errorf("illegal churn: %v", cfg.Churn)
+	}
+
+	setDefaults(&cfg)
+
+	g := &Generator{
+		cfg:       cfg,
+		rng:       rand.New(rand.NewSource(cfg.Seed)),
+		nextINode: 10000,
+	}
+
+	if err := g.initStates(); err != nil {
+		return nil, fmt.Errorf("initialising states: %w", err)
+	}
+
+	g.initListeners()
+
+	g.conns = make([]*tcpconnparser.Connection, 0, cfg.Sockets)
+	for i := 0; i < cfg.Sockets; i++ {
+		g.conns = append(g.conns, g.newConnection())
+	}
+
+	return g, nil
+}
+
+// SetDefaults replaces the zero-valued fields of cfg, and its empty slices and maps,
+// with their defaults.
+func setDefaults(cfg *Config) {
+	if len(cfg.StateWeights) == 0 {
+		cfg.StateWeights = DefaultStateWeights
+	}
+
+	if len(cfg.ListenPorts) == 0 {
+		cfg.ListenPorts = defaultListenPorts
+	}
+
+	if cfg.MaxAcceptBacklog == 0 {
+		cfg.MaxAcceptBacklog = 128
+	}
+
+	if len(cfg.RemoteDestPorts) == 0 {
+		cfg.RemoteDestPorts = defaultRemoteDestPorts
+	}
+
+	if len(cfg.LocalIPv4Addrs) == 0 {
+		cfg.LocalIPv4Addrs = defaultLocalIPv4Addrs
+	}
+
+	if len(cfg.LocalIPv6Addrs) == 0 {
+		cfg.LocalIPv6Addrs = defaultLocalIPv6Addrs
+	}
+
+	if len(cfg.RemoteIPv4Nets) == 0 {
+		cfg.RemoteIPv4Nets = defaultRemoteIPv4Nets
+	}
+
+	if len(cfg.RemoteIPv6Nets) == 0 {
+		cfg.RemoteIPv6Nets = defaultRemoteIPv6Nets
+	}
+}
+
+// InitStates builds the cumulative weights of the configured states, in a
+// deterministic order.
+func (g *Generator) initStates() error {
+	for state, weight := range g.cfg.StateWeights {
+		if state == tcpconnparser.KernelStateListen {
+			return errors.New("listeners are configured by ListenPorts, not StateWeights")
+		}
+
+		if _, err := state.State(); err != nil {
+			return fmt.Errorf("checking state weight: %w", err)
+		}
+
+		if weight < 0 {
+			return fmt.Errorf("illegal weight for state %s: %v", state, weight)
+		}
+
+		if weight > 0 {
+			g.states = append(g.states, state)
+		}
+	}
+
+	if len(g.states) == 0 && g.cfg.Sockets > 0 {
+		return errors.New("no states with positive weight")
+	}
+
+	sort.Slice(g.states, func(i, j int) bool { return g.states[i] < g.states[j] })
+
+	total := 0.0
+	for _, state := range g.states {
+		total += g.cfg.StateWeights[state]
+		g.cumWeight = append(g.cumWeight, total)
+	}
+
+	return nil
+}
+
+// InitListeners generates a listener on each listen port of each local address.
+func (g *Generator) initListeners() {
+	for _, protocolVersion := range g.protocolVersions() {
+		for _, addr := range g.localAddrs(protocolVersion) {
+			for _, port := range g.cfg.ListenPorts {
+				backlog := uint32(0)
+				if g.rng.Float64() < nonEmptyQueueFraction {
+					backlog = uint32(g.rng.Int63n(int64(g.cfg.MaxAcceptBacklog) + 1))
+				}
+
+				g.listeners = append(g.listeners, tcpconnparser.NewListeningConnection(protocolVersion,
+					backlog,
+					addr,
+					port,
+					0,
+					g.newINode()))
+			}
+		}
+	}
+}
+
+// Connections returns the current snapshot, listeners first, as the kernel lists them.
+func (g *Generator) Connections() []*tcpconnparser.Connection {
+	conns := make([]*tcpconnparser.Connection, 0, len(g.listeners)+len(g.conns))
+	conns = append(conns, g.listeners...)
+	conns = append(conns, g.conns...)
+
+	return conns
+}
+
+// Next applies the configured churn to the current snapshot, and returns the new snapshot.
+func (g *Generator) Next() []*tcpconnparser.Connection {
+	closed := int(g.cfg.Churn * float64(len(g.conns)))
+
+	for i := 0; i < closed; i++ {
+		g.conns[g.rng.Intn(len(g.conns))] = g.newConnection()
+	}
+
+	return g.Connections()
+}
+
+// Write writes the connections of the current snapshot with the given protocolVersion to
+// writer, in the format of the corresponding /proc/net/tcp* pseudo-file.
+func (g *Generator) Write(writer io.Writer, protocolVersion tcpconnparser.ProtocolVersion) error {
+	conns := make([]*tcpconnparser.Connection, 0, len(g.listeners)+len(g.conns))
+	for _, conn := range g.Connections() {
+		if conn.ProtocolVersion == protocolVersion {
+			conns = append(conns, conn)
+		}
+	}
+
+	if err := tcpconnparser.WriteConnections(writer, protocolVersion, conns); err != nil {
+		return fmt.Errorf("writing connections: %w", err)
+	}
+
+	return nil
+}
+
+// NewConnection generates a new non-listening connection.
+func (g *Generator) newConnection() *tcpconnparser.Connection {
+	protocolVersion := tcpconnparser.ProtocolVersionIPv4
+	if g.rng.Float64() < g.cfg.IPv6Fraction {
+		protocolVersion = tcpconnparser.ProtocolVersionIPv6
+	}
+
+	kernelState := g.pickState()
+	state, _ := kernelState.State()
+
+	localAddrs := g.localAddrs(protocolVersion)
+	localAddr := localAddrs[g.rng.Intn(len(localAddrs))]
+	remoteAddr := g.remoteAddr(protocolVersion)
+
+	var localPort, remotePort uint16
+	inbound := kernelState == tcpconnparser.KernelStateSynRecv ||
+		kernelState == tcpconnparser.KernelStateNewSynRecv ||
+		(kernelState != tcpconnparser.KernelStateSynSent && g.rng.Float64() < g.cfg.InboundFraction)
+
+	if inbound && len(g.cfg.ListenPorts) > 0 {
+		localPort = g.cfg.ListenPorts[g.rng.Intn(len(g.cfg.ListenPorts))]
+		remotePort = g.ephemeralPort()
+	} else {
+		localPort = g.ephemeralPort()
+		remotePort = g.cfg.RemoteDestPorts[g.rng.Intn(len(g.cfg.RemoteDestPorts))]
+	}
+
+	// Sockets which are not full sockets have no inode, UID or queues
+	var rxQueue, txQueue, uid, iNode uint32
+	switch kernelState {
+	case tcpconnparser.KernelStateTimeWait, tcpconnparser.KernelStateNewSynRecv:
+	default:
+		uid = 1000
+		iNode = g.newINode()
+
+		if g.rng.Float64() < nonEmptyQueueFraction {
+			rxQueue = uint32(g.rng.Intn(maxQueueSize))
+		}
+
+		if g.rng.Float64() < nonEmptyQueueFraction {
+			txQueue = uint32(g.rng.Intn(maxQueueSize))
+		}
+	}
+
+	conn := tcpconnparser.NewConnection(state,
+		protocolVersion,
+		rxQueue,
+		txQueue,
+		localAddr,
+		localPort,
+		remoteAddr,
+		remotePort,
+		uid,
+		iNode)
+	conn.KernelState = kernelState
+
+	return conn
+}
+
+// PickState returns a random state according to the configured weights.
+func (g *Generator) pickState() tcpconnparser.KernelState {
+	r := g.rng.Float64() * g.cumWeight[len(g.cumWeight)-1]
+
+	i := sort.SearchFloat64s(g.cumWeight, r)
+	if i == len(g.states) {
+		i--
+	}
+
+	return g.states[i]
+}
+
+// ProtocolVersions returns the protocol versions of the connections generated.
+func (g *Generator) protocolVersions() []tcpconnparser.ProtocolVersion {
+	switch g.cfg.IPv6Fraction {
+	case 0:
+		return []tcpconnparser.ProtocolVersion{tcpconnparser.ProtocolVersionIPv4}
+	case 1:
+		return []tcpconnparser.ProtocolVersion{tcpconnparser.ProtocolVersionIPv6}
+	default:
+		return []tcpconnparser.ProtocolVersion{tcpconnparser.ProtocolVersionIPv4, tcpconnparser.ProtocolVersionIPv6}
+	}
+}
+
+// LocalAddrs returns the local addresses configured for the given protocolVersion.
+func (g *Generator) localAddrs(protocolVersion tcpconnparser.ProtocolVersion) []net.IP {
+	if protocolVersion == tcpconnparser.ProtocolVersionIPv6 {
+		return g.cfg.LocalIPv6Addrs
+	}
+
+	return g.cfg.LocalIPv4Addrs
+}
+
+// RemoteAddr returns a random address within the remote prefixes configured for
+// the given protocolVersion.
+func (g *Generator) remoteAddr(protocolVersion tcpconnparser.ProtocolVersion) net.IP {
+	nets := g.cfg.RemoteIPv4Nets
+	if protocolVersion == tcpconnparser.ProtocolVersionIPv6 {
+		nets = g.cfg.RemoteIPv6Nets
+	}
+
+	ipNet := nets[g.rng.Intn(len(nets))]
+	prefix := ipNet.IP
+	if len(ipNet.Mask) == net.IPv4len {
+		prefix = prefix.To4()
+	}
+
+	addr := make(net.IP, len(ipNet.Mask))
+	g.rng.Read(addr)
+
+	for i := range addr {
+		addr[i] = prefix[i] | (addr[i] &^ ipNet.Mask[i])
+	}
+
+	return addr
+}
+
+// EphemeralPort returns a random port within the ephemeral port range.
+func (g *Generator) ephemeralPort() uint16 {
+	return uint16(ephemeralPortMin + g.rng.Intn(ephemeralPortMax-ephemeralPortMin+1))
+}
+
+// NewINode returns a new, unique inode.
+func (g *Generator) newINode() uint32 {
+	g.nextINode++
+	return g.nextINode
+}
+
+// MustParseCIDR parses the given CIDR prefix, panicking on failure.
+func mustParseCIDR(str string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(str)
+	if err != nil {
+		panic(err)
+	}
+
+	return ipNet
+}
@@ -0,0 +1,245 @@
+package generator
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+func TestNewDeterministic(t *testing.T) {
+	cfg := Config{Seed: 42, Sockets: 500, IPv6Fraction: 0.3, Churn: 0.1}
+
+	first, err := New(cfg)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	second, err := New(cfg)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	first.Next()
+	second.Next()
+
+	for _, protocolVersion := range []tcpconnparser.ProtocolVersion{
+		tcpconnparser.ProtocolVersionIPv4,
+		tcpconnparser.ProtocolVersionIPv6,
+	} {
+		firstBuf, secondBuf := new(bytes.Buffer), new(bytes.Buffer)
+
+		if err := first.Write(firstBuf, protocolVersion); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if err := second.Write(secondBuf, protocolVersion); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if !bytes.Equal(firstBuf.Bytes(), secondBuf.Bytes()) {
+			t.Errorf("expected generators with equal seeds to write equal %s tables", protocolVersion)
+		}
+	}
+}
+
+func TestNewSocketCountAndStates(t *testing.T) {
+	cfg := Config{
+		Seed:         1,
+		Sockets:      1000,
+		StateWeights: map[tcpconnparser.KernelState]float64{tcpconnparser.KernelStateTimeWait: 1},
+		ListenPorts:  []uint16{80},
+	}
+
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	conns := g.Connections()
+	listeners, timeWaits := 0, 0
+	for _, conn := range conns {
+		switch conn.State {
+		case tcpconnparser.StateListen:
+			listeners++
+		case tcpconnparser.StateTimeWait:
+			timeWaits++
+		default:
+			t.Errorf("unexpected state %s", conn.State)
+		}
+	}
+
+	if listeners != len(defaultLocalIPv4Addrs) {
+		t.Errorf("expected %d listeners, got %d", len(defaultLocalIPv4Addrs), listeners)
+	}
+
+	if timeWaits != cfg.Sockets {
+		t.Errorf("expected %d TIME-WAIT connections, got %d", cfg.Sockets, timeWaits)
+	}
+}
+
+func TestNextChurn(t *testing.T) {
+	g, err := New(Config{Seed: 7, Sockets: 1000, Churn: 0.2})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	before := make(map[*tcpconnparser.Connection]bool)
+	for _, conn := range g.Connections() {
+		before[conn] = true
+	}
+
+	after := g.Next()
+	replaced := 0
+	for _, conn := range after {
+		if !before[conn] {
+			replaced++
+		}
+	}
+
+	// Connections may be replaced more than once, so allow for fewer than the churn
+	if replaced == 0 || replaced > 200 {
+		t.Errorf("expected up to 200 replaced connections, got %d", replaced)
+	}
+
+	if len(after) != len(before) {
+		t.Errorf("expected snapshot size to remain %d, got %d", len(before), len(after))
+	}
+}
+
+func TestWriteParses(t *testing.T) {
+	g, err := New(Config{Seed: 3, Sockets: 2000, IPv6Fraction: 0.5})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	total := 0
+	for _, protocolVersion := range []tcpconnparser.ProtocolVersion{
+		tcpconnparser.ProtocolVersionIPv4,
+		tcpconnparser.ProtocolVersionIPv6,
+	} {
+		buf := new(bytes.Buffer)
+		if err := g.Write(buf, protocolVersion); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		conns, err := tcpconnparser.GetConnectionsFromReader(buf, protocolVersion)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		total += len(conns)
+	}
+
+	if total != len(g.Connections()) {
+		t.Errorf("expected %d parsed connections, got %d", len(g.Connections()), total)
+	}
+}
+
+func TestNewEmptySlicesDefault(t *testing.T) {
+	g, err := New(Config{
+		Seed:            1,
+		Sockets:         100,
+		IPv6Fraction:    0.5,
+		StateWeights:    map[tcpconnparser.KernelState]float64{},
+		ListenPorts:     []uint16{},
+		RemoteDestPorts: []uint16{},
+		LocalIPv4Addrs:  []net.IP{},
+		LocalIPv6Addrs:  []net.IP{},
+		RemoteIPv4Nets:  []*net.IPNet{},
+		RemoteIPv6Nets:  []*net.IPNet{},
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	expected := (len(defaultLocalIPv4Addrs)+len(defaultLocalIPv6Addrs))*len(defaultListenPorts) + 100
+	if output := len(g.Connections()); output != expected {
+		t.Errorf("expected %d connections, got %d", expected, output)
+	}
+}
+
+func TestNewInboundFraction(t *testing.T) {
+	for _, test := range []struct {
+		inboundFraction float64
+		expected        bool
+	}{
+		{0, false},
+		{DefaultConfig().InboundFraction, true},
+	} {
+		cfg := DefaultConfig()
+		cfg.Sockets = 100
+		cfg.InboundFraction = test.inboundFraction
+		cfg.StateWeights = map[tcpconnparser.KernelState]float64{tcpconnparser.KernelStateEstablished: 1}
+		cfg.ListenPorts = []uint16{80}
+
+		g, err := New(cfg)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		inbound := false
+		for _, conn := range g.Connections() {
+			if conn.State != tcpconnparser.StateListen && conn.LocalPort == 80 {
+				inbound = true
+			}
+		}
+
+		if inbound != test.expected {
+			t.Errorf("expected inbound connections to be %t for inbound fraction %v, got %t",
+				test.expected, test.inboundFraction, inbound)
+		}
+	}
+}
+
+func TestNewIllegalFractionError(t *testing.T) {
+	for _, cfg := range []Config{
+		{IPv6Fraction: -0.1},
+		{InboundFraction: -1},
+		{InboundFraction: 1.5},
+		{Churn: 2},
+	} {
+		_, err := New(cfg)
+		if err == nil {
+			t.Errorf("expected error, got nil for config %+v", cfg)
+			continue
+		}
+
+		t.Logf("got error %q (of type %T)", err, err)
+	}
+}
+
+func TestNewListenStateWeightError(t *testing.T) {
+	_, err := New(Config{
+		Sockets:      1,
+		StateWeights: map[tcpconnparser.KernelState]float64{tcpconnparser.KernelStateListen: 1},
+	})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func BenchmarkGetConnectionsFromReader(b *testing.B) {
+	g, err := New(Config{Seed: 1, Sockets: 100000})
+	if err != nil {
+		b.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := g.Write(buf, tcpconnparser.ProtocolVersionIPv4); err != nil {
+		b.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	b.SetBytes(int64(buf.Len()))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := tcpconnparser.GetConnectionsFromReader(bytes.NewReader(buf.Bytes()),
+			tcpconnparser.ProtocolVersionIPv4); err != nil {
+			b.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+	}
+}
@@ -1,3 +1,7 @@
 module github.com/jhwbarlow/tcpconnparser
 
-go 1.17
+go 1.20
+
+require golang.org/x/term v0.15.0
+
+require golang.org/x/sys v0.15.0 // indirect
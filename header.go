@@ -0,0 +1,96 @@
+package tcpconnparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of header fields which are given separately in the header of a
+// /proc/net/tcp* pseudo-file, but which are joined by a colon into a single
+// field in each of its lines, e.g. "tx_queue rx_queue" and "00000000:00000032".
+var joinedHeaderFields = map[string]string{
+	fieldTXQueue: fieldRXQueue,
+	"tr":         "tm->when",
+}
+
+// Alternative names for header fields, keyed by the alternative name.
+// The tcp6 pseudo-file spells out the remote address field in full.
+var headerFieldAliases = map[string]string{
+	"remote_address": fieldRemoteAddress,
+}
+
+// Names of the header fields which must be present in order to parse a line.
+var requiredHeaderFields = []string{
+	fieldSlot,
+	fieldLocalAddress,
+	fieldRemoteAddress,
+	fieldState,
+	fieldQueues,
+	fieldUID,
+	fieldINode,
+}
+
+// Columns holds the indices of interesting fields within the space-separated
+// fields of a line of a /proc/net/tcp* pseudo-file, as given by its header.
+type columns struct {
+	slot          int
+	localAddress  int
+	remoteAddress int
+	state         int
+	queues        int
+	uid           int
+	iNode         int
+
+	minNoOfFields int
+}
+
+// ParseHeader builds the columns of a /proc/net/tcp* pseudo-file from its header line.
+// An error is returned if any field required to parse a line is missing from the header.
+func parseHeader(str string) (*columns, error) {
+	headerFields := strings.Fields(str)
+	indices := make(map[string]int, len(headerFields))
+
+	for i, column := 0, 0; i < len(headerFields); i, column = i+1, column+1 {
+		name := headerFields[i]
+		if alias, ok := headerFieldAliases[name]; ok {
+			name = alias
+		}
+
+		if joined, ok := joinedHeaderFields[name]; ok &&
+			i+1 < len(headerFields) &&
+			headerFields[i+1] == joined {
+			name = name + ":" + joined
+			i++
+		}
+
+		if _, ok := indices[name]; ok {
+			return nil, fmt.Errorf("invalid header: duplicate field %q", name)
+		}
+
+		indices[name] = column
+	}
+
+	for _, name := range requiredHeaderFields {
+		if _, ok := indices[name]; !ok {
+			return nil, fmt.Errorf("invalid header: missing field %q", name)
+		}
+	}
+
+	cols := &columns{
+		slot:          indices[fieldSlot],
+		localAddress:  indices[fieldLocalAddress],
+		remoteAddress: indices[fieldRemoteAddress],
+		state:         indices[fieldState],
+		queues:        indices[fieldQueues],
+		uid:           indices[fieldUID],
+		iNode:         indices[fieldINode],
+	}
+
+	for _, name := range requiredHeaderFields {
+		if indices[name]+1 > cols.minNoOfFields {
+			cols.minNoOfFields = indices[name] + 1
+		}
+	}
+
+	return cols, nil
+}
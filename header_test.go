@@ -0,0 +1,91 @@
+package tcpconnparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHeaderIPv4AndIPv6Equal(t *testing.T) {
+	ipv4Header := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode"
+	ipv6Header := "  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode"
+	expected := columns{
+		slot:          0,
+		localAddress:  1,
+		remoteAddress: 2,
+		state:         3,
+		queues:        4,
+		uid:           7,
+		iNode:         9,
+		minNoOfFields: 10,
+	}
+
+	for _, input := range []string{ipv4Header, ipv6Header} {
+		output, err := parseHeader(input)
+		if err != nil {
+			t.Errorf("expected nil error, got %v (of type %T)", err, err)
+			continue
+		}
+
+		if *output != expected {
+			t.Errorf("expected %+v, got %+v for input %q", expected, *output, input)
+		}
+
+		t.Logf("got output %+v for input %q", *output, input)
+	}
+}
+
+func TestParseHeaderMissingFieldError(t *testing.T) {
+	input := "sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   timeout inode"
+
+	_, err := parseHeader(input)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestParseHeaderDuplicateFieldError(t *testing.T) {
+	input := "sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  uid  timeout inode"
+
+	_, err := parseHeader(input)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestGetConnectionsReorderedColumns(t *testing.T) {
+	mockFile := `sl  rem_address local_address extra st tx_queue rx_queue inode uid
+0: 7D10DD58:01BB 0301A8C0:D3A0 XYZ 01 00000000:00000000 380687 1000`
+
+	conns, err := GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(conns) != 1 {
+		t.Fatalf("expected conns slice to include 1 connection, but contained %d", len(conns))
+	}
+
+	conn := conns[0]
+
+	if conn.LocalPort != 54176 || conn.RemotePort != 443 || conn.UID != 1000 || conn.INode != 380687 {
+		t.Errorf("expected connection fields to be taken from reordered columns, got %q", conn)
+	}
+
+	t.Logf("got conn %q", conn)
+}
+
+func TestGetConnectionsBadHeaderError(t *testing.T) {
+	mockFile := `sl  local_address st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0301A8C0:D3A0 01 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1`
+
+	_, err := GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
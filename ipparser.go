@@ -7,3 +7,9 @@ import "net"
 type ipParser interface {
 	parseAddress(str string) (addr net.IP, err error)
 }
+
+// IPFormatter is an interface which describes objects which format net.IP objects
+// into the string representation parsed by the corresponding ipParser.
+type ipFormatter interface {
+	formatAddress(addr net.IP) (str string, err error)
+}
@@ -31,3 +31,18 @@ func (*ipv4Parser) parseAddress(str string) (addr net.IP, err error) {
 
 	return net.IP(addrBytes), nil
 }
+
+// FormatAddress formats net.IP objects into IP addresses in the format provided by the
+// /proc/net/tcp pseudo-file. A nil addr is formatted as the unspecified address.
+func (*ipv4Parser) formatAddress(addr net.IP) (str string, err error) {
+	if addr == nil {
+		addr = net.IPv4zero
+	}
+
+	addrBytes := addr.To4()
+	if addrBytes == nil {
+		return "", fmt.Errorf("not an IPv4 address: %s", addr)
+	}
+
+	return hexWordFromReversedBytes(addrBytes), nil
+}
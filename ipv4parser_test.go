@@ -2,6 +2,7 @@ package tcpconnparser
 
 import (
 	"net"
+	"strings"
 	"testing"
 )
 
@@ -20,3 +21,25 @@ func TestParseIPv4(t *testing.T) {
 
 	t.Logf("got output %q for input %q", output, input)
 }
+
+func FuzzParseIPv4(f *testing.F) {
+	for _, seed := range []string{"0100007F", "0301A8C0", "00000000", "BADADDRESS"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		output, err := new(ipv4Parser).parseAddress(input)
+		if err != nil {
+			return
+		}
+
+		formatted, err := new(ipv4Parser).formatAddress(output)
+		if err != nil {
+			t.Fatalf("expected nil error formatting %q, got %v (of type %T)", output, err, err)
+		}
+
+		if formatted != strings.ToUpper(input) {
+			t.Errorf("expected formatting %q to give %q, got %q", output, input, formatted)
+		}
+	})
+}
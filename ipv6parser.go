@@ -3,11 +3,13 @@ package tcpconnparser
 import (
 	"fmt"
 	"net"
+	"strings"
 )
 
 const (
 	bytesInIPv6Address   = 16
 	nibblesIn32BitWord   = 8
+	bytesIn32BitWord     = nibblesIn32BitWord / 2
 	wordsInIPv6Address   = 4
 	nibblesInIPv6Address = bytesInIPv6Address * 2
 )
@@ -37,7 +39,6 @@ func (*ipv6Parser) parseAddress(str string) (addr net.IP, err error) {
 	endIndex := startIndex + nibblesIn32BitWord
 
 	for i := 0; i < wordsInIPv6Address; i++ {
-		// TODO: Bad slicing can panic - recover and return an error
 		wordBytes, err := reverseBytesInHexWord(str[startIndex:endIndex])
 		if err != nil {
 			return nil, fmt.Errorf("reversing bytes in word: %w", err)
@@ -50,3 +51,25 @@ func (*ipv6Parser) parseAddress(str string) (addr net.IP, err error) {
 
 	return net.IP(addrBytes), nil
 }
+
+// FormatAddress formats net.IP objects into IP addresses in the format provided by the
+// /proc/net/tcp6 pseudo-file. A nil addr is formatted as the unspecified address.
+// IPv4 addresses are formatted as IPv4-mapped IPv6 addresses.
+func (*ipv6Parser) formatAddress(addr net.IP) (str string, err error) {
+	if addr == nil {
+		addr = net.IPv6unspecified
+	}
+
+	addrBytes := addr.To16()
+	if addrBytes == nil {
+		return "", fmt.Errorf("not an IPv6 address: %s", addr)
+	}
+
+	// Each 32-bit word is displayed little endian, as when parsing
+	var b strings.Builder
+	for i := 0; i < wordsInIPv6Address; i++ {
+		b.WriteString(hexWordFromReversedBytes(addrBytes[i*bytesIn32BitWord : (i+1)*bytesIn32BitWord]))
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,244 @@
+package tcpconnparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+)
+
+// JSONSchemaVersion is the version of the JSON schema used to marshal Connections.
+// It is incremented whenever a field is removed or its meaning changes.
+//
+// Version 1 of the schema is an object with the following fields:
+//
+//	schema          number  The schema version, always 1
+//	state           string  The State, e.g. "ESTABLISHED"
+//	kernel_state    string  The KernelState, e.g. "TCP_ESTABLISHED"
+//	family          string  The ProtocolVersion, "IPv4" or "IPv6"
+//	local_address   string  The local IP address, e.g. "127.0.0.1" or "::1"
+//	local_port      number  The local port
+//	remote_address  string  The remote IP address, omitted for listening connections
+//	remote_port     number  The remote port, omitted for listening connections
+//	rx_queue        number  The receive buffer size, omitted for listening connections
+//	tx_queue        number  The send buffer size, omitted for listening connections
+//	accept_backlog  number  The accept backlog, present only for listening connections
+//	uid             number  The UID owning the socket
+//	inode           number  The inode of the socket
+const JSONSchemaVersion = 1
+
+// JSONConnection is the representation of a Connection in version 1 of the JSON schema.
+type jsonConnection struct {
+	Schema          int             `json:"schema"`
+	State           State           `json:"state"`
+	KernelState     KernelState     `json:"kernel_state"`
+	ProtocolVersion ProtocolVersion `json:"family"`
+	LocalAddr       string          `json:"local_address"`
+	LocalPort       uint16          `json:"local_port"`
+	RemoteAddr      string          `json:"remote_address,omitempty"`
+	RemotePort      *uint16         `json:"remote_port,omitempty"`
+	RXQueue         *uint32         `json:"rx_queue,omitempty"`
+	TXQueue         *uint32         `json:"tx_queue,omitempty"`
+	AcceptBacklog   *uint32         `json:"accept_backlog,omitempty"`
+	UID             uint32          `json:"uid"`
+	INode           uint32          `json:"inode"`
+}
+
+// MarshalJSON marshals this Connection into JSON, according to the schema
+// described by JSONSchemaVersion.
+func (c *Connection) MarshalJSON() ([]byte, error) {
+	jsonConn := jsonConnection{
+		Schema:          JSONSchemaVersion,
+		State:           c.State,
+		KernelState:     c.KernelState,
+		ProtocolVersion: c.ProtocolVersion,
+		LocalAddr:       formatTextAddr(c.LocalAddr, c.ProtocolVersion),
+		LocalPort:       c.LocalPort,
+		UID:             c.UID,
+		INode:           c.INode,
+	}
+
+	if c.State == StateListen {
+		jsonConn.AcceptBacklog = &c.AcceptBacklog
+	} else {
+		jsonConn.RemoteAddr = formatTextAddr(c.RemoteAddr, c.ProtocolVersion)
+		jsonConn.RemotePort = &c.RemotePort
+		jsonConn.RXQueue = &c.ReceiveBufferSize
+		jsonConn.TXQueue = &c.SendBufferSize
+	}
+
+	return json.Marshal(jsonConn)
+}
+
+// UnmarshalJSON unmarshals this Connection from JSON, according to the schema
+// described by JSONSchemaVersion.
+func (c *Connection) UnmarshalJSON(data []byte) error {
+	var jsonConn jsonConnection
+	if err := json.Unmarshal(data, &jsonConn); err != nil {
+		return err
+	}
+
+	if jsonConn.Schema != JSONSchemaVersion {
+		return fmt.Errorf("unsupported connection schema version: %d", jsonConn.Schema)
+	}
+
+	localAddr, err := parseTextAddr(jsonConn.LocalAddr)
+	if err != nil {
+		return fmt.Errorf("parsing local address: %w", err)
+	}
+
+	var remoteAddr net.IP
+	if jsonConn.RemoteAddr != "" {
+		remoteAddr, err = parseTextAddr(jsonConn.RemoteAddr)
+		if err != nil {
+			return fmt.Errorf("parsing remote address: %w", err)
+		}
+	}
+
+	*c = Connection{
+		State:           jsonConn.State,
+		KernelState:     jsonConn.KernelState,
+		ProtocolVersion: jsonConn.ProtocolVersion,
+		LocalAddr:       localAddr,
+		LocalPort:       jsonConn.LocalPort,
+		RemoteAddr:      remoteAddr,
+		UID:             jsonConn.UID,
+		INode:           jsonConn.INode,
+	}
+
+	if jsonConn.RemotePort != nil {
+		c.RemotePort = *jsonConn.RemotePort
+	}
+
+	if jsonConn.RXQueue != nil {
+		c.ReceiveBufferSize = *jsonConn.RXQueue
+	}
+
+	if jsonConn.TXQueue != nil {
+		c.SendBufferSize = *jsonConn.TXQueue
+	}
+
+	if jsonConn.AcceptBacklog != nil {
+		c.AcceptBacklog = *jsonConn.AcceptBacklog
+	}
+
+	return nil
+}
+
+// FormatTextAddr formats the given address for the JSON schema and connection tables.
+// A nil address is formatted as the unspecified address of the given protocolVersion.
+func formatTextAddr(addr net.IP, protocolVersion ProtocolVersion) string {
+	if addr != nil {
+		return addr.String()
+	}
+
+	if protocolVersion == ProtocolVersionIPv6 {
+		return net.IPv6unspecified.String()
+	}
+
+	return net.IPv4zero.String()
+}
+
+// ParseTextAddr parses an address from the JSON schema or a connection table.
+func parseTextAddr(str string) (net.IP, error) {
+	addr := net.ParseIP(str)
+	if addr == nil {
+		return nil, fmt.Errorf("invalid IP address: %q", str)
+	}
+
+	return addr, nil
+}
+
+// MarshalJSON marshals this State into a JSON string.
+func (s State) MarshalJSON() ([]byte, error) {
+	if !s.valid() {
+		return nil, fmt.Errorf("illegal state: %q", string(s))
+	}
+
+	return json.Marshal(string(s))
+}
+
+// UnmarshalJSON unmarshals this State from a JSON string.
+func (s *State) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	if !State(str).valid() {
+		return fmt.Errorf("illegal state: %q", str)
+	}
+
+	*s = State(str)
+	return nil
+}
+
+// MarshalJSON marshals this KernelState into a JSON string of its name.
+func (ks KernelState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ks.String())
+}
+
+// UnmarshalJSON unmarshals this KernelState from a JSON string of its name.
+func (ks *KernelState) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	kernelState, err := ParseKernelStateName(str)
+	if err != nil {
+		return err
+	}
+
+	*ks = kernelState
+	return nil
+}
+
+// MarshalJSON marshals this ProtocolVersion into a JSON string of its name.
+func (pv ProtocolVersion) MarshalJSON() ([]byte, error) {
+	if _, err := parseProtocolVersion(pv.String()); err != nil {
+		return nil, fmt.Errorf("illegal protocol version: %d", int(pv))
+	}
+
+	return json.Marshal(pv.String())
+}
+
+// UnmarshalJSON unmarshals this ProtocolVersion from a JSON string of its name.
+func (pv *ProtocolVersion) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	protocolVersion, err := parseProtocolVersion(str)
+	if err != nil {
+		return err
+	}
+
+	*pv = protocolVersion
+	return nil
+}
+
+// JSONLinesEncoder writes a stream of Connections as JSON lines, i.e. one JSON
+// object, according to the schema described by JSONSchemaVersion, per line.
+type JSONLinesEncoder struct {
+	encoder *json.Encoder
+}
+
+// NewJSONLinesEncoder constructs a new JSONLinesEncoder writing to writer.
+func NewJSONLinesEncoder(writer io.Writer) *JSONLinesEncoder {
+	return &JSONLinesEncoder{
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+// Encode writes each of the given Connections as a JSON line.
+func (e *JSONLinesEncoder) Encode(conns ...*Connection) error {
+	for _, conn := range conns {
+		if err := e.encoder.Encode(conn); err != nil {
+			return fmt.Errorf("encoding connection: %w", err)
+		}
+	}
+
+	return nil
+}
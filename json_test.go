@@ -0,0 +1,180 @@
+package tcpconnparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestConnectionMarshalJSONListening(t *testing.T) {
+	conn := NewListeningConnection(ProtocolVersionIPv4,
+		50,
+		net.IPv4(127, 0, 0, 1),
+		6789,
+		1000,
+		789829)
+	expected := `{"schema":1,"state":"LISTEN","kernel_state":"TCP_LISTEN","family":"IPv4",` +
+		`"local_address":"127.0.0.1","local_port":6789,"accept_backlog":50,"uid":1000,"inode":789829}`
+
+	output, err := json.Marshal(conn)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if string(output) != expected {
+		t.Errorf("expected %s, got %s", expected, output)
+	}
+
+	t.Logf("got output %s", output)
+}
+
+func TestConnectionMarshalJSONNonListening(t *testing.T) {
+	conn := NewConnection(StateEstablished,
+		ProtocolVersionIPv6,
+		10,
+		20,
+		net.ParseIP("::1"),
+		6789,
+		net.ParseIP("::1"),
+		49018,
+		1000,
+		394269)
+	expected := `{"schema":1,"state":"ESTABLISHED","kernel_state":"TCP_ESTABLISHED","family":"IPv6",` +
+		`"local_address":"::1","local_port":6789,"remote_address":"::1","remote_port":49018,` +
+		`"rx_queue":10,"tx_queue":20,"uid":1000,"inode":394269}`
+
+	output, err := json.Marshal(conn)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if string(output) != expected {
+		t.Errorf("expected %s, got %s", expected, output)
+	}
+
+	t.Logf("got output %s", output)
+}
+
+func TestConnectionUnmarshalJSONRoundTrip(t *testing.T) {
+	conns := []*Connection{
+		NewListeningConnection(ProtocolVersionIPv4,
+			50,
+			net.IPv4(127, 0, 0, 1),
+			6789,
+			1000,
+			789829),
+		NewConnection(StateSynReceived,
+			ProtocolVersionIPv4,
+			0,
+			0,
+			net.IPv4(192, 168, 1, 3),
+			443,
+			net.IPv4(88, 221, 16, 125),
+			54176,
+			0,
+			0),
+	}
+	conns[1].KernelState = KernelStateNewSynRecv
+
+	for _, conn := range conns {
+		data, err := json.Marshal(conn)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		output := new(Connection)
+		if err := json.Unmarshal(data, output); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if !output.Equal(conn) {
+			t.Errorf("expected connection to be equal to %q, but was %q", conn, output)
+		}
+	}
+}
+
+func TestConnectionUnmarshalJSONSchemaError(t *testing.T) {
+	input := `{"schema":2,"state":"LISTEN","kernel_state":"TCP_LISTEN","family":"IPv4","local_address":"127.0.0.1"}`
+
+	err := json.Unmarshal([]byte(input), new(Connection))
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestStateUnmarshalJSONError(t *testing.T) {
+	input := `"NOT-A-STATE"`
+
+	var state State
+	err := json.Unmarshal([]byte(input), &state)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestProtocolVersionMarshalJSONError(t *testing.T) {
+	_, err := json.Marshal(ProtocolVersion(999))
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestProtocolVersionStringUnknown(t *testing.T) {
+	input := ProtocolVersion(999)
+	expected := "ProtocolVersion(999)"
+
+	output := input.String()
+	if output != expected {
+		t.Errorf("expected %q, got %q for input %d", expected, output, int(input))
+	}
+
+	t.Logf("got output %q for input %d", output, int(input))
+}
+
+func TestJSONLinesEncoder(t *testing.T) {
+	conns := []*Connection{
+		NewListeningConnection(ProtocolVersionIPv4,
+			50,
+			net.IPv4(127, 0, 0, 1),
+			6789,
+			1000,
+			789829),
+		NewListeningConnection(ProtocolVersionIPv6,
+			0,
+			net.ParseIP("::1"),
+			631,
+			0,
+			31267),
+	}
+
+	buf := new(bytes.Buffer)
+	if err := NewJSONLinesEncoder(buf).Encode(conns...); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(conns) {
+		t.Fatalf("expected %d lines, got %d: %q", len(conns), len(lines), buf.String())
+	}
+
+	for i, line := range lines {
+		output := new(Connection)
+		if err := json.Unmarshal([]byte(line), output); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if !output.Equal(conns[i]) {
+			t.Errorf("expected connection to be equal to %q, but was %q", conns[i], output)
+		}
+	}
+
+	t.Logf("got output %q", buf.String())
+}
@@ -0,0 +1,130 @@
+package tcpconnparser
+
+import "net"
+
+// ListenerGroup is a set of listening connections sharing a protocol version, local address
+// and local port, together with the connections they accepted. A group has several
+// listeners when they share the port with SO_REUSEPORT, each accepting a share of the
+// connections, which cannot be attributed to any one of them.
+type ListenerGroup struct {
+	ProtocolVersion ProtocolVersion
+	LocalAddr       net.IP // The unspecified address of the ProtocolVersion for wildcard listeners
+	LocalPort       uint16
+
+	Listeners []*Connection // The listening connections, in the order given
+	Accepted  []*Connection // The connections matched to the listeners, in the order given
+}
+
+// ListenerMatches is the mapping of connections to the ListenerGroups which accepted
+// them, as returned by MatchListeners.
+type ListenerMatches struct {
+	// The ListenerGroups, in the order of their first listener, including those
+	// which accepted no connections.
+	Groups []*ListenerGroup
+
+	groups map[*Connection]*ListenerGroup
+}
+
+// Group returns the ListenerGroup which accepted the given connection, or nil if none
+// did, e.g. as it is outbound or its listener has since closed.
+func (m *ListenerMatches) Group(conn *Connection) *ListenerGroup {
+	return m.groups[conn]
+}
+
+// MatchListeners matches each of the given non-listening connections to the ListenerGroup
+// which most likely accepted it, as the kernel would choose it: the group of the same
+// protocol version with the same local port and address, else the wildcard group of the
+// same protocol version and local port.
+//
+// Connections accepted by dual-stack IPv6 listeners are listed by the kernel as IPv6
+// connections with IPv4-mapped IPv6 addresses, so IPv4 connections never match IPv6
+// listeners. Connections in SYN-SENT, which are outbound, are never matched.
+func MatchListeners(conns []*Connection) *ListenerMatches {
+	matches := &ListenerMatches{
+		groups: make(map[*Connection]*ListenerGroup),
+	}
+
+	type listenerKey struct {
+		protocolVersion ProtocolVersion
+		localAddr       string
+		localPort       uint16
+	}
+
+	groupsByKey := make(map[listenerKey]*ListenerGroup)
+	groupsByPort := make(map[uint16][]*ListenerGroup)
+
+	for _, conn := range conns {
+		if conn.State != StateListen {
+			continue
+		}
+
+		localAddr := conn.LocalAddr
+		if localAddr == nil {
+			localAddr = unspecifiedAddr(conn.ProtocolVersion)
+		}
+
+		key := listenerKey{conn.ProtocolVersion, string(localAddr.To16()), conn.LocalPort}
+		group, ok := groupsByKey[key]
+		if !ok {
+			group = &ListenerGroup{
+				ProtocolVersion: conn.ProtocolVersion,
+				LocalAddr:       localAddr,
+				LocalPort:       conn.LocalPort,
+			}
+			groupsByKey[key] = group
+			groupsByPort[conn.LocalPort] = append(groupsByPort[conn.LocalPort], group)
+			matches.Groups = append(matches.Groups, group)
+		}
+
+		group.Listeners = append(group.Listeners, conn)
+	}
+
+	for _, conn := range conns {
+		if conn.State == StateListen || conn.State == StateSynSent {
+			continue
+		}
+
+		var best *ListenerGroup
+		var bestScore int
+
+		for _, group := range groupsByPort[conn.LocalPort] {
+			if score := listenerScore(group, conn); score > bestScore {
+				best, bestScore = group, score
+			}
+		}
+
+		if best != nil {
+			best.Accepted = append(best.Accepted, conn)
+			matches.groups[conn] = best
+		}
+	}
+
+	return matches
+}
+
+// ListenerScore returns how specifically the given ListenerGroup, on the same local port
+// as the given connection, matches it: 2 for the same protocol version and local address,
+// 1 for a wildcard listener of the same protocol version, or 0 for no match.
+func listenerScore(group *ListenerGroup, conn *Connection) int {
+	if group.ProtocolVersion != conn.ProtocolVersion {
+		return 0
+	}
+
+	switch {
+	case conn.LocalAddr != nil && group.LocalAddr.Equal(conn.LocalAddr):
+		return 2
+	case group.LocalAddr.IsUnspecified():
+		return 1
+	}
+
+	return 0
+}
+
+// UnspecifiedAddr returns the unspecified address of the given protocol version.
+func unspecifiedAddr(protocolVersion ProtocolVersion) net.IP {
+	if protocolVersion == ProtocolVersionIPv6 {
+		return net.IPv6unspecified
+	}
+
+	return net.IPv4zero
+}
@@ -0,0 +1,83 @@
+package tcpconnparser
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMatchListeners(t *testing.T) {
+	wildcard := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(0, 0, 0, 0), 80, 0, 1)
+	specific := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(10, 0, 0, 1), 80, 0, 2)
+	reusePort1 := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(0, 0, 0, 0), 8080, 0, 3)
+	reusePort2 := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(0, 0, 0, 0), 8080, 0, 4)
+	dualStack := NewListeningConnection(ProtocolVersionIPv6, 0, net.IPv6unspecified, 443, 0, 5)
+	sshIPv4 := NewListeningConnection(ProtocolVersionIPv4, 0, net.IPv4(0, 0, 0, 0), 22, 0, 6)
+	sshIPv6 := NewListeningConnection(ProtocolVersionIPv6, 0, net.IPv6unspecified, 22, 0, 7)
+
+	toSpecific := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 80, net.IPv4(198, 51, 100, 7), 40000, 0, 10)
+	toWildcard := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 2), 80, net.IPv4(198, 51, 100, 7), 40001, 0, 11)
+	toReusePort := NewConnection(StateTimeWait, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 8080, net.IPv4(198, 51, 100, 7), 40002, 0, 0)
+	mappedToDualStack := NewConnection(StateEstablished, ProtocolVersionIPv6, 0, 0,
+		net.ParseIP("::ffff:10.0.0.1"), 443, net.ParseIP("::ffff:198.51.100.7"), 40003, 0, 12)
+	ipv6ToDualStack := NewConnection(StateSynReceived, ProtocolVersionIPv6, 0, 0,
+		net.ParseIP("2001:db8::1"), 443, net.ParseIP("2001:db8::2"), 40004, 0, 0)
+	ipv4ToDualStack := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 443, net.IPv4(198, 51, 100, 7), 40005, 0, 13)
+	ipv4ToSSH := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 22, net.IPv4(198, 51, 100, 7), 40006, 0, 14)
+	ipv6ToSSH := NewConnection(StateEstablished, ProtocolVersionIPv6, 0, 0,
+		net.ParseIP("2001:db8::1"), 22, net.ParseIP("2001:db8::2"), 40007, 0, 15)
+	outbound := NewConnection(StateEstablished, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 40008, net.IPv4(198, 51, 100, 7), 80, 0, 16)
+	connecting := NewConnection(StateSynSent, ProtocolVersionIPv4, 0, 0,
+		net.IPv4(10, 0, 0, 1), 80, net.IPv4(198, 51, 100, 7), 80, 0, 17)
+
+	matches := MatchListeners([]*Connection{
+		wildcard, specific, reusePort1, reusePort2, dualStack, sshIPv4, sshIPv6,
+		toSpecific, toWildcard, toReusePort, mappedToDualStack, ipv6ToDualStack, ipv4ToDualStack,
+		ipv4ToSSH, ipv6ToSSH, outbound, connecting,
+	})
+
+	if len(matches.Groups) != 6 {
+		t.Fatalf("expected 6 listener groups, got %d", len(matches.Groups))
+	}
+
+	tests := []struct {
+		conn     *Connection
+		listener *Connection
+	}{
+		{toSpecific, specific},
+		{toWildcard, wildcard},
+		{toReusePort, reusePort1},
+		{mappedToDualStack, dualStack},
+		{ipv6ToDualStack, dualStack},
+		{ipv4ToSSH, sshIPv4},
+		{ipv6ToSSH, sshIPv6},
+	}
+
+	for _, test := range tests {
+		group := matches.Group(test.conn)
+		if group == nil || group.Listeners[0] != test.listener {
+			t.Errorf("expected connection from port %d to match listener %d, got %+v",
+				test.conn.RemotePort, test.listener.INode, group)
+		}
+	}
+
+	for _, conn := range []*Connection{ipv4ToDualStack, outbound, connecting} {
+		if group := matches.Group(conn); group != nil {
+			t.Errorf("expected connection from port %d to match no listener, got %+v", conn.RemotePort, group)
+		}
+	}
+
+	reusePort := matches.Group(toReusePort)
+	if len(reusePort.Listeners) != 2 || reusePort.Listeners[1] != reusePort2 {
+		t.Errorf("expected SO_REUSEPORT group of 2 listeners, got %v", reusePort.Listeners)
+	}
+
+	if accepted := matches.Group(mappedToDualStack).Accepted; len(accepted) != 2 {
+		t.Errorf("expected dual-stack listener to accept 2 connections, got %d", len(accepted))
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// InfluxMeasurement is the measurement name of the points written by WriteInflux.
+const InfluxMeasurement = "tcp_conn"
+
+// Tag keys of the points written by WriteInflux.
+const (
+	InfluxTagFamily    = "family"
+	InfluxTagLocalPort = "lport"
+	InfluxTagState     = "state"
+)
+
+// Replacer escaping tag keys and values in the InfluxDB line protocol.
+var influxTagEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+
+// InfluxPoint is the sums of the connections with a given family, state and local port.
+type influxPoint struct {
+	family, state, localPort             string
+	count, rxQueue, txQueue, acceptQueue uint64
+}
+
+// WriteInflux writes the given connections to writer in the InfluxDB line protocol, as
+// points of the tcp_conn measurement at the given timestamp. One point is written for each
+// combination of family, state and local port, tagged with them, with the fields:
+//
+//	count         The number of connections
+//	rx_queue      The summed receive queue sizes, in bytes
+//	tx_queue      The summed send queue sizes, in bytes
+//	accept_queue  The summed accept queue depths of listening connections
+//
+// WriteInflux has the signature of an Encoder, so may be pushed by a Pusher.
+func WriteInflux(writer io.Writer, conns []*tcpconnparser.Connection, timestamp time.Time) error {
+	points := make(map[string]*influxPoint)
+
+	for _, conn := range conns {
+		family := conn.ProtocolVersion.String()
+		state := string(conn.State)
+		localPort := strconv.Itoa(int(conn.LocalPort))
+
+		key := family + "\x00" + state + "\x00" + localPort
+		point, ok := points[key]
+		if !ok {
+			point = &influxPoint{
+				family:    family,
+				state:     state,
+				localPort: localPort,
+			}
+			points[key] = point
+		}
+
+		point.count++
+		point.rxQueue += uint64(conn.ReceiveBufferSize)
+		point.txQueue += uint64(conn.SendBufferSize)
+		point.acceptQueue += uint64(conn.AcceptBacklog)
+	}
+
+	keys := make([]string, 0, len(points))
+	for key := range points {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	buf := bufio.NewWriter(writer)
+
+	for _, key := range keys {
+		point := points[key]
+
+		// Tags are written sorted by key, as recommended for InfluxDB
+		fmt.Fprintf(buf, "%s,%s=%s,%s=%s,%s=%s count=%di,rx_queue=%di,tx_queue=%di,accept_queue=%di %d\n",
+			InfluxMeasurement,
+			InfluxTagFamily, influxTagEscaper.Replace(point.family),
+			InfluxTagLocalPort, influxTagEscaper.Replace(point.localPort),
+			InfluxTagState, influxTagEscaper.Replace(point.state),
+			point.count, point.rxQueue, point.txQueue, point.acceptQueue,
+			timestamp.UnixNano())
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("writing points: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteInflux(t *testing.T) {
+	expected := `tcp_conn,family=IPv4,lport=80,state=ESTABLISHED count=2i,rx_queue=101i,tx_queue=202i,accept_queue=0i 1700000000000000000
+tcp_conn,family=IPv4,lport=80,state=LISTEN count=2i,rx_queue=0i,tx_queue=0i,accept_queue=7i 1700000000000000000
+tcp_conn,family=IPv6,lport=443,state=TIME-WAIT count=1i,rx_queue=0i,tx_queue=0i,accept_queue=0i 1700000000000000000
+`
+
+	var buf bytes.Buffer
+	if err := WriteInflux(&buf, mockConns(), time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteInfluxNoConnections(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteInflux(&buf, nil, time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
@@ -0,0 +1,286 @@
+// package metrics implements the aggregation of TCP connections, as returned by the
+// tcpconnparser package, into metrics, and their exposition in the Prometheus text
+// format, without depending on the Prometheus client libraries.
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Types of metric families, as named in the Prometheus text format.
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+)
+
+// Names of the labels of the connection metrics.
+const (
+	LabelFamily     = "family"
+	LabelState      = "state"
+	LabelLocalPort  = "port"
+	LabelRemoteAddr = "remote"
+)
+
+// DefaultNamespace is the prefix of the metric names used if none is configured.
+const DefaultNamespace = "tcpconn"
+
+// Default prefix lengths to which remote addresses are aggregated with RemoteAddrPrefix.
+const (
+	DefaultIPv4PrefixLen = 24
+	DefaultIPv6PrefixLen = 64
+)
+
+// RemoteAddrMode selects how remote addresses are labelled on connection counts,
+// trading detail against label cardinality.
+type RemoteAddrMode int
+
+const (
+	// RemoteAddrDrop omits the remote address label.
+	RemoteAddrDrop RemoteAddrMode = iota
+
+	// RemoteAddrPrefix labels connections with the prefix containing the remote address,
+	// e.g. "198.51.100.0/24".
+	RemoteAddrPrefix
+
+	// RemoteAddrFull labels connections with the full remote address.
+	RemoteAddrFull
+)
+
+// Config configures a Collector. The zero value of each field selects a default.
+type Config struct {
+	Namespace string // Prefix of the metric names
+
+	// Whether to label connection counts with their local port.
+	LocalPorts bool
+
+	// How to label connection counts with their remote address.
+	RemoteAddrs   RemoteAddrMode
+	IPv4PrefixLen int // Prefix length used with RemoteAddrPrefix for IPv4
+	IPv6PrefixLen int // Prefix length used with RemoteAddrPrefix for IPv6
+
+	// The limit on the accept backlog of listeners, as returned by
+	// tcpconnparser.Parser.GetMaxAcceptBacklog. If zero, it is not reported.
+	MaxAcceptBacklog uint32
+}
+
+// Label is a name-value pair identifying a Sample within a Family.
+type Label struct {
+	Name, Value string
+}
+
+// Sample is a single value of a metric.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Family is a set of Samples of a metric with a given name.
+type Family struct {
+	Name, Help, Type string
+	Samples          []Sample
+}
+
+// Collector aggregates connections into metric Families.
+type Collector struct {
+	cfg Config
+}
+
+// NewCollector constructs a new Collector configured with cfg.
+func NewCollector(cfg Config) (*Collector, error) {
+	if cfg.Namespace == "" {
+		cfg.Namespace = DefaultNamespace
+	}
+
+	if cfg.IPv4PrefixLen == 0 {
+		cfg.IPv4PrefixLen = DefaultIPv4PrefixLen
+	}
+
+	if cfg.IPv6PrefixLen == 0 {
+		cfg.IPv6PrefixLen = DefaultIPv6PrefixLen
+	}
+
+	if cfg.IPv4PrefixLen < 0 || cfg.IPv4PrefixLen > 8*net.IPv4len {
+		return nil, fmt.Errorf("illegal IPv4 prefix length: %d", cfg.IPv4PrefixLen)
+	}
+
+	if cfg.IPv6PrefixLen < 0 || cfg.IPv6PrefixLen > 8*net.IPv6len {
+		return nil, fmt.Errorf("illegal IPv6 prefix length: %d", cfg.IPv6PrefixLen)
+	}
+
+	return &Collector{cfg: cfg}, nil
+}
+
+// WithMaxAcceptBacklog returns a copy of this Collector which reports the given limit
+// on the accept backlog of listeners.
+func (c *Collector) WithMaxAcceptBacklog(maxAcceptBacklog uint32) *Collector {
+	cfg := c.cfg
+	cfg.MaxAcceptBacklog = maxAcceptBacklog
+
+	return &Collector{cfg: cfg}
+}
+
+// Collect aggregates the given connections into metric Families, reporting:
+//   - the number of connections by family and state, and optionally local port
+//     and remote address
+//   - the summed receive and send queues of connections by family and state
+//   - the number of listeners, their summed accept queues and, if configured, their
+//     maximum accept backlog, by family and local port
+func (c *Collector) Collect(conns []*tcpconnparser.Connection) []*Family {
+	connCounts := newAggregate()
+	rxQueues := newAggregate()
+	txQueues := newAggregate()
+	listeners := newAggregate()
+	acceptQueues := newAggregate()
+
+	for _, conn := range conns {
+		family := Label{LabelFamily, conn.ProtocolVersion.String()}
+		state := Label{LabelState, string(conn.State)}
+
+		if conn.State == tcpconnparser.StateListen {
+			port := Label{LabelLocalPort, strconv.Itoa(int(conn.LocalPort))}
+			listeners.add(1, family, port)
+			acceptQueues.add(float64(conn.AcceptBacklog), family, port)
+		}
+
+		connLabels := []Label{family, state}
+		if c.cfg.LocalPorts {
+			connLabels = append(connLabels, Label{LabelLocalPort, strconv.Itoa(int(conn.LocalPort))})
+		}
+
+		if c.cfg.RemoteAddrs != RemoteAddrDrop {
+			connLabels = append(connLabels, Label{LabelRemoteAddr, c.remoteAddr(conn)})
+		}
+
+		connCounts.add(1, connLabels...)
+		rxQueues.add(float64(conn.ReceiveBufferSize), family, state)
+		txQueues.add(float64(conn.SendBufferSize), family, state)
+	}
+
+	families := []*Family{
+		connCounts.family(c.name("connections"),
+			"Number of TCP connections.",
+			TypeGauge),
+		rxQueues.family(c.name("receive_queue_bytes"),
+			"Summed receive queue sizes of TCP connections, in bytes.",
+			TypeGauge),
+		txQueues.family(c.name("send_queue_bytes"),
+			"Summed send queue sizes of TCP connections, in bytes.",
+			TypeGauge),
+		listeners.family(c.name("listeners"),
+			"Number of listening TCP sockets.",
+			TypeGauge),
+		acceptQueues.family(c.name("listener_accept_queue"),
+			"Summed accept queue depths of listening TCP sockets.",
+			TypeGauge),
+	}
+
+	if c.cfg.MaxAcceptBacklog > 0 {
+		maxBacklogs := newAggregate()
+		for _, sample := range acceptQueues.samples {
+			maxBacklogs.samples[sample.key] = &aggregateSample{
+				labels: sample.labels,
+				value:  float64(c.cfg.MaxAcceptBacklog),
+			}
+		}
+
+		families = append(families, maxBacklogs.family(c.name("listener_max_backlog"),
+			"Upper bound on the accept backlog of listening TCP sockets, as given by net.core.somaxconn.",
+			TypeGauge))
+	}
+
+	return families
+}
+
+// Name returns the given metric name prefixed with the configured namespace.
+func (c *Collector) name(name string) string {
+	return c.cfg.Namespace + "_" + name
+}
+
+// RemoteAddr returns the remote address label value of the given connection.
+// Listening connections have no remote address, so are given an empty value.
+func (c *Collector) remoteAddr(conn *tcpconnparser.Connection) string {
+	if conn.State == tcpconnparser.StateListen || conn.RemoteAddr == nil {
+		return ""
+	}
+
+	if c.cfg.RemoteAddrs == RemoteAddrFull {
+		return conn.RemoteAddr.String()
+	}
+
+	return tcpconnparser.Prefix(conn.RemoteAddr, c.cfg.IPv4PrefixLen, c.cfg.IPv6PrefixLen).String()
+}
+
+// Aggregate sums values by their labels.
+type aggregate struct {
+	samples map[string]*aggregateSample
+}
+
+// AggregateSample is the sum of the values with a set of labels.
+type aggregateSample struct {
+	key    string
+	labels []Label
+	value  float64
+}
+
+// NewAggregate constructs a new, empty aggregate.
+func newAggregate() *aggregate {
+	return &aggregate{
+		samples: make(map[string]*aggregateSample),
+	}
+}
+
+// Add adds value to the sum of the values with the given labels.
+func (a *aggregate) add(value float64, labels ...Label) {
+	var key strings.Builder
+	for _, label := range labels {
+		key.WriteString(label.Name)
+		key.WriteByte(0)
+		key.WriteString(label.Value)
+		key.WriteByte(0)
+	}
+
+	sample, ok := a.samples[key.String()]
+	if !ok {
+		sample = &aggregateSample{
+			key:    key.String(),
+			labels: append([]Label(nil), labels...),
+		}
+		a.samples[sample.key] = sample
+	}
+
+	sample.value += value
+}
+
+// Family returns the sums as a Family with the given name, help and type, with
+// its Samples ordered by their labels.
+func (a *aggregate) family(name, help, typ string) *Family {
+	keys := make([]string, 0, len(a.samples))
+	for key := range a.samples {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	family := &Family{
+		Name:    name,
+		Help:    help,
+		Type:    typ,
+		Samples: make([]Sample, 0, len(keys)),
+	}
+
+	for _, key := range keys {
+		sample := a.samples[key]
+		family.Samples = append(family.Samples, Sample{
+			Labels: sample.labels,
+			Value:  sample.value,
+		})
+	}
+
+	return family
+}
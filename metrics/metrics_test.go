@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// MockConns returns a set of connections exercising each metric.
+func mockConns() []*tcpconnparser.Connection {
+	return []*tcpconnparser.Connection{
+		tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv4,
+			5,
+			net.IPv4(0, 0, 0, 0),
+			80,
+			0,
+			1),
+		tcpconnparser.NewListeningConnection(tcpconnparser.ProtocolVersionIPv4,
+			2,
+			net.IPv4(127, 0, 0, 1),
+			80,
+			0,
+			2),
+		tcpconnparser.NewConnection(tcpconnparser.StateEstablished,
+			tcpconnparser.ProtocolVersionIPv4,
+			100,
+			200,
+			net.IPv4(10, 0, 0, 1),
+			80,
+			net.IPv4(198, 51, 100, 7),
+			40000,
+			0,
+			3),
+		tcpconnparser.NewConnection(tcpconnparser.StateEstablished,
+			tcpconnparser.ProtocolVersionIPv4,
+			1,
+			2,
+			net.IPv4(10, 0, 0, 1),
+			80,
+			net.IPv4(198, 51, 100, 9),
+			40001,
+			0,
+			4),
+		tcpconnparser.NewConnection(tcpconnparser.StateTimeWait,
+			tcpconnparser.ProtocolVersionIPv6,
+			0,
+			0,
+			net.ParseIP("2001:db8::1"),
+			443,
+			net.ParseIP("2001:db8:1::5"),
+			40002,
+			0,
+			0),
+	}
+}
+
+// FindSample returns the value of the sample of the named family with the given labels.
+func findSample(t *testing.T, families []*Family, name string, labels ...Label) float64 {
+	t.Helper()
+
+	for _, family := range families {
+		if family.Name != name {
+			continue
+		}
+
+	samples:
+		for _, sample := range family.Samples {
+			if len(sample.Labels) != len(labels) {
+				continue
+			}
+
+			for i := range labels {
+				if sample.Labels[i] != labels[i] {
+					continue samples
+				}
+			}
+
+			return sample.Value
+		}
+	}
+
+	t.Fatalf("no sample of %s with labels %v", name, labels)
+	return 0
+}
+
+func TestCollect(t *testing.T) {
+	collector, err := NewCollector(Config{MaxAcceptBacklog: 4096})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	families := collector.Collect(mockConns())
+	ipv4 := Label{LabelFamily, "IPv4"}
+	established := Label{LabelState, "ESTABLISHED"}
+	port80 := Label{LabelLocalPort, "80"}
+
+	if v := findSample(t, families, "tcpconn_connections", ipv4, established); v != 2 {
+		t.Errorf("expected 2 established IPv4 connections, got %v", v)
+	}
+
+	if v := findSample(t, families, "tcpconn_receive_queue_bytes", ipv4, established); v != 101 {
+		t.Errorf("expected summed receive queues of 101, got %v", v)
+	}
+
+	if v := findSample(t, families, "tcpconn_send_queue_bytes", ipv4, established); v != 202 {
+		t.Errorf("expected summed send queues of 202, got %v", v)
+	}
+
+	if v := findSample(t, families, "tcpconn_listeners", ipv4, port80); v != 2 {
+		t.Errorf("expected 2 listeners on port 80, got %v", v)
+	}
+
+	if v := findSample(t, families, "tcpconn_listener_accept_queue", ipv4, port80); v != 7 {
+		t.Errorf("expected summed accept queue of 7 on port 80, got %v", v)
+	}
+
+	if v := findSample(t, families, "tcpconn_listener_max_backlog", ipv4, port80); v != 4096 {
+		t.Errorf("expected max backlog of 4096 on port 80, got %v", v)
+	}
+}
+
+func TestCollectRemoteAddrPrefix(t *testing.T) {
+	collector, err := NewCollector(Config{
+		Namespace:     "test",
+		RemoteAddrs:   RemoteAddrPrefix,
+		IPv4PrefixLen: 24,
+		IPv6PrefixLen: 48,
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	families := collector.Collect(mockConns())
+
+	v := findSample(t, families, "test_connections",
+		Label{LabelFamily, "IPv4"},
+		Label{LabelState, "ESTABLISHED"},
+		Label{LabelRemoteAddr, "198.51.100.0/24"})
+	if v != 2 {
+		t.Errorf("expected 2 connections from 198.51.100.0/24, got %v", v)
+	}
+
+	v = findSample(t, families, "test_connections",
+		Label{LabelFamily, "IPv6"},
+		Label{LabelState, "TIME-WAIT"},
+		Label{LabelRemoteAddr, "2001:db8:1::/48"})
+	if v != 1 {
+		t.Errorf("expected 1 connection from 2001:db8:1::/48, got %v", v)
+	}
+}
+
+func TestNewCollectorIllegalPrefixLenError(t *testing.T) {
+	_, err := NewCollector(Config{IPv4PrefixLen: 33})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// Maximum size of the datagrams sent over UDP, chosen to avoid fragmentation on
+// typical networks, as recommended by StatsD and Telegraf.
+const maxDatagramSize = 1432
+
+// Timeout of dialling and writing to the destination of a Pusher.
+const pushTimeout = 10 * time.Second
+
+// Encoder writes a snapshot of connections, taken at the given timestamp, to writer as
+// newline-terminated lines. WriteInflux and StatsDEncoder.Encode are Encoders.
+type Encoder func(writer io.Writer, conns []*tcpconnparser.Connection, timestamp time.Time) error
+
+// Source returns a snapshot of connections, e.g. by calling tcpconnparser.Parser.GetConnections.
+type Source func() ([]*tcpconnparser.Connection, error)
+
+// Pusher periodically encodes snapshots of connections and sends them to a remote
+// address over UDP or TCP, e.g. to a Telegraf or StatsD agent.
+//
+// Over UDP, lines are batched into datagrams of at most 1432 bytes, without splitting
+// lines. Over TCP, a single connection is kept open and redialled if a push fails.
+type Pusher struct {
+	network, address string
+	source           Source
+	encoder          Encoder
+	sent             func() // Called after each snapshot is sent, if not nil
+	now              func() time.Time
+
+	conn net.Conn
+	buf  bytes.Buffer
+}
+
+// NewPusher constructs a new Pusher sending the snapshots returned by source, encoded
+// with encoder, to the given address on network, which must be one of "udp", "udp4",
+// "udp6", "tcp", "tcp4" or "tcp6".
+func NewPusher(network, address string, source Source, encoder Encoder) (*Pusher, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported network: %q", network)
+	}
+
+	return &Pusher{
+		network: network,
+		address: address,
+		source:  source,
+		encoder: encoder,
+		now:     time.Now,
+	}, nil
+}
+
+// NewStatsDPusher constructs a new Pusher sending the metrics collected by collector from
+// the snapshots returned by source as StatsD gauges, encoded by a StatsDEncoder which is
+// told of each snapshot sent, to the given address on network, as for NewPusher.
+func NewStatsDPusher(network, address string, source Source, collector *Collector) (*Pusher, error) {
+	encoder := NewStatsDEncoder(collector)
+
+	pusher, err := NewPusher(network, address, source, encoder.Encode)
+	if err != nil {
+		return nil, err
+	}
+
+	pusher.sent = encoder.Sent
+	return pusher, nil
+}
+
+// Push takes a snapshot of connections and sends it.
+func (p *Pusher) Push() error {
+	timestamp := p.now()
+
+	conns, err := p.source()
+	if err != nil {
+		return fmt.Errorf("getting connections: %w", err)
+	}
+
+	p.buf.Reset()
+	if err := p.encoder(&p.buf, conns, timestamp); err != nil {
+		return fmt.Errorf("encoding connections: %w", err)
+	}
+
+	if p.conn == nil {
+		conn, err := net.DialTimeout(p.network, p.address, pushTimeout)
+		if err != nil {
+			return fmt.Errorf("dialling %s %s: %w", p.network, p.address, err)
+		}
+
+		p.conn = conn
+	}
+
+	if err := p.send(p.buf.Bytes()); err != nil {
+		// Redial on the next push, as the connection may have been closed by the peer
+		p.conn.Close()
+		p.conn = nil
+
+		return fmt.Errorf("sending to %s %s: %w", p.network, p.address, err)
+	}
+
+	if p.sent != nil {
+		p.sent()
+	}
+
+	return nil
+}
+
+// Send writes the given lines to the connection, batched into datagrams for UDP.
+func (p *Pusher) send(lines []byte) error {
+	if err := p.conn.SetWriteDeadline(time.Now().Add(pushTimeout)); err != nil {
+		return err
+	}
+
+	if _, ok := p.conn.(*net.UDPConn); !ok {
+		_, err := p.conn.Write(lines)
+		return err
+	}
+
+	for len(lines) > 0 {
+		datagram := nextDatagram(lines)
+		if _, err := p.conn.Write(datagram); err != nil {
+			return err
+		}
+
+		lines = lines[len(datagram):]
+	}
+
+	return nil
+}
+
+// NextDatagram returns the longest prefix of lines, made up of whole lines, which fits in
+// a datagram. If the first line does not fit, it is returned alone.
+func nextDatagram(lines []byte) []byte {
+	end := 0
+	for end < len(lines) {
+		next := bytes.IndexByte(lines[end:], '\n')
+		if next < 0 {
+			next = len(lines) - end - 1
+		}
+
+		if end > 0 && end+next+1 > maxDatagramSize {
+			break
+		}
+
+		end += next + 1
+	}
+
+	return lines[:end]
+}
+
+// Run pushes a snapshot immediately, then at each interval, until ctx is cancelled.
+// Errors pushing snapshots are passed to onError, if not nil, and do not stop the Pusher.
+// Run returns the error of ctx when it is cancelled, or an error without pushing if
+// interval is not positive.
+func (p *Pusher) Run(ctx context.Context, interval time.Duration, onError func(error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("illegal push interval: %v", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Push(); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the connection to the destination, if open.
+func (p *Pusher) Close() error {
+	if p.conn == nil {
+		return nil
+	}
+
+	err := p.conn.Close()
+	p.conn = nil
+
+	return err
+}
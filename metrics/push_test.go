@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+func mockSource() ([]*tcpconnparser.Connection, error) {
+	return mockConns(), nil
+}
+
+// MockLinesEncoder writes the given number of numbered lines of the given length,
+// regardless of the connections.
+func mockLinesEncoder(lines, length int) Encoder {
+	return func(writer io.Writer, _ []*tcpconnparser.Connection, _ time.Time) error {
+		for i := 0; i < lines; i++ {
+			line := fmt.Sprintf("%04d", i)
+			fmt.Fprintf(writer, "%s%s\n", line, strings.Repeat("x", length-len(line)-1))
+		}
+
+		return nil
+	}
+}
+
+func TestPusherUDPBatchesDatagrams(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer listener.Close()
+
+	// 100 lines of 100 bytes fit 14 to a datagram
+	const noOfLines, lineLen = 100, 100
+	pusher, err := NewPusher("udp", listener.LocalAddr().String(), mockSource, mockLinesEncoder(noOfLines, lineLen))
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer pusher.Close()
+
+	if err := pusher.Push(); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	var received bytes.Buffer
+	datagrams := 0
+	buf := make([]byte, 65536)
+
+	for received.Len() < noOfLines*lineLen {
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if n > maxDatagramSize {
+			t.Errorf("expected datagram of at most %d bytes, got %d", maxDatagramSize, n)
+		}
+
+		if buf[n-1] != '\n' {
+			t.Errorf("expected datagram to end with a whole line, got %q", buf[:n])
+		}
+
+		received.Write(buf[:n])
+		datagrams++
+	}
+
+	if expected := 8; datagrams != expected {
+		t.Errorf("expected %d datagrams, got %d", expected, datagrams)
+	}
+
+	var expected bytes.Buffer
+	mockLinesEncoder(noOfLines, lineLen)(&expected, nil, time.Time{})
+	if received.String() != expected.String() {
+		t.Error("expected received lines to equal encoded lines")
+	}
+}
+
+func TestPusherTCPSendsInflux(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 16)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	pusher, err := NewPusher("tcp", listener.Addr().String(), mockSource, WriteInflux)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer pusher.Close()
+
+	pusher.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	// Both pushes are sent over the same connection
+	for i := 0; i < 2; i++ {
+		if err := pusher.Push(); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+	}
+
+	expected := "tcp_conn,family=IPv4,lport=80,state=ESTABLISHED count=2i,rx_queue=101i,tx_queue=202i,accept_queue=0i 1700000000000000000"
+	for i := 0; i < 6; i++ {
+		select {
+		case line := <-lines:
+			if i%3 == 0 && line != expected {
+				t.Errorf("expected line %q, got %q", expected, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for line %d", i)
+		}
+	}
+}
+
+func TestStatsDPusherZeroesGoneGaugesAfterFailedSend(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer listener.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	closed.Close()
+
+	collector, err := NewCollector(Config{})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	conns := mockConns()
+	source := func() ([]*tcpconnparser.Connection, error) { return conns, nil }
+
+	pusher, err := NewStatsDPusher("udp", listener.LocalAddr().String(), source, collector)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer pusher.Close()
+
+	if err := pusher.Push(); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	// The listener goes away, and the push zeroing its gauge fails
+	conns = nil
+	pusher.Close()
+	pusher.network, pusher.address = "tcp", closed.Addr().String()
+	if err := pusher.Push(); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	pusher.network, pusher.address = "udp", listener.LocalAddr().String()
+	if err := pusher.Push(); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	expected := "tcpconn_listener_accept_queue.IPv4.80:0|g\n"
+	buf := make([]byte, 65536)
+	for {
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("expected line %q, got error %v (of type %T)", expected, err, err)
+		}
+
+		if strings.Contains(string(buf[:n]), expected) {
+			break
+		}
+	}
+}
+
+func TestPusherRunReportsErrors(t *testing.T) {
+	mockErr := errors.New("mock error")
+	source := func() ([]*tcpconnparser.Connection, error) {
+		return nil, mockErr
+	}
+
+	pusher, err := NewPusher("udp", "127.0.0.1:9", source, WriteInflux)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer pusher.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errs := 0
+	err = pusher.Run(ctx, time.Millisecond, func(err error) {
+		if !errors.Is(err, mockErr) {
+			t.Errorf("expected error %v, got %v (of type %T)", mockErr, err, err)
+		}
+
+		if errs++; errs == 3 {
+			cancel()
+		}
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected error %v, got %v (of type %T)", context.Canceled, err, err)
+	}
+}
+
+func TestPusherRunIllegalIntervalError(t *testing.T) {
+	pusher, err := NewPusher("udp", "127.0.0.1:9", mockSource, WriteInflux)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+	defer pusher.Close()
+
+	err = pusher.Run(context.Background(), 0, nil)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestNewPusherUnsupportedNetworkError(t *testing.T) {
+	_, err := NewPusher("unix", "/tmp/sock", mockSource, WriteInflux)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+// WriteStatsD writes the given Families to writer as StatsD gauges, one per line.
+// The name of each gauge is the name of its Family followed by the values of its
+// labels, separated by dots, e.g. "tcpconn_connections.IPv4.ESTABLISHED:3|g".
+// Characters of label values not permitted in StatsD names, such as the dots and
+// colons of IP addresses, are replaced with underscores. Empty label values are
+// written as "none".
+func WriteStatsD(writer io.Writer, families []*Family) error {
+	buf := bufio.NewWriter(writer)
+
+	for _, family := range families {
+		for _, sample := range family.Samples {
+			writeStatsDGauge(buf, statsDName(family.Name, sample.Labels), sample.Value)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("writing gauges: %w", err)
+	}
+
+	return nil
+}
+
+// StatsDEncoder encodes the metrics collected from snapshots of connections as StatsD
+// gauges, as written by WriteStatsD. A StatsD server reports the last value sent for a
+// gauge until it is sent another, so gauges previously written whose labels are not
+// collected from the current snapshot, e.g. as no connections remain in a state, are
+// written with a value of zero. They are written again with each snapshot until Sent
+// confirms that a snapshot including them has been delivered.
+type StatsDEncoder struct {
+	collector *Collector
+	live      map[string]bool // Names of the gauges which may have a non-zero value
+	zeroed    []string        // Names of the gauges zeroed by the last snapshot encoded
+}
+
+// NewStatsDEncoder constructs a new StatsDEncoder encoding the metrics collected by
+// collector.
+func NewStatsDEncoder(collector *Collector) *StatsDEncoder {
+	return &StatsDEncoder{
+		collector: collector,
+		live:      make(map[string]bool),
+	}
+}
+
+// Encode writes the metrics collected from the given connections to writer as StatsD
+// gauges, followed by zero-valued gauges for those previously written but not for this
+// snapshot. As StatsD servers timestamp gauges on receipt, the timestamp is ignored.
+//
+// Encode has the signature of an Encoder, so may be pushed by a Pusher, which must call
+// Sent once each snapshot is delivered, as one constructed by NewStatsDPusher does.
+func (e *StatsDEncoder) Encode(writer io.Writer, conns []*tcpconnparser.Connection, _ time.Time) error {
+	buf := bufio.NewWriter(writer)
+	written := make(map[string]bool, len(e.live))
+
+	for _, family := range e.collector.Collect(conns) {
+		for _, sample := range family.Samples {
+			name := statsDName(family.Name, sample.Labels)
+			writeStatsDGauge(buf, name, sample.Value)
+			written[name] = true
+		}
+	}
+
+	var gone []string
+	for name := range e.live {
+		if !written[name] {
+			gone = append(gone, name)
+		}
+	}
+
+	sort.Strings(gone)
+	for _, name := range gone {
+		writeStatsDGauge(buf, name, 0)
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("writing gauges: %w", err)
+	}
+
+	for name := range written {
+		e.live[name] = true
+	}
+
+	e.zeroed = gone
+	return nil
+}
+
+// Sent confirms that the snapshot last encoded has been delivered, so the gauges it
+// zeroed need not be written again.
+func (e *StatsDEncoder) Sent() {
+	for _, name := range e.zeroed {
+		delete(e.live, name)
+	}
+
+	e.zeroed = nil
+}
+
+// StatsDName returns the name of the StatsD gauge of the sample with the given labels
+// of the named Family.
+func statsDName(familyName string, labels []Label) string {
+	var name strings.Builder
+	name.WriteString(familyName)
+
+	for _, label := range labels {
+		name.WriteByte('.')
+		name.WriteString(statsDNameComponent(label.Value))
+	}
+
+	return name.String()
+}
+
+// WriteStatsDGauge writes a StatsD gauge line with the given name and value to buf.
+func writeStatsDGauge(buf *bufio.Writer, name string, value float64) {
+	buf.WriteString(name)
+	buf.WriteByte(':')
+	buf.WriteString(formatValue(value))
+	buf.WriteString("|g\n")
+}
+
+// StatsDNameComponent returns the given label value with characters not permitted in
+// StatsD names replaced.
+func statsDNameComponent(value string) string {
+	if value == "" {
+		return "none"
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhwbarlow/tcpconnparser"
+)
+
+func TestWriteStatsD(t *testing.T) {
+	families := []*Family{
+		{
+			Name: "tcpconn_connections",
+			Type: TypeGauge,
+			Samples: []Sample{
+				{Labels: []Label{{LabelFamily, "IPv4"}, {LabelState, "ESTABLISHED"}}, Value: 3},
+				{Labels: []Label{{LabelFamily, "IPv6"}, {LabelRemoteAddr, "2001:db8::/64"}}, Value: 1},
+				{Labels: []Label{{LabelFamily, "IPv4"}, {LabelRemoteAddr, ""}}, Value: 2},
+			},
+		},
+		{
+			Name:    "tcpconn_listeners",
+			Type:    TypeGauge,
+			Samples: []Sample{{Value: 0.5}},
+		},
+	}
+	expected := `tcpconn_connections.IPv4.ESTABLISHED:3|g
+tcpconn_connections.IPv6.2001_db8___64:1|g
+tcpconn_connections.IPv4.none:2|g
+tcpconn_listeners:0.5|g
+`
+
+	var buf bytes.Buffer
+	if err := WriteStatsD(&buf, families); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestStatsDEncoderEncode(t *testing.T) {
+	collector, err := NewCollector(Config{})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewStatsDEncoder(collector).Encode(&buf, mockConns(), time.Time{}); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	expected := "tcpconn_listener_accept_queue.IPv4.80:7|g\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected line %q in output:\n%s", expected, buf.String())
+	}
+}
+
+func TestStatsDEncoderEncodeZeroesGoneGauges(t *testing.T) {
+	collector, err := NewCollector(Config{})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	encoder := NewStatsDEncoder(collector)
+	expected := "tcpconn_listener_accept_queue.IPv4.80:0|g\n"
+
+	// The gauge is zeroed until a push zeroing it is sent
+	for i, test := range []struct {
+		conns    []*tcpconnparser.Connection
+		sent     bool
+		expected bool
+	}{
+		{mockConns(), true, false},
+		{nil, false, true},
+		{nil, true, true},
+		{nil, true, false},
+	} {
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, test.conns, time.Time{}); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if test.sent {
+			encoder.Sent()
+		}
+
+		if output := strings.Contains(buf.String(), expected); output != test.expected {
+			t.Errorf("expected line %q in output of push %d to be %t, got output:\n%s",
+				expected, i, test.expected, buf.String())
+		}
+	}
+}
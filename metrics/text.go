@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TextContentType is the HTTP content type of the Prometheus text format written by WriteText.
+const TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Replacer escaping label values in the Prometheus text format.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Replacer escaping help text in the Prometheus text format.
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// WriteText writes the given Families to writer in the Prometheus text exposition format.
+// Families without Samples are omitted.
+func WriteText(writer io.Writer, families []*Family) error {
+	buf := bufio.NewWriter(writer)
+
+	for _, family := range families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buf, "# HELP %s %s\n", family.Name, helpEscaper.Replace(family.Help))
+		fmt.Fprintf(buf, "# TYPE %s %s\n", family.Name, family.Type)
+
+		for _, sample := range family.Samples {
+			buf.WriteString(family.Name)
+
+			if len(sample.Labels) > 0 {
+				buf.WriteByte('{')
+				for i, label := range sample.Labels {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+
+					fmt.Fprintf(buf, "%s=\"%s\"", label.Name, labelValueEscaper.Replace(label.Value))
+				}
+				buf.WriteByte('}')
+			}
+
+			buf.WriteByte(' ')
+			buf.WriteString(formatValue(sample.Value))
+			buf.WriteByte('\n')
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("writing metrics: %w", err)
+	}
+
+	return nil
+}
+
+// FormatValue formats a sample value per the Prometheus text format.
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
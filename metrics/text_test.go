@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	families := []*Family{
+		{
+			Name: "tcpconn_connections",
+			Help: "Number of TCP connections.",
+			Type: TypeGauge,
+			Samples: []Sample{
+				{Labels: []Label{{LabelFamily, "IPv4"}, {LabelState, "ESTABLISHED"}}, Value: 2},
+				{Labels: []Label{{LabelRemoteAddr, "a\"b\\c\n"}}, Value: math.Inf(1)},
+			},
+		},
+		{
+			Name: "tcpconn_empty",
+			Help: "Omitted.",
+			Type: TypeGauge,
+		},
+		{
+			Name:    "tcpconn_errors_total",
+			Help:    "Errors.",
+			Type:    TypeCounter,
+			Samples: []Sample{{Value: 0.5}},
+		},
+	}
+	expected := `# HELP tcpconn_connections Number of TCP connections.
+# TYPE tcpconn_connections gauge
+tcpconn_connections{family="IPv4",state="ESTABLISHED"} 2
+tcpconn_connections{remote="a\"b\\c\n"} +Inf
+# HELP tcpconn_errors_total Errors.
+# TYPE tcpconn_errors_total counter
+tcpconn_errors_total 0.5
+`
+
+	buf := new(bytes.Buffer)
+	if err := WriteText(buf, families); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	t.Logf("got output %q", buf.String())
+}
@@ -0,0 +1,303 @@
+package tcpconnparser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Paths of the network counter pseudo-files, relative to the procfs root.
+const (
+	snmpPath    = "net/snmp"
+	snmp6Path   = "net/snmp6"
+	netstatPath = "net/netstat"
+)
+
+// Protocols of the counters held in the typed fields of NetCounters.
+const (
+	counterProtocolTCP    = "Tcp"
+	counterProtocolTCPExt = "TcpExt"
+)
+
+// Counters which are gauges rather than ever-increasing counts, so have no rate.
+var gaugeCounters = map[string]map[string]bool{
+	"Ip":               {"Forwarding": true, "DefaultTTL": true},
+	counterProtocolTCP: {"RtoAlgorithm": true, "RtoMin": true, "RtoMax": true, "MaxConn": true, "CurrEstab": true},
+}
+
+// NetCounters is the system-wide network counters reported by the kernel in the procfs
+// /proc/net/snmp, /proc/net/snmp6 and /proc/net/netstat pseudo-files, as read at Time.
+type NetCounters struct {
+	Time time.Time // The time at which the counters were read
+
+	TCP    TCPCounters    // The Tcp counters of /proc/net/snmp
+	TCPExt TCPExtCounters // The TcpExt counters of /proc/net/netstat
+
+	// All the counters of the pseudo-files, keyed by protocol and name, e.g.
+	// Counters["TcpExt"]["ListenOverflows"]. The counters of /proc/net/snmp6, which are
+	// named with their protocol, e.g. "Udp6InDatagrams", are split likewise, e.g.
+	// Counters["Udp6"]["InDatagrams"]. Counters not held in a typed field, such as those
+	// added by later kernels, are available only here. As the kernel counts in unsigned
+	// longs, counters above the largest int64 are held with the same bits, so are negative.
+	Counters map[string]map[string]int64
+}
+
+// TCPCounters is the Tcp counters of /proc/net/snmp, as defined in RFC 4022.
+// The counts include both IPv4 and IPv6 connections.
+type TCPCounters struct {
+	RtoAlgorithm int64 // Gauge
+	RtoMin       int64 // Gauge, in milliseconds
+	RtoMax       int64 // Gauge, in milliseconds
+	MaxConn      int64 // Gauge, -1 as the number of connections is not limited
+	ActiveOpens  int64
+	PassiveOpens int64
+	AttemptFails int64
+	EstabResets  int64
+	CurrEstab    int64 // Gauge
+	InSegs       int64
+	OutSegs      int64
+	RetransSegs  int64
+	InErrs       int64
+	OutRsts      int64
+	InCsumErrors int64
+}
+
+// TCPExtCounters is a selection of the TcpExt counters of /proc/net/netstat, which are
+// specific to Linux. The remainder are available only in NetCounters.Counters.
+type TCPExtCounters struct {
+	SyncookiesSent       int64
+	SyncookiesRecv       int64
+	SyncookiesFailed     int64
+	EmbryonicRsts        int64
+	PruneCalled          int64
+	TW                   int64
+	TWRecycled           int64
+	TWKilled             int64
+	DelayedACKs          int64
+	ListenOverflows      int64
+	ListenDrops          int64
+	TCPLostRetransmit    int64
+	TCPFastRetrans       int64
+	TCPSlowStartRetrans  int64
+	TCPTimeouts          int64
+	TCPAbortOnData       int64
+	TCPAbortOnClose      int64
+	TCPAbortOnMemory     int64
+	TCPAbortOnTimeout    int64
+	TCPAbortOnLinger     int64
+	TCPAbortFailed       int64
+	TCPMemoryPressures   int64
+	TCPBacklogDrop       int64
+	TCPTimeWaitOverflow  int64
+	TCPReqQFullDoCookies int64
+	TCPReqQFullDrop      int64
+	TCPRetransFail       int64
+	TCPOFODrop           int64
+	TCPSynRetrans        int64
+	TCPKeepAlive         int64
+	TCPZeroWindowDrop    int64
+	TCPRcvQDrop          int64
+}
+
+// GetNetCounters returns the network counters read from /proc/net/snmp, /proc/net/netstat
+// and, if it exists, /proc/net/snmp6, which is absent on hosts where IPv6 is disabled.
+func (p *Parser) GetNetCounters() (*NetCounters, error) {
+	netCounters := &NetCounters{
+		Time:     time.Now(),
+		Counters: make(map[string]map[string]int64),
+	}
+
+	for _, name := range []string{snmpPath, netstatPath} {
+		data, err := fs.ReadFile(p.procFS(), name)
+		if err != nil {
+			return nil, fmt.Errorf("reading %q: %w", p.displayPath(name), err)
+		}
+
+		if err := parsePairedCounters(data, netCounters.Counters); err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", p.displayPath(name), err)
+		}
+	}
+
+	data, err := fs.ReadFile(p.procFS(), snmp6Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading %q: %w", p.displayPath(snmp6Path), err)
+	}
+
+	if err == nil {
+		if err := parseSNMP6Counters(data, netCounters.Counters); err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", p.displayPath(snmp6Path), err)
+		}
+	}
+
+	setCounterFields(&netCounters.TCP, netCounters.Counters[counterProtocolTCP])
+	setCounterFields(&netCounters.TCPExt, netCounters.Counters[counterProtocolTCPExt])
+
+	return netCounters, nil
+}
+
+// ParsePairedCounters parses counters in the format of /proc/net/snmp and /proc/net/netstat,
+// in which each protocol has a line of names followed by a line of values, each prefixed
+// with the protocol, e.g. "Tcp: ActiveOpens PassiveOpens" followed by "Tcp: 42 36".
+func parsePairedCounters(data []byte, counters map[string]map[string]int64) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		protocol, names, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			return fmt.Errorf("line %d: missing protocol", line)
+		}
+
+		if !scanner.Scan() {
+			return fmt.Errorf("line %d: missing values of %s counters", line, protocol)
+		}
+
+		line++
+		valueProtocol, values, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || valueProtocol != protocol {
+			return fmt.Errorf("line %d: expected values of %s counters", line, protocol)
+		}
+
+		nameFields, valueFields := strings.Fields(names), strings.Fields(values)
+		if len(nameFields) != len(valueFields) {
+			return fmt.Errorf("line %d: %d values of %d %s counters",
+				line, len(valueFields), len(nameFields), protocol)
+		}
+
+		for i, name := range nameFields {
+			if err := setCounter(counters, protocol, name, valueFields[i]); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// ParseSNMP6Counters parses counters in the format of /proc/net/snmp6, in which each line
+// holds the name of a counter, prefixed with its protocol, and its value, e.g.
+// "Udp6InDatagrams 12". The protocol is taken to be the name up to its first "6".
+func parseSNMP6Counters(data []byte, counters map[string]map[string]int64) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: expected name and value, got %d fields", line, len(fields))
+		}
+
+		protocol, name := fields[0], fields[0]
+		if i := strings.IndexByte(fields[0], '6'); i >= 0 {
+			protocol, name = fields[0][:i+1], fields[0][i+1:]
+		}
+
+		if err := setCounter(counters, protocol, name, fields[1]); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// SetCounter parses value and sets it as the named counter of protocol in counters.
+func setCounter(counters map[string]map[string]int64, protocol, name, value string) error {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		// Counters are unsigned longs, so may exceed the largest int64 after long uptimes,
+		// in which case their bits are kept, to be compared as unsigned by CounterDeltas
+		u, uErr := strconv.ParseUint(value, 10, 64)
+		if uErr != nil {
+			return fmt.Errorf("unable to parse %s %s %q as integer: %w", protocol, name, value, err)
+		}
+
+		v = int64(u)
+	}
+
+	if counters[protocol] == nil {
+		counters[protocol] = make(map[string]int64)
+	}
+
+	counters[protocol][name] = v
+	return nil
+}
+
+// SetCounterFields sets each int64 field of the struct pointed to by dst to the counter
+// of the same name, leaving fields without a counter zero.
+func setCounterFields(dst any, counters map[string]int64) {
+	v := reflect.ValueOf(dst).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		v.Field(i).SetInt(counters[v.Type().Field(i).Name])
+	}
+}
+
+// CounterDeltas returns the increase of each counter from prev to cur, keyed as in
+// NetCounters.Counters. Counters are compared as the unsigned longs counted by the kernel,
+// so counters which have exceeded the largest int64 are not mistaken for having been reset.
+// Gauges, counters missing from either sample, and counters which decreased, having been
+// reset or wrapped, are omitted.
+func CounterDeltas(prev, cur *NetCounters) map[string]map[string]int64 {
+	deltas := make(map[string]map[string]int64, len(cur.Counters))
+
+	for protocol, curCounters := range cur.Counters {
+		for name, curValue := range curCounters {
+			if gaugeCounters[protocol][name] {
+				continue
+			}
+
+			prevValue, ok := prev.Counters[protocol][name]
+			if !ok || uint64(curValue) < uint64(prevValue) {
+				continue
+			}
+
+			delta := uint64(curValue) - uint64(prevValue)
+			if delta > math.MaxInt64 {
+				continue
+			}
+
+			if deltas[protocol] == nil {
+				deltas[protocol] = make(map[string]int64, len(curCounters))
+			}
+
+			deltas[protocol][name] = int64(delta)
+		}
+	}
+
+	return deltas
+}
+
+// CounterRates returns the rate per second of each counter between prev and cur, keyed as
+// in NetCounters.Counters, with counters omitted as by CounterDeltas. It is an error for
+// cur not to have been read after prev.
+func CounterRates(prev, cur *NetCounters) (map[string]map[string]float64, error) {
+	interval := cur.Time.Sub(prev.Time)
+	if interval <= 0 {
+		return nil, fmt.Errorf("counters not read after previous counters: interval %v", interval)
+	}
+
+	deltas := CounterDeltas(prev, cur)
+	rates := make(map[string]map[string]float64, len(deltas))
+
+	for protocol, protocolDeltas := range deltas {
+		rates[protocol] = make(map[string]float64, len(protocolDeltas))
+		for name, delta := range protocolDeltas {
+			rates[protocol][name] = float64(delta) / interval.Seconds()
+		}
+	}
+
+	return rates, nil
+}
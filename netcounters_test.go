@@ -0,0 +1,241 @@
+package tcpconnparser
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const mockSNMP = `Ip: Forwarding DefaultTTL InReceives
+Ip: 2 64 4670
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 42 36 0 17 2 4622 4638 5 1 7 0
+`
+
+const mockNetstat = `TcpExt: SyncookiesSent ListenOverflows ListenDrops TCPTimeouts TCPBacklogDrop TCPFutureCounter
+TcpExt: 3 11 12 9 4 18446744073709551615
+IpExt: InNoRoutes InOctets
+IpExt: 0 123456
+`
+
+const mockSNMP6 = `Ip6InReceives                   	3
+Icmp6InMsgs                     	1
+UdpLite6InDatagrams             	0
+`
+
+func TestGetNetCounters(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/snmp":    {Data: []byte(mockSNMP)},
+		"net/netstat": {Data: []byte(mockNetstat)},
+		"net/snmp6":   {Data: []byte(mockSNMP6)},
+	}
+
+	output, err := NewParser(WithFS(mockFS)).GetNetCounters()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	expectedTCP := TCPCounters{
+		RtoAlgorithm: 1,
+		RtoMin:       200,
+		RtoMax:       120000,
+		MaxConn:      -1,
+		ActiveOpens:  42,
+		PassiveOpens: 36,
+		EstabResets:  17,
+		CurrEstab:    2,
+		InSegs:       4622,
+		OutSegs:      4638,
+		RetransSegs:  5,
+		InErrs:       1,
+		OutRsts:      7,
+	}
+	if output.TCP != expectedTCP {
+		t.Errorf("expected Tcp counters %+v, got %+v", expectedTCP, output.TCP)
+	}
+
+	expectedTCPExt := TCPExtCounters{
+		SyncookiesSent:  3,
+		ListenOverflows: 11,
+		ListenDrops:     12,
+		TCPTimeouts:     9,
+		TCPBacklogDrop:  4,
+	}
+	if output.TCPExt != expectedTCPExt {
+		t.Errorf("expected TcpExt counters %+v, got %+v", expectedTCPExt, output.TCPExt)
+	}
+
+	tests := []struct {
+		protocol, name string
+		expected       int64
+	}{
+		{"Ip", "InReceives", 4670},
+		{"IpExt", "InOctets", 123456},
+		{"TcpExt", "TCPFutureCounter", -1}, // Wrapped from the largest unsigned long
+		{"Ip6", "InReceives", 3},
+		{"Icmp6", "InMsgs", 1},
+		{"UdpLite6", "InDatagrams", 0},
+	}
+
+	for _, test := range tests {
+		value, ok := output.Counters[test.protocol][test.name]
+		if !ok || value != test.expected {
+			t.Errorf("expected %s %s counter %d, got %d (present: %t)", test.protocol, test.name, test.expected, value, ok)
+		}
+	}
+}
+
+func TestGetNetCountersWithoutIPv6(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/snmp":    {Data: []byte(mockSNMP)},
+		"net/netstat": {Data: []byte(mockNetstat)},
+	}
+
+	if _, err := NewParser(WithFS(mockFS)).GetNetCounters(); err != nil {
+		t.Errorf("expected nil error, got %v (of type %T)", err, err)
+	}
+}
+
+func TestGetNetCountersMissingError(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/snmp": {Data: []byte(mockSNMP)},
+	}
+
+	_, err := NewParser(WithFS(mockFS)).GetNetCounters()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestParsePairedCountersError(t *testing.T) {
+	inputs := []string{
+		"Tcp: ActiveOpens PassiveOpens\n",
+		"Tcp: ActiveOpens PassiveOpens\nTcp: 1\n",
+		"Tcp: ActiveOpens\nUdp: 1\n",
+		"Tcp: ActiveOpens\nTcp: many\n",
+		"Tcp ActiveOpens\nTcp 1\n",
+	}
+
+	for _, input := range inputs {
+		err := parsePairedCounters([]byte(input), make(map[string]map[string]int64))
+		if err == nil {
+			t.Errorf("expected error, got nil for input %q", input)
+			continue
+		}
+
+		t.Logf("got error %q (of type %T) for input %q", err, err, input)
+	}
+}
+
+func TestParseSNMP6CountersError(t *testing.T) {
+	inputs := []string{
+		"Ip6InReceives",
+		"Ip6InReceives 1 2",
+		"Ip6InReceives many",
+	}
+
+	for _, input := range inputs {
+		err := parseSNMP6Counters([]byte(input), make(map[string]map[string]int64))
+		if err == nil {
+			t.Errorf("expected error, got nil for input %q", input)
+			continue
+		}
+
+		t.Logf("got error %q (of type %T) for input %q", err, err, input)
+	}
+}
+
+func TestCounterRates(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	prev := &NetCounters{
+		Time: start,
+		Counters: map[string]map[string]int64{
+			"Tcp":    {"ActiveOpens": 100, "CurrEstab": 5, "OutRsts": 50},
+			"TcpExt": {"ListenOverflows": 10, "TCPTimeouts": 4},
+		},
+	}
+	cur := &NetCounters{
+		Time: start.Add(10 * time.Second),
+		Counters: map[string]map[string]int64{
+			"Tcp":    {"ActiveOpens": 150, "CurrEstab": 9, "OutRsts": 20},
+			"TcpExt": {"ListenOverflows": 30, "TCPTimeouts": 4, "TCPNew": 1},
+		},
+	}
+
+	deltas := CounterDeltas(prev, cur)
+	if deltas["TcpExt"]["ListenOverflows"] != 20 {
+		t.Errorf("expected ListenOverflows delta 20, got %d", deltas["TcpExt"]["ListenOverflows"])
+	}
+
+	rates, err := CounterRates(prev, cur)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	expected := map[string]map[string]float64{
+		"Tcp":    {"ActiveOpens": 5},
+		"TcpExt": {"ListenOverflows": 2, "TCPTimeouts": 0},
+	}
+
+	for protocol, expectedRates := range expected {
+		if len(rates[protocol]) != len(expectedRates) {
+			t.Errorf("expected %s rates %v, got %v", protocol, expectedRates, rates[protocol])
+		}
+
+		for name, expectedRate := range expectedRates {
+			if rate, ok := rates[protocol][name]; !ok || rate != expectedRate {
+				t.Errorf("expected %s %s rate %g, got %g (present: %t)", protocol, name, expectedRate, rate, ok)
+			}
+		}
+	}
+}
+
+func TestCounterDeltasAboveMaxInt64(t *testing.T) {
+	prev := &NetCounters{Counters: make(map[string]map[string]int64)}
+	cur := &NetCounters{Counters: make(map[string]map[string]int64)}
+
+	// Values of each counter in prev and cur, as read from procfs
+	values := map[string][2]string{
+		"OutSegs":     {"9223372036854775800", "9223372036854775820"},
+		"InSegs":      {"18446744073709551600", "18446744073709551610"},
+		"ActiveOpens": {"9223372036854775807", "5"},
+	}
+
+	for name, value := range values {
+		if err := setCounter(prev.Counters, "Tcp", name, value[0]); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if err := setCounter(cur.Counters, "Tcp", name, value[1]); err != nil {
+			t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+		}
+	}
+
+	deltas := CounterDeltas(prev, cur)
+
+	// OutSegs exceeds the largest int64 between samples, and InSegs is above it in both
+	expected := map[string]int64{"OutSegs": 20, "InSegs": 10}
+	for name, expectedDelta := range expected {
+		if delta, ok := deltas["Tcp"][name]; !ok || delta != expectedDelta {
+			t.Errorf("expected %s delta %d, got %d (present: %t)", name, expectedDelta, delta, ok)
+		}
+	}
+
+	// ActiveOpens was reset
+	if delta, ok := deltas["Tcp"]["ActiveOpens"]; ok {
+		t.Errorf("expected no ActiveOpens delta, got %d", delta)
+	}
+}
+
+func TestCounterRatesIntervalError(t *testing.T) {
+	sample := &NetCounters{Time: time.Unix(1700000000, 0)}
+
+	_, err := CounterRates(sample, sample)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,27 @@
+module github.com/jhwbarlow/tcpconnparser/otelmetrics
+
+// OpenTelemetry v1.44 requires go 1.25. The root module declares an older go version,
+// which is why this package is a separate module.
+go 1.25.0
+
+require (
+	github.com/jhwbarlow/tcpconnparser v0.0.0-20261018183054-aaf44559548e
+	go.opentelemetry.io/otel v1.44.0
+	go.opentelemetry.io/otel/metric v1.44.0
+	go.opentelemetry.io/otel/sdk/metric v1.44.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	go.opentelemetry.io/otel/sdk v1.44.0 // indirect
+	go.opentelemetry.io/otel/trace v1.44.0 // indirect
+	golang.org/x/sys v0.46.0 // indirect
+)
+
+// Build against the enclosing checkout during development. Consumers of this module
+// ignore the replacement and use the required version.
+replace github.com/jhwbarlow/tcpconnparser => ../
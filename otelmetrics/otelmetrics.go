@@ -0,0 +1,201 @@
+// package otelmetrics registers asynchronous OpenTelemetry instruments reporting the TCP
+// connections returned by a tcpconnparser.Parser, read afresh on each collection cycle.
+//
+// Instruments are attributed per the OpenTelemetry semantic conventions for network
+// attributes, with network.transport always "tcp", network.type "ipv4" or "ipv6",
+// network.connection.state the state of the connection and server.port the port on
+// which a listener listens.
+//
+// This package is a separate module, so that users of tcpconnparser who do not use it
+// do not depend on the OpenTelemetry modules.
+package otelmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jhwbarlow/tcpconnparser"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+// Names of the registered instruments.
+const (
+	InstrumentConnections        = "tcpconn.connections"
+	InstrumentListenerQueueDepth = "tcpconn.listener.queue.depth"
+	InstrumentReceiveQueueSize   = "tcpconn.receive_queue.size"
+	InstrumentSendQueueSize      = "tcpconn.send_queue.size"
+)
+
+// Instruments are the asynchronous instruments observed from the connections.
+type instruments struct {
+	connections        metric.Int64ObservableUpDownCounter
+	listenerQueueDepth metric.Int64ObservableUpDownCounter
+	receiveQueueSize   metric.Int64ObservableUpDownCounter
+	sendQueueSize      metric.Int64ObservableUpDownCounter
+}
+
+// Register registers asynchronous instruments with meter reporting, for the connections
+// returned by parser.GetAllConnections for the given protocol versions, or both IPv4 and
+// IPv6 if none are given:
+//   - tcpconn.connections: the number of connections by family and state
+//   - tcpconn.listener.queue.depth: the summed accept queues of listeners by family and port
+//   - tcpconn.receive_queue.size and tcpconn.send_queue.size: the summed receive and send
+//     queues of connections by family and state, in bytes
+//
+// Protocol versions whose files do not exist, such as IPv6 on hosts where it is disabled,
+// are skipped. Lines which a parser created WithLenientParsing cannot parse are skipped
+// and reported to the global OpenTelemetry error handler, the connections which were
+// parsed still being observed. If reading the connections otherwise fails, no
+// observations are made for that cycle.
+// The returned Registration may be used to unregister the instruments.
+func Register(meter metric.Meter,
+	parser *tcpconnparser.Parser,
+	protocolVersions ...tcpconnparser.ProtocolVersion) (metric.Registration, error) {
+	var insts instruments
+	var err error
+
+	if insts.connections, err = meter.Int64ObservableUpDownCounter(InstrumentConnections,
+		metric.WithDescription("Number of TCP connections."),
+		metric.WithUnit("{connection}")); err != nil {
+		return nil, fmt.Errorf("creating %s instrument: %w", InstrumentConnections, err)
+	}
+
+	if insts.listenerQueueDepth, err = meter.Int64ObservableUpDownCounter(InstrumentListenerQueueDepth,
+		metric.WithDescription("Summed accept queue depths of listening TCP sockets."),
+		metric.WithUnit("{connection}")); err != nil {
+		return nil, fmt.Errorf("creating %s instrument: %w", InstrumentListenerQueueDepth, err)
+	}
+
+	if insts.receiveQueueSize, err = meter.Int64ObservableUpDownCounter(InstrumentReceiveQueueSize,
+		metric.WithDescription("Summed receive queue sizes of TCP connections."),
+		metric.WithUnit("By")); err != nil {
+		return nil, fmt.Errorf("creating %s instrument: %w", InstrumentReceiveQueueSize, err)
+	}
+
+	if insts.sendQueueSize, err = meter.Int64ObservableUpDownCounter(InstrumentSendQueueSize,
+		metric.WithDescription("Summed send queue sizes of TCP connections."),
+		metric.WithUnit("By")); err != nil {
+		return nil, fmt.Errorf("creating %s instrument: %w", InstrumentSendQueueSize, err)
+	}
+
+	callback := func(_ context.Context, observer metric.Observer) error {
+		conns, err := parser.GetAllConnections(protocolVersions...)
+
+		// A collection in error is not exported, so the connections which were parsed
+		// are observed and the lines which were not are reported separately
+		var parseErrs tcpconnparser.ParseErrors
+		if err != nil && !errors.As(err, &parseErrs) {
+			return err
+		}
+
+		insts.observe(observer, conns)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		return nil
+	}
+
+	registration, err := meter.RegisterCallback(callback,
+		insts.connections,
+		insts.listenerQueueDepth,
+		insts.receiveQueueSize,
+		insts.sendQueueSize)
+	if err != nil {
+		return nil, fmt.Errorf("registering callback: %w", err)
+	}
+
+	return registration, nil
+}
+
+// StateKey is the key of the sums by family and state.
+type stateKey struct {
+	protocolVersion tcpconnparser.ProtocolVersion
+	state           tcpconnparser.State
+}
+
+// ListenerKey is the key of the sums by family and listening port.
+type listenerKey struct {
+	protocolVersion tcpconnparser.ProtocolVersion
+	port            uint16
+}
+
+// Observe sums the given connections and records the sums with observer.
+func (insts *instruments) observe(observer metric.Observer, conns []*tcpconnparser.Connection) {
+	counts := make(map[stateKey]int64)
+	rxQueues := make(map[stateKey]int64)
+	txQueues := make(map[stateKey]int64)
+	acceptQueues := make(map[listenerKey]int64)
+
+	for _, conn := range conns {
+		key := stateKey{conn.ProtocolVersion, conn.State}
+		counts[key]++
+		rxQueues[key] += int64(conn.ReceiveBufferSize)
+		txQueues[key] += int64(conn.SendBufferSize)
+
+		if conn.State == tcpconnparser.StateListen {
+			acceptQueues[listenerKey{conn.ProtocolVersion, conn.LocalPort}] += int64(conn.AcceptBacklog)
+		}
+	}
+
+	for key, count := range counts {
+		attrs := metric.WithAttributes(semconv.NetworkTransportTCP,
+			networkType(key.protocolVersion),
+			connectionState(key.state))
+
+		observer.ObserveInt64(insts.connections, count, attrs)
+		observer.ObserveInt64(insts.receiveQueueSize, rxQueues[key], attrs)
+		observer.ObserveInt64(insts.sendQueueSize, txQueues[key], attrs)
+	}
+
+	for key, depth := range acceptQueues {
+		observer.ObserveInt64(insts.listenerQueueDepth, depth,
+			metric.WithAttributes(semconv.NetworkTransportTCP,
+				networkType(key.protocolVersion),
+				semconv.ServerPort(int(key.port))))
+	}
+}
+
+// NetworkType returns the network.type attribute of the given protocol version.
+func networkType(protocolVersion tcpconnparser.ProtocolVersion) attribute.KeyValue {
+	if protocolVersion == tcpconnparser.ProtocolVersionIPv6 {
+		return semconv.NetworkTypeIPv6
+	}
+
+	return semconv.NetworkTypeIPv4
+}
+
+// ConnectionState returns the network.connection.state attribute of the given state.
+// States without a semantic convention value, i.e. StateUnknown, are given "unknown".
+func connectionState(state tcpconnparser.State) attribute.KeyValue {
+	switch state {
+	case tcpconnparser.StateListen:
+		return semconv.NetworkConnectionStateListen
+	case tcpconnparser.StateSynSent:
+		return semconv.NetworkConnectionStateSynSent
+	case tcpconnparser.StateSynReceived:
+		return semconv.NetworkConnectionStateSynReceived
+	case tcpconnparser.StateEstablished:
+		return semconv.NetworkConnectionStateEstablished
+	case tcpconnparser.StateFinWait1:
+		return semconv.NetworkConnectionStateFinWait1
+	case tcpconnparser.StateFinWait2:
+		return semconv.NetworkConnectionStateFinWait2
+	case tcpconnparser.StateCloseWait:
+		return semconv.NetworkConnectionStateCloseWait
+	case tcpconnparser.StateClosing:
+		return semconv.NetworkConnectionStateClosing
+	case tcpconnparser.StateLastAck:
+		return semconv.NetworkConnectionStateLastAck
+	case tcpconnparser.StateTimeWait:
+		return semconv.NetworkConnectionStateTimeWait
+	case tcpconnparser.StateClosed:
+		return semconv.NetworkConnectionStateClosed
+	default:
+		return semconv.NetworkConnectionStateKey.String("unknown")
+	}
+}
@@ -0,0 +1,197 @@
+package otelmetrics
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jhwbarlow/tcpconnparser"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+const mockTCP = `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0
+1: 0301A8C0:D3A0 7D10DD58:01BB 01 00000010:00000020 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1
+2: 0301A8C0:D3A1 7D10DD58:01BB 01 00000001:00000002 02:0000009A 00000000  1000        0 380688 2 0000000000000000 22 4 2 10 -1`
+
+const mockTCP6 = `sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+2: 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 31267 1 0000000000000000 100 0 0 10 0`
+
+// Collect registers the instruments with a meter reading from mockFS and collects them.
+func collect(t *testing.T, mockFS fstest.MapFS) map[string]metricdata.Sum[int64] {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	parser := tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS))
+	if _, err := Register(provider.Meter("test"), parser); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	sums := make(map[string]metricdata.Sum[int64])
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("expected %s to be an int64 sum, got %T", m.Name, m.Data)
+			}
+
+			if sum.IsMonotonic {
+				t.Errorf("expected %s to be non-monotonic", m.Name)
+			}
+
+			sums[m.Name] = sum
+		}
+	}
+
+	return sums
+}
+
+// FindValue returns the value of the data point of sum with exactly the given attributes.
+func findValue(t *testing.T, sum metricdata.Sum[int64], attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+
+	expected := attribute.NewSet(attrs...)
+	for _, dp := range sum.DataPoints {
+		if dp.Attributes.Equals(&expected) {
+			return dp.Value
+		}
+	}
+
+	t.Errorf("expected data point with attributes %v", expected.ToSlice())
+	return 0
+}
+
+func TestRegisterObservesConnections(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp":  {Data: []byte(mockTCP)},
+		"net/tcp6": {Data: []byte(mockTCP6)},
+	}
+
+	sums := collect(t, mockFS)
+
+	established := []attribute.KeyValue{
+		attribute.String("network.transport", "tcp"),
+		attribute.String("network.type", "ipv4"),
+		attribute.String("network.connection.state", "established"),
+	}
+
+	tests := []struct {
+		instrument string
+		attrs      []attribute.KeyValue
+		expected   int64
+	}{
+		{InstrumentConnections, established, 2},
+		{InstrumentReceiveQueueSize, established, 0x22},
+		{InstrumentSendQueueSize, established, 0x11},
+		{InstrumentConnections, []attribute.KeyValue{
+			attribute.String("network.transport", "tcp"),
+			attribute.String("network.type", "ipv6"),
+			attribute.String("network.connection.state", "listen"),
+		}, 1},
+		{InstrumentListenerQueueDepth, []attribute.KeyValue{
+			attribute.String("network.transport", "tcp"),
+			attribute.String("network.type", "ipv4"),
+			attribute.Int("server.port", 6789),
+		}, 50},
+		{InstrumentListenerQueueDepth, []attribute.KeyValue{
+			attribute.String("network.transport", "tcp"),
+			attribute.String("network.type", "ipv6"),
+			attribute.Int("server.port", 631),
+		}, 0},
+	}
+
+	for _, test := range tests {
+		output := findValue(t, sums[test.instrument], test.attrs...)
+		if output != test.expected {
+			t.Errorf("expected %s %v to be %d, got %d", test.instrument, test.attrs, test.expected, output)
+		}
+	}
+}
+
+func TestRegisterSkipsMissingProtocolVersion(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte(mockTCP)},
+	}
+
+	sums := collect(t, mockFS)
+
+	if output := len(sums[InstrumentConnections].DataPoints); output != 2 {
+		t.Errorf("expected 2 data points, got %d", output)
+	}
+}
+
+func TestRegisterLenientParseErrorsObservesParsed(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte(mockTCP + "\n   9: bogus line\n")},
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	parser := tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS), tcpconnparser.WithLenientParsing(0))
+	if _, err := Register(provider.Meter("test"), parser); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	var output int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != InstrumentConnections {
+				continue
+			}
+
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				output += dp.Value
+			}
+		}
+	}
+
+	if output != 3 {
+		t.Errorf("expected 3 connections, got %d", output)
+	}
+}
+
+func TestRegisterReadErrorObservesNothing(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte("bogus header")},
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	parser := tcpconnparser.NewParser(tcpconnparser.WithFS(mockFS))
+	if _, err := Register(provider.Meter("test"), parser); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	err := reader.Collect(context.Background(), &rm)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+
+	for _, sm := range rm.ScopeMetrics {
+		if len(sm.Metrics) != 0 {
+			t.Errorf("expected no metrics, got %d", len(sm.Metrics))
+		}
+	}
+}
@@ -8,25 +8,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"os"
+	"path"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 )
 
-// Indices of interesting fields within the space-separated fields of a
-// /proc/net/tcp* pseudo-file.
-const (
-	indexLocalAddress = 1
-	indexRemAddress   = 2
-	indexState        = 3
-	indexQueues       = 4
-	indexUID          = 7
-	indexINode        = 9
-
-	minNoOfFields = 10
-)
+// Directory from which procfs files are read unless a Parser is configured otherwise.
+const defaultProcRoot = "/proc"
+
+// Offset of the unnamed socket address field from the inode field within the
+// space-separated fields of a /proc/net/tcp* pseudo-file.
+const offsetSockPtrFromINode = 2
 
 // Indices of subfields within the colon-seperated address fields of a
 // /proc/net/tcp* pseudo-file.
@@ -53,46 +50,295 @@ func init() {
 	}
 }
 
+// Parser parses connections from the procfs /proc/net/tcp* pseudo-files,
+// according to the Options it was constructed with. The zero Parser reads from the
+// host's /proc with no Options, as does that returned by NewParser with none given.
+type Parser struct {
+	allowUnknownStates bool
+	lenient            bool
+	maxErrors          int
+	maxPasses          int
+	tolerance          float64
+	fsys               fs.FS  // Nil for the host's /proc
+	root               string // Used to describe paths within fsys and to read links
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithUnknownStates configures a Parser to accept kernel TCP states which are not
+// known to this package, rather than failing the whole read. Connections in such
+// states are given the State StateUnknown, with the raw kernel state held in KernelState.
+func WithUnknownStates() Option {
+	return func(p *Parser) {
+		p.allowUnknownStates = true
+	}
+}
+
+// WithLenientParsing configures a Parser to skip lines which cannot be parsed, rather than
+// failing the whole read. The connections which could be parsed are returned along with a
+// ParseErrors describing the skipped lines. If more than maxErrors lines cannot be parsed,
+// the Parser gives up and returns only an error. A maxErrors of zero or less sets no limit.
+func WithLenientParsing(maxErrors int) Option {
+	return func(p *Parser) {
+		p.lenient = true
+		p.maxErrors = maxErrors
+	}
+}
+
+// ReadLinkFS is a file system which can read symbolic links, as the io/fs.ReadLinkFS of
+// Go 1.25 and later. A file system given to WithFS must be a ReadLinkFS for GetSocketOwners
+// to read the file descriptor links of processes. The file systems of os.DirFS and, from
+// Go 1.25, testing/fstest.MapFS are ReadLinkFSs.
+type ReadLinkFS interface {
+	fs.FS
+
+	// ReadLink returns the destination of the named symbolic link.
+	ReadLink(name string) (string, error)
+}
+
+// WithFS configures a Parser to read all procfs files from fsys, rather than from the
+// host's /proc. The root of fsys is taken as the root of procfs, e.g. the TCP connections
+// are read from "net/tcp". This allows use of a testing/fstest.MapFS, for example.
+// Socket owners can be read only if fsys is a ReadLinkFS.
+func WithFS(fsys fs.FS) Option {
+	return func(p *Parser) {
+		p.fsys = fsys
+		p.root = ""
+	}
+}
+
+// WithProcRoot configures a Parser to read all procfs files from the directory dir, rather
+// than from the host's /proc. This allows analysis of a /proc captured from another machine.
+func WithProcRoot(dir string) Option {
+	return func(p *Parser) {
+		p.fsys = os.DirFS(dir)
+		p.root = dir
+	}
+}
+
+// NewParser constructs a new Parser configured with the provided Options.
+// Unless configured otherwise, procfs files are read from the host's /proc.
+func NewParser(opts ...Option) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
 // GetConnections returns a slice of Connections which is the union of all connections
 // using the provided protocolVersions.
 func GetConnections(protocolVersions ...ProtocolVersion) ([]*Connection, error) {
+	return NewParser().GetConnections(protocolVersions...)
+}
+
+// GetConnectionsFromReader returns a slice of Connections read from the provided Reader.
+// It is expected that the reader provides connections in a format which matches that given
+// by the IP protocol version given in protocolVersion, otherwise parsing errors will result.
+func GetConnectionsFromReader(reader io.Reader, protocolVersion ProtocolVersion) ([]*Connection, error) {
+	return NewParser().GetConnectionsFromReader(reader, protocolVersion)
+}
+
+// GetConnections returns a slice of Connections which is the union of all connections
+// using the provided protocolVersions.
+// If the Parser is lenient, the errors for lines skipped in all files are returned
+// together as a single ParseErrors, along with the connections.
+func (p *Parser) GetConnections(protocolVersions ...ProtocolVersion) ([]*Connection, error) {
+	return p.getConnectionsInDir("", protocolVersions...)
+}
+
+// GetAllConnections returns a slice of Connections which is the union of all connections
+// using the provided protocolVersions, or both IPv4 and IPv6 if none are given, as
+// GetConnections does, except that protocol versions whose files do not exist, such as
+// IPv6 on hosts where it is disabled, are skipped. If the Parser is lenient, the errors
+// for lines skipped in all files are returned together as a single ParseErrors, along
+// with the connections. Any other error fails the whole read and no connections are
+// returned.
+func (p *Parser) GetAllConnections(protocolVersions ...ProtocolVersion) ([]*Connection, error) {
+	if len(protocolVersions) == 0 {
+		protocolVersions = []ProtocolVersion{ProtocolVersionIPv4, ProtocolVersionIPv6}
+	}
+
 	allConns := make([]*Connection, 0, 4096)
+	var allParseErrs ParseErrors
 
 	for _, protocolVersion := range protocolVersions {
-		path, err := protocolVersion.path()
-		if err != nil {
-			return nil, fmt.Errorf("getting path: %w", err)
+		conns, err := p.GetConnections(protocolVersion)
+		if parseErrs, ok := err.(ParseErrors); ok {
+			allParseErrs = append(allParseErrs, parseErrs...)
+		} else if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("getting %s connections: %w", protocolVersion, err)
 		}
 
-		file, err := os.Open(path)
+		allConns = append(allConns, conns...)
+	}
+
+	if len(allParseErrs) > 0 {
+		return allConns, allParseErrs
+	}
+
+	return allConns, nil
+}
+
+// GetProcessConnections returns a slice of Connections which is the union of all connections
+// using the provided protocolVersions, within the network namespace of the process with the
+// given PID, as read from the /proc/<pid>/net/tcp* pseudo-files. Errors are returned in the
+// same manner as GetConnections.
+func (p *Parser) GetProcessConnections(pid int, protocolVersions ...ProtocolVersion) ([]*Connection, error) {
+	return p.getConnectionsInDir(strconv.Itoa(pid), protocolVersions...)
+}
+
+// GetConnectionsInDir returns a slice of Connections which is the union of all connections
+// using the provided protocolVersions, read from the procfs directory dir, or the procfs root
+// if dir is empty.
+func (p *Parser) getConnectionsInDir(dir string, protocolVersions ...ProtocolVersion) ([]*Connection, error) {
+	allConns := make([]*Connection, 0, 4096)
+	var allParseErrs ParseErrors
+
+	for _, protocolVersion := range protocolVersions {
+		file, path, err := p.openConnectionsFile(dir, protocolVersion)
 		if err != nil {
-			return nil, fmt.Errorf("opening %q: %w", path, err)
+			return nil, err
 		}
 		defer file.Close()
 
-		conns, err := GetConnectionsFromReader(file, protocolVersion)
-		if err != nil {
+		conns, err := p.GetConnectionsFromReader(file, protocolVersion)
+		if parseErrs, ok := err.(ParseErrors); ok {
+			// Lenient parsing skipped some lines, but the connections are usable
+			parseErrs.setPath(path)
+			allParseErrs = append(allParseErrs, parseErrs...)
+		} else if err != nil {
+			setErrorPath(err, path)
 			return nil, fmt.Errorf("getting connections from file %q: %w", path, err)
 		}
 
 		allConns = append(allConns, conns...)
 	}
 
+	if len(allParseErrs) > 0 {
+		return allConns, allParseErrs
+	}
+
 	return allConns, nil
 }
 
+// OpenConnectionsFile opens the procfs file listing the TCP connections of the given
+// protocolVersion, within the procfs directory dir, or the procfs root if dir is empty.
+// The path of the file is also returned, for use in errors.
+func (p *Parser) openConnectionsFile(dir string, protocolVersion ProtocolVersion) (fs.File, string, error) {
+	name, err := protocolVersion.path()
+	if err != nil {
+		return nil, "", fmt.Errorf("getting path: %w", err)
+	}
+
+	if dir != "" {
+		name = path.Join(dir, name)
+	}
+
+	displayPath := p.displayPath(name)
+
+	file, err := p.procFS().Open(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening %q: %w", displayPath, err)
+	}
+
+	return file, displayPath, nil
+}
+
+// DisplayPath returns the path used to describe the file with the given name within
+// the Parser's procfs file system.
+func (p *Parser) displayPath(name string) string {
+	root := p.procRoot()
+	if root == "" {
+		return name
+	}
+
+	return filepath.Join(root, filepath.FromSlash(name))
+}
+
+// ProcFS returns the file system from which the Parser reads procfs files.
+func (p *Parser) procFS() fs.FS {
+	if p.fsys == nil {
+		return os.DirFS(defaultProcRoot)
+	}
+
+	return p.fsys
+}
+
+// ProcRoot returns the directory of the Parser's procfs file system, or an empty string
+// if it is not a directory of the operating system.
+func (p *Parser) procRoot() string {
+	if p.fsys == nil {
+		return defaultProcRoot
+	}
+
+	return p.root
+}
+
+// CanReadLinks returns whether the Parser can read symbolic links within its procfs file
+// system, as it can if the file system is a ReadLinkFS or a directory of the operating
+// system.
+func (p *Parser) canReadLinks() bool {
+	_, ok := p.procFS().(ReadLinkFS)
+	return ok || p.procRoot() != ""
+}
+
+// ReadLink returns the destination of the symbolic link with the given name within the
+// Parser's procfs file system. Links are read by the file system if it is a ReadLinkFS,
+// else from the operating system, so canReadLinks must be true.
+func (p *Parser) readLink(name string) (string, error) {
+	if linkFS, ok := p.procFS().(ReadLinkFS); ok {
+		return linkFS.ReadLink(name)
+	}
+
+	return os.Readlink(filepath.Join(p.procRoot(), filepath.FromSlash(name)))
+}
+
 // GetConnectionsFromReader returns a slice of Connections read from the provided Reader.
 // It is expected that the reader provides connections in a format which matches that given
 // by the IP protocol version given in protocolVersion, otherwise parsing errors will result.
-func GetConnectionsFromReader(reader io.Reader, protocolVersion ProtocolVersion) ([]*Connection, error) {
+// Errors parsing a line are returned wrapping a *ParseError.
+// If the Parser is lenient, lines which cannot be parsed are skipped, and the connections
+// are returned along with a ParseErrors describing the skipped lines.
+func (p *Parser) GetConnectionsFromReader(reader io.Reader, protocolVersion ProtocolVersion) ([]*Connection, error) {
+	entries, err := p.readEntries(reader, protocolVersion)
+	if _, ok := err.(ParseErrors); !ok && err != nil {
+		return nil, err
+	}
+
+	conns := make([]*Connection, 0, len(entries))
+	for _, entry := range entries {
+		conns = append(conns, entry.conn)
+	}
+
+	// Return any errors from lenient parsing along with the connections
+	return conns, err
+}
+
+// Entry is a Connection, along with the fields identifying the line it was parsed from.
+type entry struct {
+	conn    *Connection
+	slot    uint64
+	sockPtr uint64 // Zero if not known, e.g. when hidden by kptr_restrict
+}
+
+// ReadEntries returns a slice of entries read from the provided Reader, in the order they
+// were read. Errors are returned in the same manner as GetConnectionsFromReader.
+func (p *Parser) readEntries(reader io.Reader, protocolVersion ProtocolVersion) ([]*entry, error) {
 	ipParser, err := protocolVersion.parser()
 	if err != nil {
 		return nil, fmt.Errorf("getting parser: %w", err)
 	}
 
 	scanner := bufio.NewScanner(reader)
-	conns := make([]*Connection, 0, 2048)
-	firstLine := true
+	entries := make([]*entry, 0, 2048)
+	lineNo := 0
+	var cols *columns
+	var parseErrs ParseErrors
 
 	for {
 		if !scanner.Scan() {
@@ -100,88 +346,149 @@ func GetConnectionsFromReader(reader io.Reader, protocolVersion ProtocolVersion)
 				return nil, fmt.Errorf("scanning for connection line: %w", err)
 			}
 
-			return conns, nil
+			if len(parseErrs) > 0 {
+				return entries, parseErrs
+			}
+
+			return entries, nil
 		}
 
-		if firstLine {
-			firstLine = false
+		lineNo++
+		str := scanner.Text()
+
+		if lineNo == 1 {
+			cols, err = parseHeader(str)
+			if err != nil {
+				return nil, fmt.Errorf("parsing header: %w", &ParseError{Line: lineNo, Raw: str, Err: err})
+			}
+
 			continue
 		}
 
-		str := scanner.Text()
 		if len(str) == 0 {
 			continue
 		}
 
-		conn, err := toConn(str, ipParser, protocolVersion)
+		entry, err := p.toEntry(str, cols, ipParser, protocolVersion)
 		if err != nil {
-			return nil, fmt.Errorf("parsing event: %w", err)
+			err.Line = lineNo
+			err.Raw = str
+
+			if !p.lenient {
+				return nil, fmt.Errorf("parsing event: %w", err)
+			}
+
+			parseErrs = append(parseErrs, err)
+			if p.maxErrors > 0 && len(parseErrs) > p.maxErrors {
+				return nil, fmt.Errorf("giving up after %d unparseable lines: %w", len(parseErrs), parseErrs)
+			}
+
+			continue
 		}
 
-		conns = append(conns, conn)
+		entries = append(entries, entry)
 	}
 }
 
-// ToConn converts the given string into a Connection, using the provided ipParser to convert
-// the IP Address into a net.IP object. The ProtocolVersion of the connection is given by the
-// that provided in protocolVersion.
-func toConn(str string, ipParser ipParser, protocolVersion ProtocolVersion) (*Connection, error) {
+// ToEntry converts the given string into an entry, finding each field at the index given
+// in cols. The Connection of the entry is obtained using toConn. Any error is returned as
+// a *ParseError, the location of which is to be filled in by the caller.
+func (p *Parser) toEntry(str string,
+	cols *columns,
+	ipParser ipParser,
+	protocolVersion ProtocolVersion) (*entry, *ParseError) {
 	fields := strings.Fields(str)
-	if len(fields) < minNoOfFields {
-		return nil, fmt.Errorf("invalid format: line contained less than %d fields: %d",
-			minNoOfFields,
-			len(fields))
+	if len(fields) < cols.minNoOfFields {
+		return nil, &ParseError{
+			Err: fmt.Errorf("invalid format: line contained less than %d fields: %d",
+				cols.minNoOfFields,
+				len(fields)),
+		}
 	}
 
-	localAddr, localPort, err := parseAddress(fields[indexLocalAddress], ipParser)
+	slot, err := parseSlot(fields[cols.slot])
 	if err != nil {
-		return nil, fmt.Errorf("parsing local address: %w", err)
+		return nil, newFieldError(fieldSlot, fields[cols.slot], err)
 	}
 
-	remoteAddr, remotePort, err := parseAddress(fields[indexRemAddress], ipParser)
+	conn, parseErr := p.toConn(fields, cols, ipParser, protocolVersion)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	return &entry{
+		conn:    conn,
+		slot:    slot,
+		sockPtr: parseSockPtr(fields, cols),
+	}, nil
+}
+
+// ToConn converts the given fields into a Connection, finding each field at the index given
+// in cols and using the provided ipParser to convert the IP Address into a net.IP object.
+// The ProtocolVersion of the connection is given by the that provided in protocolVersion.
+// The caller must ensure that fields contains at least the minimum number of fields given
+// in cols. Any error is returned as a *ParseError, the location of which is to be filled
+// in by the caller.
+func (p *Parser) toConn(fields []string,
+	cols *columns,
+	ipParser ipParser,
+	protocolVersion ProtocolVersion) (*Connection, *ParseError) {
+	localAddr, localPort, err := parseAddress(fields[cols.localAddress], ipParser)
 	if err != nil {
-		return nil, fmt.Errorf("parsing remote address: %w", err)
+		return nil, newFieldError(fieldLocalAddress, fields[cols.localAddress], err)
 	}
 
-	txQueue, rxQueue, err := parseQueues(fields[indexQueues])
+	remoteAddr, remotePort, err := parseAddress(fields[cols.remoteAddress], ipParser)
 	if err != nil {
-		return nil, fmt.Errorf("parsing queue lengths: %w", err)
+		return nil, newFieldError(fieldRemoteAddress, fields[cols.remoteAddress], err)
 	}
 
-	state, err := parseState(fields[indexState])
+	txQueue, rxQueue, err := parseQueues(fields[cols.queues])
 	if err != nil {
-		return nil, fmt.Errorf("parsing connection state: %w", err)
+		return nil, newFieldError(fieldQueues, fields[cols.queues], err)
 	}
 
-	iNode, err := parseINode(fields[indexINode])
+	kernelState, state, err := parseState(fields[cols.state], p.allowUnknownStates)
 	if err != nil {
-		return nil, fmt.Errorf("parsing connection inode: %w", err)
+		return nil, newFieldError(fieldState, fields[cols.state], err)
 	}
 
-	uid, err := parseUID(fields[indexUID])
+	iNode, err := parseINode(fields[cols.iNode])
 	if err != nil {
-		return nil, fmt.Errorf("parsing UID: %w", err)
+		return nil, newFieldError(fieldINode, fields[cols.iNode], err)
 	}
 
+	uid, err := parseUID(fields[cols.uid])
+	if err != nil {
+		return nil, newFieldError(fieldUID, fields[cols.uid], err)
+	}
+
+	var conn *Connection
 	if state == StateListen {
-		return NewListeningConnection(protocolVersion,
+		conn = NewListeningConnection(protocolVersion,
+			rxQueue,
+			localAddr,
+			localPort,
+			uid,
+			iNode)
+	} else {
+		conn = NewConnection(state,
+			protocolVersion,
 			rxQueue,
+			txQueue,
 			localAddr,
 			localPort,
+			remoteAddr,
+			remotePort,
 			uid,
-			iNode), nil
+			iNode)
 	}
 
-	return NewConnection(state,
-		protocolVersion,
-		rxQueue,
-		txQueue,
-		localAddr,
-		localPort,
-		remoteAddr,
-		remotePort,
-		uid,
-		iNode), nil
+	// Retain the state exactly as reported by the kernel, as it may not be the
+	// one which the constructors assume, e.g. TCP_NEW_SYN_RECV.
+	conn.KernelState = kernelState
+
+	return conn, nil
 }
 
 // ParseAddress returns the IP address and port encoded in the provided string.
@@ -242,14 +549,52 @@ func parseQueues(str string) (tx, rx uint32, err error) {
 	return uint32(txUint64), uint32(rxUint64), nil
 }
 
-// ParseState returns the TCP connection state encoded in the provided string.
-func parseState(str string) (State, error) {
-	state, err := convertState(str)
+// ParseState returns the kernel TCP state encoded in the provided string, along with
+// the State it represents. If allowUnknown is true, kernel states which are not known
+// to this package are returned with the State StateUnknown, rather than an error.
+func parseState(str string, allowUnknown bool) (KernelState, State, error) {
+	kernelState, err := parseKernelState(str)
+	if err != nil {
+		return 0, StateNone, fmt.Errorf("unable to parse state %q: %w", str, err)
+	}
+
+	if !kernelState.Known() && allowUnknown {
+		return kernelState, StateUnknown, nil
+	}
+
+	state, err := kernelState.State()
+	if err != nil {
+		return 0, StateNone, fmt.Errorf("unable to parse state %q: %w", str, err)
+	}
+
+	return kernelState, state, nil
+}
+
+// ParseSlot returns the slot number encoded in the provided string.
+func parseSlot(str string) (uint64, error) {
+	slot, err := strconv.ParseUint(strings.TrimSuffix(str, ":"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse slot %q as integer: %w", str, err)
+	}
+
+	return slot, nil
+}
+
+// ParseSockPtr returns the kernel address of the socket, which is given in the unnamed
+// field following the inode and reference count. As the field is not named in the header,
+// zero is returned if it is not present or cannot be parsed, rather than an error.
+func parseSockPtr(fields []string, cols *columns) uint64 {
+	index := cols.iNode + offsetSockPtrFromINode
+	if index >= len(fields) {
+		return 0
+	}
+
+	sockPtr, err := strconv.ParseUint(fields[index], 16, 64)
 	if err != nil {
-		return StateNone, fmt.Errorf("unable to parse state %q: %w", str, err)
+		return 0
 	}
 
-	return state, nil
+	return sockPtr
 }
 
 // ParseUID returns the UID encoded in the provided string.
@@ -1,9 +1,12 @@
 package tcpconnparser
 
 import (
+	"errors"
 	"net"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestGetConnectionsListeningConnIPv4(t *testing.T) {
@@ -333,3 +336,327 @@ func TestGetConnectionsBadProtocolVersionError(t *testing.T) {
 
 	t.Logf("got error %q (of type %T)", err, err)
 }
+
+func TestGetConnectionsNewSynRecvKernelState(t *testing.T) {
+	mockFile := `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 0100007F:D3A0 0C 00000000:00000000 02:0000009A 00000000  1000        0 0 2 0000000000000000 22 4 2 10 -1`
+
+	conns, err := GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err != nil {
+		t.Errorf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(conns) != 1 {
+		t.Fatalf("expected conns slice to include 1 connection, but contained %d", len(conns))
+	}
+
+	conn := conns[0]
+
+	if conn.State != StateSynReceived {
+		t.Errorf("expected state %q, got %q", StateSynReceived, conn.State)
+	}
+
+	if conn.KernelState != KernelStateNewSynRecv {
+		t.Errorf("expected kernel state %s, got %s", KernelStateNewSynRecv, conn.KernelState)
+	}
+
+	t.Logf("got conn %q", conn)
+}
+
+func TestGetConnectionsUnknownStateError(t *testing.T) {
+	mockFile := `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0301A8C0:D3A0 7D10DD58:D3A0 0F 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1`
+
+	_, err := GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestGetConnectionsWithUnknownStates(t *testing.T) {
+	mockFile := `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0301A8C0:D3A0 7D10DD58:D3A0 0F 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1
+1: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0`
+
+	conns, err := NewParser(WithUnknownStates()).
+		GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err != nil {
+		t.Errorf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(conns) != 2 {
+		t.Fatalf("expected conns slice to include 2 connections, but contained %d", len(conns))
+	}
+
+	conn := conns[0]
+
+	if conn.State != StateUnknown {
+		t.Errorf("expected state %q, got %q", StateUnknown, conn.State)
+	}
+
+	if conn.KernelState != KernelState(0x0F) {
+		t.Errorf("expected kernel state 0x0F, got %s", conn.KernelState)
+	}
+
+	t.Logf("got conn %q", conn)
+}
+
+func TestGetConnectionsWithLenientParsing(t *testing.T) {
+	mockFile := `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0
+1: BADADDRESS:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1
+2: 0301A8C0:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380688 2 0000000000000000 22 4 2 10 -1`
+
+	conns, err := NewParser(WithLenientParsing(0)).
+		GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	var parseErrs ParseErrors
+	if !errors.As(err, &parseErrs) {
+		t.Fatalf("expected error to be ParseErrors, got %q (of type %T)", err, err)
+	}
+
+	if len(parseErrs) != 1 {
+		t.Errorf("expected 1 parse error, got %d", len(parseErrs))
+	}
+
+	if parseErrs[0].Line != 3 {
+		t.Errorf("expected parse error on line 3, got %d", parseErrs[0].Line)
+	}
+
+	if len(conns) != 2 {
+		t.Errorf("expected conns slice to include 2 connections, but contained %d", len(conns))
+	}
+
+	t.Logf("got conns %q and error %q (of type %T)", conns, err, err)
+}
+
+func TestGetConnectionsWithLenientParsingMaxErrorsError(t *testing.T) {
+	mockFile := `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: BADADDRESS:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0
+1: BADADDRESS:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1
+2: 0301A8C0:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380688 2 0000000000000000 22 4 2 10 -1`
+
+	conns, err := NewParser(WithLenientParsing(1)).
+		GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if conns != nil {
+		t.Errorf("expected nil conns slice, got %q", conns)
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestGetConnectionsWithFS(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte(`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0`)},
+		"net/tcp6": {Data: []byte(`sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+2: 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 31267 1 0000000000000000 100 0 0 10 0`)},
+	}
+
+	conns, err := NewParser(WithFS(mockFS)).GetConnections(ProtocolVersionIPv4, ProtocolVersionIPv6)
+	if err != nil {
+		t.Errorf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(conns) != 2 {
+		t.Errorf("expected conns slice to include 2 connections, but contained %d", len(conns))
+	}
+
+	t.Logf("got conns %q", conns)
+}
+
+func TestGetProcessConnectionsWithFS(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"1234/net/tcp": {Data: []byte(`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0`)},
+	}
+
+	conns, err := NewParser(WithFS(mockFS)).GetProcessConnections(1234, ProtocolVersionIPv4)
+	if err != nil {
+		t.Errorf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(conns) != 1 {
+		t.Errorf("expected conns slice to include 1 connection, but contained %d", len(conns))
+	}
+
+	t.Logf("got conns %q", conns)
+}
+
+func TestGetConnectionsWithFSParseErrorPath(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte(`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 ZZ 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0`)},
+	}
+
+	_, err := NewParser(WithFS(mockFS)).GetConnections(ProtocolVersionIPv4)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected error to wrap a *ParseError, got %q (of type %T)", err, err)
+	}
+
+	if parseErr.Path != "net/tcp" {
+		t.Errorf("expected path %q, got %q", "net/tcp", parseErr.Path)
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestGetConnectionsWithFSMissingFileError(t *testing.T) {
+	_, err := NewParser(WithFS(fstest.MapFS{})).GetConnections(ProtocolVersionIPv4)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestZeroParserReadsHostProc(t *testing.T) {
+	var parser Parser
+
+	if parser.procFS() == nil {
+		t.Error("expected non-nil procfs file system")
+	}
+
+	expected := filepath.Join("/proc", "net", "tcp")
+	if output := parser.displayPath("net/tcp"); output != expected {
+		t.Errorf("expected path %q, got %q", expected, output)
+	}
+}
+
+func TestGetAllConnectionsSkipsMissingFile(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte(`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0`)},
+	}
+
+	conns, err := NewParser(WithFS(mockFS)).GetAllConnections()
+	if err != nil {
+		t.Errorf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(conns) != 1 {
+		t.Errorf("expected conns slice to include 1 connection, but contained %d", len(conns))
+	}
+
+	t.Logf("got conns %q", conns)
+}
+
+func TestGetAllConnectionsWithLenientParsing(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte(`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0
+1: BADADDRESS:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1`)},
+		"net/tcp6": {Data: []byte(`sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+2: 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 ZZ 00000000:00000000 00:00000000 00000000     0        0 31267 1 0000000000000000 100 0 0 10 0`)},
+	}
+
+	conns, err := NewParser(WithFS(mockFS), WithLenientParsing(0)).GetAllConnections()
+
+	var parseErrs ParseErrors
+	if !errors.As(err, &parseErrs) {
+		t.Fatalf("expected error to be ParseErrors, got %q (of type %T)", err, err)
+	}
+
+	if len(parseErrs) != 2 {
+		t.Errorf("expected 2 parse errors, got %d", len(parseErrs))
+	}
+
+	if len(conns) != 1 {
+		t.Errorf("expected conns slice to include 1 connection, but contained %d", len(conns))
+	}
+
+	t.Logf("got conns %q and error %q (of type %T)", conns, err, err)
+}
+
+func TestGetAllConnectionsParseError(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/tcp": {Data: []byte(`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 ZZ 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0`)},
+	}
+
+	conns, err := NewParser(WithFS(mockFS)).GetAllConnections()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if conns != nil {
+		t.Errorf("expected nil conns slice, got %q", conns)
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func FuzzParseAddress(f *testing.F) {
+	for _, seed := range []string{
+		"0100007F:1A85",
+		"00000000:0000",
+		"00000000000000000000000001000000:0277",
+		"0301A8C0",
+		"0301A8C0:BADPORT",
+		"::",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, parser := range []ipParser{new(ipv4Parser), new(ipv6Parser)} {
+			addr, _, err := parseAddress(input, parser)
+			if err == nil && addr == nil {
+				t.Errorf("expected non-nil address for input %q", input)
+			}
+		}
+	})
+}
+
+func FuzzToConn(f *testing.F) {
+	for _, seed := range []string{
+		"0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0",
+		"0: 0301A8C0:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 0000000000000000 22 4 2 10 -1",
+		"1: 0301A8C0:D3A0 7D10DD58:01BB 06 00000000:00000000 03:00000F2C 00000000     0        0 0 3 0000000000000000",
+		"2: 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 31267 1 0000000000000000 100 0 0 10 0",
+		"5: 00000000000000000000000001000000:1A85 00000000000000000000000001000000:BF7A 01 00000000:00000000 00:00000000 00000000  1000        0 394269 1 0000000000000000 20 0 0 10 -1",
+		"0: 0301A8C0:D3A0 7D10DD58:01BB",
+	} {
+		f.Add(seed)
+	}
+
+	ipv4Cols, err := parseHeader(tcpv4Header)
+	if err != nil {
+		f.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	ipv6Cols, err := parseHeader(tcpv6Header)
+	if err != nil {
+		f.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	parser := NewParser(WithUnknownStates())
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, protocolVersion := range []ProtocolVersion{ProtocolVersionIPv4, ProtocolVersionIPv6} {
+			cols, ipParser := ipv4Cols, ipParser(new(ipv4Parser))
+			if protocolVersion == ProtocolVersionIPv6 {
+				cols, ipParser = ipv6Cols, new(ipv6Parser)
+			}
+
+			entry, err := parser.toEntry(input, cols, ipParser, protocolVersion)
+			if err == nil && entry.conn == nil {
+				t.Errorf("expected non-nil connection for input %q", input)
+			}
+		}
+	})
+}
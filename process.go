@@ -0,0 +1,139 @@
+package tcpconnparser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Prefix and suffix of the target of a procfs file descriptor link to a socket,
+// e.g. "socket:[789829]".
+const (
+	socketLinkPrefix = "socket:["
+	socketLinkSuffix = "]"
+)
+
+// Process represents a process which holds a socket open.
+type Process struct {
+	PID     int
+	Command string // As given in /proc/<pid>/comm, empty if not known
+}
+
+// String returns a human-readable string representation of this Process.
+func (p Process) String() string {
+	if p.Command == "" {
+		return strconv.Itoa(p.PID)
+	}
+
+	return fmt.Sprintf("%s (%d)", p.Command, p.PID)
+}
+
+// GetSocketOwners returns the processes which hold each socket open, keyed by the socket
+// inode, as found from the /proc/<pid>/fd file descriptor links. Processes which exit, or
+// whose file descriptors cannot be read due to lack of permission, are skipped.
+// An error is returned if the file system given to WithFS is not a ReadLinkFS.
+func (p *Parser) GetSocketOwners() (map[uint32][]Process, error) {
+	if !p.canReadLinks() {
+		return nil, errors.New("reading socket owners: procfs file system is not a ReadLinkFS")
+	}
+
+	procEntries, err := fs.ReadDir(p.procFS(), ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading procfs root %q: %w", p.displayPath("."), err)
+	}
+
+	owners := make(map[uint32][]Process)
+
+	for _, procEntry := range procEntries {
+		pid, err := strconv.Atoi(procEntry.Name())
+		if err != nil || !procEntry.IsDir() {
+			// Not a process directory
+			continue
+		}
+
+		iNodes, err := p.getProcessSocketINodes(procEntry.Name())
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+				continue
+			}
+
+			return nil, fmt.Errorf("getting sockets of process %d: %w", pid, err)
+		}
+
+		if len(iNodes) == 0 {
+			continue
+		}
+
+		process := Process{
+			PID:     pid,
+			Command: p.getProcessCommand(procEntry.Name()),
+		}
+
+		for _, iNode := range iNodes {
+			owners[iNode] = append(owners[iNode], process)
+		}
+	}
+
+	return owners, nil
+}
+
+// GetProcessSocketINodes returns the inodes of the sockets held open by the process with
+// the given procfs directory.
+func (p *Parser) getProcessSocketINodes(dir string) ([]uint32, error) {
+	fdDir := path.Join(dir, "fd")
+
+	fdEntries, err := fs.ReadDir(p.procFS(), fdDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %w", p.displayPath(fdDir), err)
+	}
+
+	iNodes := make([]uint32, 0, len(fdEntries))
+
+	for _, fdEntry := range fdEntries {
+		target, err := p.readLink(path.Join(fdDir, fdEntry.Name()))
+		if err != nil {
+			// The file descriptor may have been closed since the directory was read
+			continue
+		}
+
+		iNode, ok := parseSocketLink(target)
+		if !ok {
+			continue
+		}
+
+		iNodes = append(iNodes, iNode)
+	}
+
+	return iNodes, nil
+}
+
+// GetProcessCommand returns the command name of the process with the given procfs
+// directory, or an empty string if it cannot be read.
+func (p *Parser) getProcessCommand(dir string) string {
+	comm, err := fs.ReadFile(p.procFS(), path.Join(dir, "comm"))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(comm))
+}
+
+// ParseSocketLink returns the socket inode given in the target of a procfs file descriptor
+// link, and whether the target was a socket.
+func parseSocketLink(target string) (uint32, bool) {
+	if !strings.HasPrefix(target, socketLinkPrefix) || !strings.HasSuffix(target, socketLinkSuffix) {
+		return 0, false
+	}
+
+	iNodeStr := strings.TrimSuffix(strings.TrimPrefix(target, socketLinkPrefix), socketLinkSuffix)
+
+	iNode, err := parseINode(iNodeStr)
+	if err != nil {
+		return 0, false
+	}
+
+	return iNode, true
+}
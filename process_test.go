@@ -0,0 +1,129 @@
+package tcpconnparser
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// MockLinkFS is a fstest.MapFS whose files with fs.ModeSymlink are symbolic links to their
+// Data, as read by ReadLink, which fstest.MapFS itself provides only from Go 1.25.
+type mockLinkFS struct {
+	fstest.MapFS
+}
+
+// ReadLink returns the Data of the named symbolic link.
+func (fsys mockLinkFS) ReadLink(name string) (string, error) {
+	file, ok := fsys.MapFS[name]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if file.Mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return string(file.Data), nil
+}
+
+func TestGetSocketOwners(t *testing.T) {
+	mockFS := mockLinkFS{fstest.MapFS{
+		"1234/comm":  {Data: []byte("nginx\n")},
+		"1234/fd/0":  {Data: []byte("/dev/null"), Mode: fs.ModeSymlink},
+		"1234/fd/3":  {Data: []byte("socket:[789829]"), Mode: fs.ModeSymlink},
+		"1234/fd/4":  {Data: []byte("socket:[380687]"), Mode: fs.ModeSymlink},
+		"5678/comm":  {Data: []byte("worker\n")},
+		"5678/fd/3":  {Data: []byte("socket:[789829]"), Mode: fs.ModeSymlink},
+		"net/tcp":    {Data: []byte("")},
+		"self/comm":  {Data: []byte("self\n")},
+		"sys/kernel": {Mode: fs.ModeDir},
+	}}
+
+	owners, err := NewParser(WithFS(mockFS)).GetSocketOwners()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(owners) != 2 {
+		t.Errorf("expected owners of 2 sockets, got %d", len(owners))
+	}
+
+	if len(owners[789829]) != 2 {
+		t.Errorf("expected 2 owners of socket 789829, got %v", owners[789829])
+	}
+
+	expected := Process{PID: 1234, Command: "nginx"}
+	if len(owners[380687]) != 1 || owners[380687][0] != expected {
+		t.Errorf("expected owner %v of socket 380687, got %v", expected, owners[380687])
+	}
+
+	t.Logf("got owners %v", owners)
+}
+
+func TestGetSocketOwnersNotReadLinkFSError(t *testing.T) {
+	mockFS := struct{ fs.FS }{fstest.MapFS{
+		"1234/fd/3": {Data: []byte("socket:[789829]"), Mode: fs.ModeSymlink},
+	}}
+
+	_, err := NewParser(WithFS(mockFS)).GetSocketOwners()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestGetSocketOwnersWithProcRoot(t *testing.T) {
+	root := t.TempDir()
+	fdDir := filepath.Join(root, "1234", "fd")
+	if err := os.MkdirAll(fdDir, 0o755); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "1234", "comm"), []byte("nginx\n"), 0o644); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if err := os.Symlink("socket:[789829]", filepath.Join(fdDir, "3")); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	owners, err := NewParser(WithProcRoot(root)).GetSocketOwners()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	expected := Process{PID: 1234, Command: "nginx"}
+	if len(owners[789829]) != 1 || owners[789829][0] != expected {
+		t.Errorf("expected owner %v of socket 789829, got %v", expected, owners[789829])
+	}
+
+	t.Logf("got owners %v", owners)
+}
+
+func TestParseSocketLink(t *testing.T) {
+	input := "socket:[789829]"
+	expected := uint32(789829)
+
+	output, ok := parseSocketLink(input)
+	if !ok {
+		t.Errorf("expected %q to be a socket link", input)
+	}
+
+	if output != expected {
+		t.Errorf("expected %d, got %d for input %q", expected, output, input)
+	}
+
+	t.Logf("got output %d for input %q", output, input)
+}
+
+func TestParseSocketLinkNotSocket(t *testing.T) {
+	input := "pipe:[789829]"
+
+	_, ok := parseSocketLink(input)
+	if ok {
+		t.Errorf("expected %q not to be a socket link", input)
+	}
+}
@@ -1,10 +1,16 @@
 package tcpconnparser
 
-import "fmt"
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
 
+// Paths of the procfs files listing TCP connections, relative to the procfs root.
 const (
-	tcpv4FilePath = "/proc/net/tcp"
-	tcpv6FilePath = "/proc/net/tcp6"
+	tcpv4FilePath = "net/tcp"
+	tcpv6FilePath = "net/tcp6"
 )
 
 // ProtocolVersion represents the an IP protocol version - currently IPv4 and IPv6
@@ -16,6 +22,7 @@ const (
 )
 
 // String returns a human-readable string representing this ProtocolVersion.
+// Unknown protocol versions are formatted with their numeric value.
 func (pv ProtocolVersion) String() string {
 	switch pv {
 	case ProtocolVersionIPv4:
@@ -23,12 +30,63 @@ func (pv ProtocolVersion) String() string {
 	case ProtocolVersionIPv6:
 		return "IPv6"
 	default:
-		panic(fmt.Errorf("illegal protocol version: %d", pv))
+		return fmt.Sprintf("ProtocolVersion(%d)", int(pv))
+	}
+}
+
+// ParseProtocolVersion returns the ProtocolVersion with the given name, as returned by String.
+func parseProtocolVersion(str string) (ProtocolVersion, error) {
+	switch str {
+	case ProtocolVersionIPv4.String():
+		return ProtocolVersionIPv4, nil
+	case ProtocolVersionIPv6.String():
+		return ProtocolVersionIPv6, nil
+	default:
+		return 0, fmt.Errorf("illegal protocol version: %q", str)
 	}
 }
 
-// Path returns the path to the procfs file used to obtain a list of TCP connections
-// of this ProtocolVersion.
+// DetectProtocolVersion returns the ProtocolVersion of the connections in a /proc/net/tcp*
+// pseudo-file read from reader, judged by the length of the local address of its first
+// connection, as located by the header. Files without connections are taken to be IPv4.
+func DetectProtocolVersion(reader io.Reader) (ProtocolVersion, error) {
+	scanner := bufio.NewScanner(reader)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return 0, fmt.Errorf("scanning for header line: %w", err)
+		}
+
+		return ProtocolVersionIPv4, nil
+	}
+
+	cols, err := parseHeader(scanner.Text())
+	if err != nil {
+		return 0, fmt.Errorf("parsing header: %w", err)
+	}
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= cols.localAddress {
+			continue
+		}
+
+		addr, _, _ := strings.Cut(fields[cols.localAddress], ":")
+		if len(addr) == nibblesInIPv6Address {
+			return ProtocolVersionIPv6, nil
+		}
+
+		return ProtocolVersionIPv4, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("scanning for connection line: %w", err)
+	}
+
+	return ProtocolVersionIPv4, nil
+}
+
+// Path returns the path, relative to the procfs root, to the procfs file used to obtain
+// a list of TCP connections of this ProtocolVersion.
 func (pv ProtocolVersion) path() (string, error) {
 	switch pv {
 	case ProtocolVersionIPv4:
@@ -52,3 +110,16 @@ func (pv ProtocolVersion) parser() (ipParser, error) {
 		return nil, fmt.Errorf("illegal protocol version: %d", pv)
 	}
 }
+
+// Formatter returns the ipFormatter used to format an entry for the file path returned by
+// the path method for this ProtocolVersion.
+func (pv ProtocolVersion) formatter() (ipFormatter, error) {
+	switch pv {
+	case ProtocolVersionIPv4:
+		return new(ipv4Parser), nil
+	case ProtocolVersionIPv6:
+		return new(ipv6Parser), nil
+	default:
+		return nil, fmt.Errorf("illegal protocol version: %d", pv)
+	}
+}
@@ -0,0 +1,46 @@
+package tcpconnparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectProtocolVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ProtocolVersion
+	}{
+		{`sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 51 0000000000000000 100 0 0 10 0`,
+			ProtocolVersionIPv4},
+		{`sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+2: 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 31267 1 0000000000000000 100 0 0 10 0`,
+			ProtocolVersionIPv6},
+		{`sl  uid  local_address                         remote_address                        st tx_queue rx_queue inode
+2: 0 00000000000000000000000001000000:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 31267`,
+			ProtocolVersionIPv6},
+		{"sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n",
+			ProtocolVersionIPv4},
+		{"", ProtocolVersionIPv4},
+	}
+
+	for _, test := range tests {
+		output, err := DetectProtocolVersion(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("expected nil error, got %v (of type %T)", err, err)
+		}
+
+		if output != test.expected {
+			t.Errorf("expected %v, got %v for input %q", test.expected, output, test.input)
+		}
+	}
+}
+
+func TestDetectProtocolVersionBadHeaderError(t *testing.T) {
+	_, err := DetectProtocolVersion(strings.NewReader("sl  local_address rem_address\n"))
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,215 @@
+package tcpconnparser
+
+import (
+	"fmt"
+	"io"
+)
+
+// Snapshot is a set of connections read from the procfs /proc/net/tcp* pseudo-files,
+// along with an assessment of whether it is known to be consistent.
+//
+// The pseudo-files are produced by the kernel in page-sized chunks, so under heavy churn
+// a socket can be seen twice or missed between the reads of successive chunks.
+// Sockets seen twice within a read are removed from the Connections of a Snapshot.
+type Snapshot struct {
+	Connections []*Connection
+
+	// Consistent is true if the final read contained no duplicated sockets or slot
+	// number regressions, and, if more than one read was made, agreed with the
+	// previous read within the tolerance configured with WithConsistentSnapshots.
+	Consistent bool
+
+	Passes          int // The number of times the pseudo-files were read
+	Duplicates      int // The number of duplicated sockets removed from the final read
+	SlotRegressions int // The number of times the slot number did not increase in the final read
+}
+
+// WithConsistentSnapshots configures a Parser to read the pseudo-files repeatedly when
+// taking a Snapshot, until two successive reads agree or maxPasses reads have been made.
+// Two reads agree if the number of sockets seen in only one of them is no more than
+// tolerance multiplied by the number of sockets in the larger of the two.
+// Without this Option, a Snapshot is taken in a single read.
+func WithConsistentSnapshots(maxPasses int, tolerance float64) Option {
+	return func(p *Parser) {
+		p.maxPasses = maxPasses
+		p.tolerance = tolerance
+	}
+}
+
+// ConnKey identifies a socket across the reads of a Snapshot.
+// The kernel address of the socket is used if known, otherwise the inode if the socket
+// has one, otherwise the addresses of the connection, e.g. for TIME-WAIT sockets.
+type connKey struct {
+	sockPtr               uint64
+	iNode                 uint32
+	protocolVersion       ProtocolVersion
+	localAddr, remoteAddr string
+	localPort, remotePort uint16
+}
+
+// NewConnKey returns the connKey identifying the socket of the given entry.
+func newConnKey(entry *entry) connKey {
+	if entry.sockPtr != 0 {
+		return connKey{sockPtr: entry.sockPtr}
+	}
+
+	return newConnectionKey(entry.conn)
+}
+
+// NewConnectionKey returns the connKey identifying the socket of the given Connection,
+// for which the kernel address of the socket is not known.
+func newConnectionKey(conn *Connection) connKey {
+	if conn.INode != 0 {
+		return connKey{iNode: conn.INode}
+	}
+
+	return newAddressKey(conn)
+}
+
+// NewAddressKey returns the connKey identifying the given Connection by its protocol
+// version, addresses and ports alone.
+func newAddressKey(conn *Connection) connKey {
+	return connKey{
+		protocolVersion: conn.ProtocolVersion,
+		localAddr:       string(conn.LocalAddr.To16()),
+		remoteAddr:      string(conn.RemoteAddr.To16()),
+		localPort:       conn.LocalPort,
+		remotePort:      conn.RemotePort,
+	}
+}
+
+// Pass is the result of a single read of the pseudo-files for a Snapshot.
+type pass struct {
+	keys            []connKey
+	conns           map[connKey]*Connection
+	duplicates      int
+	slotRegressions int
+}
+
+// GetSnapshot returns a Snapshot of all connections using the provided protocolVersions.
+// If the Parser is lenient, the errors for lines skipped in the final read are returned
+// as a ParseErrors, along with the Snapshot.
+func (p *Parser) GetSnapshot(protocolVersions ...ProtocolVersion) (*Snapshot, error) {
+	return p.getSnapshot(func(protocolVersion ProtocolVersion) (io.ReadCloser, string, error) {
+		return p.openConnectionsFile("", protocolVersion)
+	}, protocolVersions...)
+}
+
+// GetSnapshot returns a Snapshot of all connections using the provided protocolVersions,
+// reading each from the ReadCloser returned by open, which also returns the path read.
+func (p *Parser) getSnapshot(open func(ProtocolVersion) (io.ReadCloser, string, error),
+	protocolVersions ...ProtocolVersion) (*Snapshot, error) {
+	maxPasses := p.maxPasses
+	if maxPasses < 1 {
+		maxPasses = 1
+	}
+
+	var prev *pass
+	for passes := 1; ; passes++ {
+		cur, parseErrs, err := p.readPass(open, protocolVersions...)
+		if err != nil {
+			return nil, err
+		}
+
+		clean := cur.duplicates == 0 && cur.slotRegressions == 0
+		agreed := prev == nil || prev.agrees(cur, p.tolerance)
+
+		if (clean && agreed && passes > 1) || passes >= maxPasses {
+			snapshot := &Snapshot{
+				Connections:     cur.connections(),
+				Consistent:      clean && agreed,
+				Passes:          passes,
+				Duplicates:      cur.duplicates,
+				SlotRegressions: cur.slotRegressions,
+			}
+
+			if len(parseErrs) > 0 {
+				return snapshot, parseErrs
+			}
+
+			return snapshot, nil
+		}
+
+		prev = cur
+	}
+}
+
+// ReadPass reads all connections using the provided protocolVersions once, removing
+// duplicated sockets and counting slot number regressions.
+func (p *Parser) readPass(open func(ProtocolVersion) (io.ReadCloser, string, error),
+	protocolVersions ...ProtocolVersion) (*pass, ParseErrors, error) {
+	cur := &pass{
+		keys:  make([]connKey, 0, 4096),
+		conns: make(map[connKey]*Connection, 4096),
+	}
+	var allParseErrs ParseErrors
+
+	for _, protocolVersion := range protocolVersions {
+		reader, path, err := open(protocolVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entries, err := p.readEntries(reader, protocolVersion)
+		reader.Close()
+		if parseErrs, ok := err.(ParseErrors); ok {
+			parseErrs.setPath(path)
+			allParseErrs = append(allParseErrs, parseErrs...)
+		} else if err != nil {
+			setErrorPath(err, path)
+			return nil, nil, fmt.Errorf("getting connections from file %q: %w", path, err)
+		}
+
+		for i, entry := range entries {
+			if i > 0 && entry.slot <= entries[i-1].slot {
+				cur.slotRegressions++
+			}
+
+			key := newConnKey(entry)
+			if _, ok := cur.conns[key]; ok {
+				// Keep the most recently read copy of the socket
+				cur.duplicates++
+			} else {
+				cur.keys = append(cur.keys, key)
+			}
+
+			cur.conns[key] = entry.conn
+		}
+	}
+
+	return cur, allParseErrs, nil
+}
+
+// Agrees returns whether the sockets seen in this pass agree with those seen in other,
+// within the given tolerance.
+func (ps *pass) agrees(other *pass, tolerance float64) bool {
+	differences := 0
+	for key := range ps.conns {
+		if _, ok := other.conns[key]; !ok {
+			differences++
+		}
+	}
+
+	for key := range other.conns {
+		if _, ok := ps.conns[key]; !ok {
+			differences++
+		}
+	}
+
+	larger := len(ps.conns)
+	if len(other.conns) > larger {
+		larger = len(other.conns)
+	}
+
+	return float64(differences) <= tolerance*float64(larger)
+}
+
+// Connections returns the connections seen in this pass, in the order first read.
+func (ps *pass) connections() []*Connection {
+	conns := make([]*Connection, 0, len(ps.keys))
+	for _, key := range ps.keys {
+		conns = append(conns, ps.conns[key])
+	}
+
+	return conns
+}
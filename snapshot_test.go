@@ -0,0 +1,101 @@
+package tcpconnparser
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+const mockSnapshotHeader = `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+`
+
+// MockOpener returns an open function for getSnapshot which returns each of the given
+// IPv4 files in turn, returning the last repeatedly once exhausted.
+func mockOpener(files ...string) func(ProtocolVersion) (io.ReadCloser, string, error) {
+	i := 0
+	return func(ProtocolVersion) (io.ReadCloser, string, error) {
+		file := files[i]
+		if i < len(files)-1 {
+			i++
+		}
+
+		return io.NopCloser(strings.NewReader(mockSnapshotHeader + file)), "mock", nil
+	}
+}
+
+func TestGetSnapshotRemovesDuplicates(t *testing.T) {
+	mockFile := `0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 2 ffff8881003c2300 100 0 0 10 0
+1: 0301A8C0:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 ffff8881003c4600 22 4 2 10 -1
+1: 0301A8C0:D3A0 7D10DD58:01BB 01 00000000:00000000 02:0000009A 00000000  1000        0 380687 2 ffff8881003c4600 22 4 2 10 -1`
+
+	snapshot, err := NewParser().getSnapshot(mockOpener(mockFile), ProtocolVersionIPv4)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if len(snapshot.Connections) != 2 {
+		t.Errorf("expected snapshot to include 2 connections, but contained %d", len(snapshot.Connections))
+	}
+
+	if snapshot.Duplicates != 1 {
+		t.Errorf("expected 1 duplicate, got %d", snapshot.Duplicates)
+	}
+
+	if snapshot.SlotRegressions != 1 {
+		t.Errorf("expected 1 slot regression, got %d", snapshot.SlotRegressions)
+	}
+
+	if snapshot.Consistent {
+		t.Error("expected snapshot to be inconsistent")
+	}
+
+	t.Logf("got snapshot %+v", snapshot)
+}
+
+func TestGetSnapshotRetriesUntilPassesAgree(t *testing.T) {
+	inconsistentFile := `0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 2 0000000000000000 100 0 0 10 0
+0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 2 0000000000000000 100 0 0 10 0`
+	consistentFile := `0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 2 0000000000000000 100 0 0 10 0
+1: 0301A8C0:D3A0 7D10DD58:01BB 06 00000000:00000000 03:00000F2C 00000000     0        0 0 3 0000000000000000`
+
+	parser := NewParser(WithConsistentSnapshots(5, 0))
+	snapshot, err := parser.getSnapshot(mockOpener(inconsistentFile, consistentFile), ProtocolVersionIPv4)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if !snapshot.Consistent {
+		t.Error("expected snapshot to be consistent")
+	}
+
+	if snapshot.Passes != 3 {
+		t.Errorf("expected 3 passes, got %d", snapshot.Passes)
+	}
+
+	if len(snapshot.Connections) != 2 {
+		t.Errorf("expected snapshot to include 2 connections, but contained %d", len(snapshot.Connections))
+	}
+
+	t.Logf("got snapshot %+v", snapshot)
+}
+
+func TestGetSnapshotMaxPassesInconsistent(t *testing.T) {
+	firstFile := `0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 2 0000000000000000 100 0 0 10 0`
+	secondFile := `0: 0100007F:1A86 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789830 2 0000000000000000 100 0 0 10 0`
+
+	parser := NewParser(WithConsistentSnapshots(2, 0.5))
+	snapshot, err := parser.getSnapshot(mockOpener(firstFile, secondFile), ProtocolVersionIPv4)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if snapshot.Consistent {
+		t.Error("expected snapshot to be inconsistent")
+	}
+
+	if snapshot.Passes != 2 {
+		t.Errorf("expected 2 passes, got %d", snapshot.Passes)
+	}
+
+	t.Logf("got snapshot %+v", snapshot)
+}
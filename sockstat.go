@@ -0,0 +1,127 @@
+package tcpconnparser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Paths of the socket statistics pseudo-files, relative to the procfs root.
+const (
+	sockStatPath  = "net/sockstat"
+	sockStat6Path = "net/sockstat6"
+)
+
+// SockStat is the socket statistics reported by the kernel in the procfs
+// /proc/net/sockstat and /proc/net/sockstat6 pseudo-files.
+type SockStat struct {
+	SocketsUsed uint64 // The number of sockets of all types in use
+
+	TCP       TCPSockStat // The TCP statistics of /proc/net/sockstat
+	TCP6InUse uint64      // The number of IPv6 TCP sockets in use, if /proc/net/sockstat6 exists
+
+	// All the counters of both pseudo-files, keyed by the protocol and name they are
+	// given in the files, e.g. Counters["UDP"]["inuse"].
+	Counters map[string]map[string]uint64
+}
+
+// TCPSockStat is the TCP socket statistics reported in /proc/net/sockstat.
+// The counts include both IPv4 and IPv6 sockets.
+type TCPSockStat struct {
+	InUse    uint64 // Sockets in use, excluding TIME-WAIT and orphaned sockets
+	Orphan   uint64 // Sockets no longer attached to a file descriptor, awaiting closure
+	TimeWait uint64 // Sockets in TIME-WAIT
+	Alloc    uint64 // Sockets allocated, including TIME-WAIT and orphaned sockets
+	MemPages uint64 // Pages of memory used by socket buffers
+}
+
+// GetSockStat returns the socket statistics read from /proc/net/sockstat and, if it exists,
+// /proc/net/sockstat6, which is absent on hosts where IPv6 is disabled.
+func (p *Parser) GetSockStat() (*SockStat, error) {
+	sockStat := &SockStat{
+		Counters: make(map[string]map[string]uint64),
+	}
+
+	if err := p.readSockStatFile(sockStatPath, sockStat.Counters); err != nil {
+		return nil, err
+	}
+
+	err := p.readSockStatFile(sockStat6Path, sockStat.Counters)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	counter := func(protocol, name string) uint64 {
+		return sockStat.Counters[protocol][name]
+	}
+
+	sockStat.SocketsUsed = counter("sockets", "used")
+	sockStat.TCP = TCPSockStat{
+		InUse:    counter("TCP", "inuse"),
+		Orphan:   counter("TCP", "orphan"),
+		TimeWait: counter("TCP", "tw"),
+		Alloc:    counter("TCP", "alloc"),
+		MemPages: counter("TCP", "mem"),
+	}
+	sockStat.TCP6InUse = counter("TCP6", "inuse")
+
+	return sockStat, nil
+}
+
+// ReadSockStatFile reads the counters of the named socket statistics file into counters.
+func (p *Parser) readSockStatFile(name string, counters map[string]map[string]uint64) error {
+	data, err := fs.ReadFile(p.procFS(), name)
+	if err != nil {
+		return fmt.Errorf("reading %q: %w", p.displayPath(name), err)
+	}
+
+	if err := parseSockStat(data, counters); err != nil {
+		return fmt.Errorf("parsing %q: %w", p.displayPath(name), err)
+	}
+
+	return nil
+}
+
+// ParseSockStat parses the lines of a socket statistics file, each of the form
+// "<protocol>: <name> <value> [<name> <value>...]", into counters.
+func parseSockStat(data []byte, counters map[string]map[string]uint64) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for line := 1; scanner.Scan(); line++ {
+		protocol, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			if strings.TrimSpace(protocol) == "" {
+				continue
+			}
+
+			return fmt.Errorf("line %d: missing protocol", line)
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields)%2 != 0 {
+			return fmt.Errorf("line %d: unpaired counter name %q", line, fields[len(fields)-1])
+		}
+
+		protocolCounters := counters[protocol]
+		if protocolCounters == nil {
+			protocolCounters = make(map[string]uint64, len(fields)/2)
+			counters[protocol] = protocolCounters
+		}
+
+		for i := 0; i < len(fields); i += 2 {
+			value, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("line %d: unable to parse %s %s %q as integer: %w",
+					line, protocol, fields[i], fields[i+1], err)
+			}
+
+			protocolCounters[fields[i]] = value
+		}
+	}
+
+	return scanner.Err()
+}
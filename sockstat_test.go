@@ -0,0 +1,92 @@
+package tcpconnparser
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestGetSockStat(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/sockstat": {Data: []byte(`sockets: used 181
+TCP: inuse 7 orphan 1 tw 3 alloc 12 mem 2
+UDP: inuse 2 mem 4
+UDPLITE: inuse 0
+RAW: inuse 0
+FRAG: inuse 0 memory 0
+`)},
+		"net/sockstat6": {Data: []byte(`TCP6: inuse 4
+UDP6: inuse 1
+UDPLITE6: inuse 0
+RAW6: inuse 1
+FRAG6: inuse 0 memory 0
+`)},
+	}
+
+	output, err := NewParser(WithFS(mockFS)).GetSockStat()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	expectedTCP := TCPSockStat{InUse: 7, Orphan: 1, TimeWait: 3, Alloc: 12, MemPages: 2}
+	if output.TCP != expectedTCP {
+		t.Errorf("expected TCP statistics %+v, got %+v", expectedTCP, output.TCP)
+	}
+
+	if output.SocketsUsed != 181 {
+		t.Errorf("expected 181 sockets used, got %d", output.SocketsUsed)
+	}
+
+	if output.TCP6InUse != 4 {
+		t.Errorf("expected 4 TCP6 sockets in use, got %d", output.TCP6InUse)
+	}
+
+	if value := output.Counters["UDP"]["mem"]; value != 4 {
+		t.Errorf("expected UDP mem counter 4, got %d", value)
+	}
+
+	if value := output.Counters["RAW6"]["inuse"]; value != 1 {
+		t.Errorf("expected RAW6 inuse counter 1, got %d", value)
+	}
+}
+
+func TestGetSockStatWithoutIPv6(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"net/sockstat": {Data: []byte("sockets: used 1\nTCP: inuse 1 orphan 0 tw 0 alloc 1 mem 0\n")},
+	}
+
+	output, err := NewParser(WithFS(mockFS)).GetSockStat()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if output.TCP6InUse != 0 {
+		t.Errorf("expected 0 TCP6 sockets in use, got %d", output.TCP6InUse)
+	}
+}
+
+func TestGetSockStatMissingError(t *testing.T) {
+	_, err := NewParser(WithFS(fstest.MapFS{})).GetSockStat()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestParseSockStatError(t *testing.T) {
+	inputs := []string{
+		"TCP: inuse",
+		"TCP: inuse seven",
+		"TCP inuse 7",
+	}
+
+	for _, input := range inputs {
+		err := parseSockStat([]byte(input), make(map[string]map[string]uint64))
+		if err == nil {
+			t.Errorf("expected error, got nil for input %q", input)
+			continue
+		}
+
+		t.Logf("got error %q (of type %T) for input %q", err, err, input)
+	}
+}
@@ -1,24 +1,116 @@
 package tcpconnparser
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
+
+// KernelState represents the numeric TCP state used internally by the kernel,
+// as defined in kernel <net/tcp_states.h>.
+type KernelState uint8
 
 // Kernel TCP states defined in kernel <net/tcp_states.h>.
-// Formatted as hexadecimal one-byte strings
 const (
-	kernelTCPEstablished = "01"
-	kernelTCPSynSent     = "02"
-	kernelTCPSynRecv     = "03"
-	kernelTCPFinWait1    = "04"
-	kernelTCPFinWait2    = "05"
-	kernelTCPTimeWait    = "06"
-	kernelTCPClose       = "07"
-	kernelTCPCloseWait   = "08"
-	kernelTCPLastAck     = "09"
-	kernelTCPListen      = "0A"
-	kernelTCPClosing     = "0B"
-	kernelTCPNewSynRecv  = "0C"
+	KernelStateEstablished KernelState = 0x01
+	KernelStateSynSent     KernelState = 0x02
+	KernelStateSynRecv     KernelState = 0x03
+	KernelStateFinWait1    KernelState = 0x04
+	KernelStateFinWait2    KernelState = 0x05
+	KernelStateTimeWait    KernelState = 0x06
+	KernelStateClose       KernelState = 0x07
+	KernelStateCloseWait   KernelState = 0x08
+	KernelStateLastAck     KernelState = 0x09
+	KernelStateListen      KernelState = 0x0A
+	KernelStateClosing     KernelState = 0x0B
+	KernelStateNewSynRecv  KernelState = 0x0C
 )
 
+// String returns the name of this KernelState as given in kernel <net/tcp_states.h>.
+// Unknown states are formatted with their numeric value.
+func (ks KernelState) String() string {
+	switch ks {
+	case KernelStateEstablished:
+		return "TCP_ESTABLISHED"
+	case KernelStateSynSent:
+		return "TCP_SYN_SENT"
+	case KernelStateSynRecv:
+		return "TCP_SYN_RECV"
+	case KernelStateFinWait1:
+		return "TCP_FIN_WAIT1"
+	case KernelStateFinWait2:
+		return "TCP_FIN_WAIT2"
+	case KernelStateTimeWait:
+		return "TCP_TIME_WAIT"
+	case KernelStateClose:
+		return "TCP_CLOSE"
+	case KernelStateCloseWait:
+		return "TCP_CLOSE_WAIT"
+	case KernelStateLastAck:
+		return "TCP_LAST_ACK"
+	case KernelStateListen:
+		return "TCP_LISTEN"
+	case KernelStateClosing:
+		return "TCP_CLOSING"
+	case KernelStateNewSynRecv:
+		return "TCP_NEW_SYN_RECV"
+	default:
+		return fmt.Sprintf("TCP_UNKNOWN(0x%02X)", uint8(ks))
+	}
+}
+
+// ParseKernelStateName returns the KernelState with the given name, as returned by String.
+func ParseKernelStateName(name string) (KernelState, error) {
+	for ks := KernelStateEstablished; ks.Known(); ks++ {
+		if ks.String() == name {
+			return ks, nil
+		}
+	}
+
+	var unknown uint8
+	if _, err := fmt.Sscanf(name, "TCP_UNKNOWN(0x%02X)", &unknown); err == nil {
+		return KernelState(unknown), nil
+	}
+
+	return 0, fmt.Errorf("illegal kernel TCP state: %q", name)
+}
+
+// Known returns whether this KernelState is one defined in kernel <net/tcp_states.h>.
+func (ks KernelState) Known() bool {
+	return ks >= KernelStateEstablished && ks <= KernelStateNewSynRecv
+}
+
+// State converts this KernelState into a State.
+func (ks KernelState) State() (State, error) {
+	switch ks {
+	case KernelStateEstablished:
+		return StateEstablished, nil
+	case KernelStateSynSent:
+		return StateSynSent, nil
+	case KernelStateSynRecv:
+		return StateSynReceived, nil
+	case KernelStateFinWait1:
+		return StateFinWait1, nil
+	case KernelStateFinWait2:
+		return StateFinWait2, nil
+	case KernelStateTimeWait:
+		return StateTimeWait, nil
+	case KernelStateClose:
+		return StateClosed, nil
+	case KernelStateCloseWait:
+		return StateCloseWait, nil
+	case KernelStateLastAck:
+		return StateLastAck, nil
+	case KernelStateListen:
+		return StateListen, nil
+	case KernelStateClosing:
+		return StateClosing, nil
+	case KernelStateNewSynRecv:
+		return StateSynReceived, nil
+	default:
+		return StateNone, fmt.Errorf("illegal kernel TCP state: %s", ks)
+	}
+}
+
 // State represents the state of a TCP connection
 type State string
 
@@ -36,39 +128,65 @@ const (
 	StateTimeWait    State = "TIME-WAIT"
 	StateClosed      State = "CLOSED"
 
+	// A state reported by the kernel which is not known to this package.
+	// Only returned when a Parser is configured with WithUnknownStates.
+	StateUnknown State = "UNKNOWN"
+
 	// A nil state
 	StateNone State = ""
 )
 
-// ConvertState converts the internal kernel state representation (as a string)
-// into a State.
-func convertState(kernelState string) (State, error) {
-	switch kernelState {
-	case kernelTCPEstablished:
-		return StateEstablished, nil
-	case kernelTCPSynSent:
-		return StateSynSent, nil
-	case kernelTCPSynRecv:
-		return StateSynReceived, nil
-	case kernelTCPFinWait1:
-		return StateFinWait1, nil
-	case kernelTCPFinWait2:
-		return StateFinWait2, nil
-	case kernelTCPTimeWait:
-		return StateTimeWait, nil
-	case kernelTCPClose:
-		return StateClosed, nil
-	case kernelTCPCloseWait:
-		return StateCloseWait, nil
-	case kernelTCPLastAck:
-		return StateLastAck, nil
-	case kernelTCPListen:
-		return StateListen, nil
-	case kernelTCPClosing:
-		return StateClosing, nil
-	case kernelTCPNewSynRecv:
-		return StateSynReceived, nil
+// Valid returns whether this State is one defined by this package, other than StateNone.
+func (s State) valid() bool {
+	switch s {
+	case StateListen, StateSynSent, StateSynReceived, StateEstablished,
+		StateFinWait1, StateFinWait2, StateCloseWait, StateClosing,
+		StateLastAck, StateTimeWait, StateClosed, StateUnknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// KernelState returns the KernelState which most commonly represents this State.
+// As the kernel represents SYN-RECEIVED with both TCP_SYN_RECV and TCP_NEW_SYN_RECV,
+// the former is returned for StateSynReceived.
+func (s State) kernelState() KernelState {
+	switch s {
+	case StateEstablished:
+		return KernelStateEstablished
+	case StateSynSent:
+		return KernelStateSynSent
+	case StateSynReceived:
+		return KernelStateSynRecv
+	case StateFinWait1:
+		return KernelStateFinWait1
+	case StateFinWait2:
+		return KernelStateFinWait2
+	case StateTimeWait:
+		return KernelStateTimeWait
+	case StateClosed:
+		return KernelStateClose
+	case StateCloseWait:
+		return KernelStateCloseWait
+	case StateLastAck:
+		return KernelStateLastAck
+	case StateListen:
+		return KernelStateListen
+	case StateClosing:
+		return KernelStateClosing
 	default:
-		return StateNone, fmt.Errorf("illegal kernel TCP state: %q", kernelState)
+		return 0
+	}
+}
+
+// ParseKernelState converts the internal kernel state representation, formatted
+// as a hexadecimal one-byte string, into a KernelState.
+func parseKernelState(str string) (KernelState, error) {
+	stateUint64, err := strconv.ParseUint(str, 16, 8)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse kernel state %q as integer: %w", str, err)
 	}
+
+	return KernelState(stateUint64), nil
 }
@@ -0,0 +1,54 @@
+package tcpconnparser
+
+import "testing"
+
+func TestKernelStateString(t *testing.T) {
+	input := KernelStateNewSynRecv
+	expected := "TCP_NEW_SYN_RECV"
+
+	output := input.String()
+	if output != expected {
+		t.Errorf("expected %q, got %q for input %d", expected, output, input)
+	}
+
+	t.Logf("got output %q for input %d", output, input)
+}
+
+func TestKernelStateStringUnknown(t *testing.T) {
+	input := KernelState(0x0F)
+	expected := "TCP_UNKNOWN(0x0F)"
+
+	output := input.String()
+	if output != expected {
+		t.Errorf("expected %q, got %q for input %d", expected, output, input)
+	}
+
+	t.Logf("got output %q for input %d", output, input)
+}
+
+func TestKernelStateState(t *testing.T) {
+	input := KernelStateNewSynRecv
+	expected := StateSynReceived
+
+	output, err := input.State()
+	if err != nil {
+		t.Errorf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if output != expected {
+		t.Errorf("expected %q, got %q for input %s", expected, output, input)
+	}
+
+	t.Logf("got output %q for input %s", output, input)
+}
+
+func TestKernelStateStateUnknownError(t *testing.T) {
+	input := KernelState(0x0F)
+
+	_, err := input.State()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,61 @@
+package tcpconnparser
+
+// Summary is the summary statistics of a set of connections, as returned by Summarise.
+type Summary struct {
+	Total             int                     // The number of connections
+	ByState           map[State]int           // The number of connections in each State
+	ByProtocolVersion map[ProtocolVersion]int // The number of connections of each ProtocolVersion
+
+	// The number of connections in TIME-WAIT. Like orphans, these are no longer owned by
+	// a process, but are counted separately by the kernel.
+	TimeWait int
+
+	// The number of connections, other than in TIME-WAIT or SYN-RECV, without an inode,
+	// which are those no longer owned by a process that are awaiting closure by the kernel.
+	// Connections in SYN-RECV have no inode as they are requests not yet accepted.
+	Orphans int
+
+	Listeners int // The number of listening connections
+
+	// The listening connections with non-empty accept queues, i.e. with connections
+	// awaiting accept by their owning process, in the order given.
+	BackloggedListeners []*Connection
+
+	// The summed receive and send queues of the non-listening connections, in bytes.
+	ReceiveQueueBytes, SendQueueBytes uint64
+}
+
+// Summarise returns the summary statistics of the given connections.
+func Summarise(conns []*Connection) *Summary {
+	summary := &Summary{
+		Total:             len(conns),
+		ByState:           make(map[State]int),
+		ByProtocolVersion: make(map[ProtocolVersion]int),
+	}
+
+	for _, conn := range conns {
+		summary.ByState[conn.State]++
+		summary.ByProtocolVersion[conn.ProtocolVersion]++
+
+		switch {
+		case conn.State == StateListen:
+			summary.Listeners++
+			if conn.AcceptBacklog > 0 {
+				summary.BackloggedListeners = append(summary.BackloggedListeners, conn)
+			}
+
+			continue
+		case conn.State == StateTimeWait:
+			summary.TimeWait++
+		case conn.State == StateSynReceived:
+			// Requests not yet accepted have no inode, but are not orphaned
+		case conn.INode == 0:
+			summary.Orphans++
+		}
+
+		summary.ReceiveQueueBytes += uint64(conn.ReceiveBufferSize)
+		summary.SendQueueBytes += uint64(conn.SendBufferSize)
+	}
+
+	return summary
+}
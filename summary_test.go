@@ -0,0 +1,74 @@
+package tcpconnparser
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSummarise(t *testing.T) {
+	backlogged := NewListeningConnection(ProtocolVersionIPv4, 3, net.IPv4(0, 0, 0, 0), 80, 0, 1)
+	conns := []*Connection{
+		backlogged,
+		NewListeningConnection(ProtocolVersionIPv6, 0, net.IPv6unspecified, 443, 0, 2),
+		NewConnection(StateEstablished, ProtocolVersionIPv4, 100, 200,
+			net.IPv4(10, 0, 0, 1), 80, net.IPv4(10, 0, 0, 2), 40000, 0, 3),
+		NewConnection(StateEstablished, ProtocolVersionIPv6, 1, 2,
+			net.ParseIP("2001:db8::1"), 443, net.ParseIP("2001:db8::2"), 40001, 0, 4),
+		NewConnection(StateFinWait1, ProtocolVersionIPv4, 0, 10,
+			net.IPv4(10, 0, 0, 1), 80, net.IPv4(10, 0, 0, 3), 40002, 0, 0),
+		NewConnection(StateTimeWait, ProtocolVersionIPv4, 0, 0,
+			net.IPv4(10, 0, 0, 1), 80, net.IPv4(10, 0, 0, 4), 40003, 0, 0),
+	}
+
+	output := Summarise(conns)
+
+	if output.Total != 6 {
+		t.Errorf("expected total 6, got %d", output.Total)
+	}
+
+	if output.ByState[StateEstablished] != 2 || output.ByState[StateListen] != 2 || len(output.ByState) != 4 {
+		t.Errorf("unexpected counts by state %v", output.ByState)
+	}
+
+	if output.ByProtocolVersion[ProtocolVersionIPv4] != 4 || output.ByProtocolVersion[ProtocolVersionIPv6] != 2 {
+		t.Errorf("unexpected counts by protocol version %v", output.ByProtocolVersion)
+	}
+
+	if output.TimeWait != 1 || output.Orphans != 1 {
+		t.Errorf("expected 1 TIME-WAIT and 1 orphan, got %d and %d", output.TimeWait, output.Orphans)
+	}
+
+	if output.Listeners != 2 {
+		t.Errorf("expected 2 listeners, got %d", output.Listeners)
+	}
+
+	if len(output.BackloggedListeners) != 1 || output.BackloggedListeners[0] != backlogged {
+		t.Errorf("expected backlogged listeners %v, got %v", []*Connection{backlogged}, output.BackloggedListeners)
+	}
+
+	if output.ReceiveQueueBytes != 101 || output.SendQueueBytes != 212 {
+		t.Errorf("expected queues of 101 and 212 bytes, got %d and %d",
+			output.ReceiveQueueBytes, output.SendQueueBytes)
+	}
+}
+
+func TestSummariseSynReceivedNotOrphan(t *testing.T) {
+	mockFile := `sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+0: 0100007F:0050 0200007F:9C40 0C 00000000:00000000 02:0000009A 00000000     0        0 0 0 0000000000000000`
+
+	conns, err := GetConnectionsFromReader(strings.NewReader(mockFile), ProtocolVersionIPv4)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	output := Summarise(conns)
+
+	if output.ByState[StateSynReceived] != 1 {
+		t.Errorf("expected 1 SYN-RECV connection, got %v", output.ByState)
+	}
+
+	if output.Orphans != 0 {
+		t.Errorf("expected no orphans, got %d", output.Orphans)
+	}
+}
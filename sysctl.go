@@ -0,0 +1,45 @@
+package tcpconnparser
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Directory beneath the procfs root holding the sysctl files.
+const sysctlDir = "sys"
+
+// Sysctl giving the limit on the accept backlog of listening sockets.
+const sysctlSomaxconn = "net.core.somaxconn"
+
+// ReadSysctl returns the value of the sysctl with the given dotted name, e.g.
+// "net.core.somaxconn", as read from the /proc/sys files, with surrounding
+// whitespace removed.
+func (p *Parser) ReadSysctl(name string) (string, error) {
+	sysctlPath := sysctlDir + "/" + strings.ReplaceAll(name, ".", "/")
+
+	value, err := fs.ReadFile(p.procFS(), sysctlPath)
+	if err != nil {
+		return "", fmt.Errorf("reading %q: %w", p.displayPath(sysctlPath), err)
+	}
+
+	return strings.TrimSpace(string(value)), nil
+}
+
+// GetMaxAcceptBacklog returns the limit on the accept backlog of listening sockets, as given
+// by the net.core.somaxconn sysctl. As the /proc/net/tcp* pseudo-files do not give the
+// backlog requested by each listener, this is the best available upper bound on it.
+func (p *Parser) GetMaxAcceptBacklog() (uint32, error) {
+	value, err := p.ReadSysctl(sysctlSomaxconn)
+	if err != nil {
+		return 0, err
+	}
+
+	somaxconn, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse %s %q as integer: %w", sysctlSomaxconn, value, err)
+	}
+
+	return uint32(somaxconn), nil
+}
@@ -0,0 +1,51 @@
+package tcpconnparser
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadSysctl(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"sys/net/ipv4/ip_local_port_range": {Data: []byte("32768\t60999\n")},
+	}
+	expected := "32768\t60999"
+
+	output, err := NewParser(WithFS(mockFS)).ReadSysctl("net.ipv4.ip_local_port_range")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+
+	t.Logf("got output %q", output)
+}
+
+func TestGetMaxAcceptBacklog(t *testing.T) {
+	mockFS := fstest.MapFS{
+		"sys/net/core/somaxconn": {Data: []byte("4096\n")},
+	}
+	expected := uint32(4096)
+
+	output, err := NewParser(WithFS(mockFS)).GetMaxAcceptBacklog()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	if output != expected {
+		t.Errorf("expected %d, got %d", expected, output)
+	}
+
+	t.Logf("got output %d", output)
+}
+
+func TestGetMaxAcceptBacklogMissingError(t *testing.T) {
+	_, err := NewParser(WithFS(fstest.MapFS{})).GetMaxAcceptBacklog()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
@@ -0,0 +1,112 @@
+package tcpconnparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Headers of the /proc/net/tcp* pseudo-files, as written by the kernel.
+const (
+	tcpv4Header = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode"
+	tcpv6Header = "  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode"
+)
+
+// Width to which the kernel pads each line of the /proc/net/tcp pseudo-file.
+// Lines of the /proc/net/tcp6 pseudo-file are not padded.
+const tcpv4LineWidth = 149
+
+// Fields which are written verbatim, as they are not held in a Connection.
+// The trailer holds the ref count, socket address, rto, ato, quick ack, cwnd and ssthresh.
+const (
+	formattedTimers  = "00:00000000 00000000" // tr:tm->when retrnsmt
+	formattedTimeout = 0
+	formattedTrailer = "1 0000000000000000 100 0 0 10 -1"
+)
+
+// WriteConnections writes the provided connections to writer in the format of the /proc/net/tcp
+// or /proc/net/tcp6 pseudo-file given by protocolVersion, including the header. All connections
+// must be of the given protocolVersion. Fields not held in a Connection, such as timers, are
+// written as zero or with plausible defaults.
+func WriteConnections(writer io.Writer, protocolVersion ProtocolVersion, conns []*Connection) error {
+	formatter, err := protocolVersion.formatter()
+	if err != nil {
+		return fmt.Errorf("getting formatter: %w", err)
+	}
+
+	header, lineWidth := tcpv4Header, tcpv4LineWidth
+	if protocolVersion == ProtocolVersionIPv6 {
+		header, lineWidth = tcpv6Header, 0
+	}
+
+	buf := bufio.NewWriter(writer)
+
+	if _, err := fmt.Fprintf(buf, "%-*s\n", lineWidth, header); err != nil {
+		return fmt.Errorf("writing header: %w", err)
+	}
+
+	for slot, conn := range conns {
+		if conn.ProtocolVersion != protocolVersion {
+			return fmt.Errorf("connection %d has protocol version %s, expected %s",
+				slot,
+				conn.ProtocolVersion,
+				protocolVersion)
+		}
+
+		line, err := fromConn(conn, slot, formatter)
+		if err != nil {
+			return fmt.Errorf("formatting connection %d: %w", slot, err)
+		}
+
+		if _, err := fmt.Fprintf(buf, "%-*s\n", lineWidth, line); err != nil {
+			return fmt.Errorf("writing connection %d: %w", slot, err)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("flushing connections: %w", err)
+	}
+
+	return nil
+}
+
+// FromConn converts the given Connection into a line of a /proc/net/tcp* pseudo-file with
+// the given slot number, using the provided ipFormatter to format its IP addresses.
+// It is the inverse of toConn.
+func fromConn(conn *Connection, slot int, formatter ipFormatter) (string, error) {
+	localAddr, err := formatter.formatAddress(conn.LocalAddr)
+	if err != nil {
+		return "", fmt.Errorf("formatting local address: %w", err)
+	}
+
+	remoteAddr, err := formatter.formatAddress(conn.RemoteAddr)
+	if err != nil {
+		return "", fmt.Errorf("formatting remote address: %w", err)
+	}
+
+	kernelState := conn.KernelState
+	if kernelState == 0 {
+		kernelState = conn.State.kernelState()
+	}
+
+	// For listening connections, the kernel gives the accept backlog as the RX queue
+	txQueue, rxQueue := conn.SendBufferSize, conn.ReceiveBufferSize
+	if conn.State == StateListen {
+		txQueue, rxQueue = 0, conn.AcceptBacklog
+	}
+
+	return fmt.Sprintf("%4d: %s:%04X %s:%04X %02X %08X:%08X %s %5d %8d %d %s",
+		slot,
+		localAddr,
+		conn.LocalPort,
+		remoteAddr,
+		conn.RemotePort,
+		uint8(kernelState),
+		txQueue,
+		rxQueue,
+		formattedTimers,
+		conn.UID,
+		formattedTimeout,
+		conn.INode,
+		formattedTrailer), nil
+}
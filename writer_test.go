@@ -0,0 +1,163 @@
+package tcpconnparser
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestWriteConnectionsIPv4(t *testing.T) {
+	conns := []*Connection{
+		NewListeningConnection(ProtocolVersionIPv4,
+			50,
+			net.IPv4(127, 0, 0, 1),
+			6789,
+			1000,
+			789829),
+	}
+	expected := "   0: 0100007F:1A85 00000000:0000 0A 00000000:00000032 00:00000000 00000000  1000        0 789829 1 0000000000000000 100 0 0 10 -1"
+
+	buf := new(bytes.Buffer)
+	if err := WriteConnections(buf, ProtocolVersionIPv4, conns); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header, 1 line and trailing newline, got %q", buf.String())
+	}
+
+	if strings.TrimRight(lines[0], " ") != tcpv4Header {
+		t.Errorf("expected header %q, got %q", tcpv4Header, lines[0])
+	}
+
+	if strings.TrimRight(lines[1], " ") != expected {
+		t.Errorf("expected line %q, got %q", expected, lines[1])
+	}
+
+	t.Logf("got output %q", buf.String())
+}
+
+func TestWriteConnectionsIPv6(t *testing.T) {
+	conns := []*Connection{
+		NewConnection(StateEstablished,
+			ProtocolVersionIPv6,
+			0,
+			0,
+			net.ParseIP("::1"),
+			6789,
+			net.ParseIP("::1"),
+			49018,
+			1000,
+			394269),
+	}
+	expected := "   0: 00000000000000000000000001000000:1A85 00000000000000000000000001000000:BF7A 01 00000000:00000000 00:00000000 00000000  1000        0 394269 1 0000000000000000 100 0 0 10 -1"
+
+	buf := new(bytes.Buffer)
+	if err := WriteConnections(buf, ProtocolVersionIPv6, conns); err != nil {
+		t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header, 1 line and trailing newline, got %q", buf.String())
+	}
+
+	if lines[1] != expected {
+		t.Errorf("expected line %q, got %q", expected, lines[1])
+	}
+
+	t.Logf("got output %q", buf.String())
+}
+
+func TestWriteConnectionsMismatchedProtocolVersionError(t *testing.T) {
+	conns := []*Connection{
+		NewListeningConnection(ProtocolVersionIPv6,
+			0,
+			net.ParseIP("::1"),
+			631,
+			0,
+			31267),
+	}
+
+	err := WriteConnections(new(bytes.Buffer), ProtocolVersionIPv4, conns)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	t.Logf("got error %q (of type %T)", err, err)
+}
+
+func TestWriteConnectionsRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, protocolVersion := range []ProtocolVersion{ProtocolVersionIPv4, ProtocolVersionIPv6} {
+		for i := 0; i < 100; i++ {
+			conns := make([]*Connection, rng.Intn(50))
+			for j := range conns {
+				conns[j] = randomConnection(rng, protocolVersion)
+			}
+
+			buf := new(bytes.Buffer)
+			if err := WriteConnections(buf, protocolVersion, conns); err != nil {
+				t.Fatalf("expected nil error, got %v (of type %T)", err, err)
+			}
+
+			output, err := GetConnectionsFromReader(bytes.NewReader(buf.Bytes()), protocolVersion)
+			if err != nil {
+				t.Fatalf("expected nil error, got %v (of type %T) for input %q", err, err, buf.String())
+			}
+
+			if len(output) != len(conns) {
+				t.Fatalf("expected %d connections, got %d for input %q", len(conns), len(output), buf.String())
+			}
+
+			for j := range conns {
+				if !output[j].Equal(conns[j]) {
+					t.Errorf("expected connection to be equal to %q, but was %q", conns[j], output[j])
+				}
+			}
+		}
+	}
+}
+
+// RandomConnection returns a Connection with random fields of the given protocolVersion.
+func randomConnection(rng *rand.Rand, protocolVersion ProtocolVersion) *Connection {
+	randomIP := func() net.IP {
+		ip := make(net.IP, 4)
+		if protocolVersion == ProtocolVersionIPv6 {
+			ip = make(net.IP, 16)
+		}
+
+		rng.Read(ip)
+		return ip
+	}
+
+	kernelState := KernelState(rng.Intn(int(KernelStateNewSynRecv)) + 1)
+	state, _ := kernelState.State()
+
+	if state == StateListen {
+		return NewListeningConnection(protocolVersion,
+			rng.Uint32(),
+			randomIP(),
+			uint16(rng.Uint32()),
+			rng.Uint32(),
+			rng.Uint32())
+	}
+
+	conn := NewConnection(state,
+		protocolVersion,
+		rng.Uint32(),
+		rng.Uint32(),
+		randomIP(),
+		uint16(rng.Uint32()),
+		randomIP(),
+		uint16(rng.Uint32()),
+		rng.Uint32(),
+		rng.Uint32())
+	conn.KernelState = kernelState
+
+	return conn
+}